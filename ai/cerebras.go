@@ -2,10 +2,16 @@ package ai
 
 import (
     "bytes"
+    "context"
     "encoding/json"
     "fmt"
     "net/http"
     "os"
+    "strings"
+    "time"
+
+    "discord-ai-bot/logging"
+    "discord-ai-bot/metrics"
 )
 
 // Message is the standard structure for LLM chat history.
@@ -25,55 +31,147 @@ type CerebrasResponse struct {
     Choices []struct {
         Message Message `json:"message"`
     } `json:"choices"`
+    Usage Usage `json:"usage"`
+}
+
+// Usage is the token accounting returned with every completion.
+type Usage struct {
+    PromptTokens     int `json:"prompt_tokens"`
+    CompletionTokens int `json:"completion_tokens"`
+    TotalTokens      int `json:"total_tokens"`
+}
+
+// DefaultModel is used when CEREBRAS_MODEL is not set.
+const DefaultModel = "llama-3.3-70b"
+
+// ConfiguredModel returns CEREBRAS_MODEL, or DefaultModel when it is unset.
+func ConfiguredModel() string {
+    if model := os.Getenv("CEREBRAS_MODEL"); model != "" {
+        return model
+    }
+    return DefaultModel
+}
+
+// ModelAllowed reports whether callers may pick model. ASK_MODELS is a
+// comma-separated allowlist; without it only ConfiguredModel is allowed.
+func ModelAllowed(model string) bool {
+    if model == ConfiguredModel() {
+        return true
+    }
+    for _, m := range strings.Split(os.Getenv("ASK_MODELS"), ",") {
+        if m = strings.TrimSpace(m); m != "" && m == model {
+            return true
+        }
+    }
+    return false
+}
+
+// modelLabel keeps metric labels bounded: models outside the allowlist are
+// counted together.
+func modelLabel(model string) string {
+    if ModelAllowed(model) {
+        return model
+    }
+    return "other"
+}
+
+// GetCerebrasResponse sends the conversation history to the Cerebras API
+// using the model from CEREBRAS_MODEL. The request is cancelled with ctx and
+// logged with the logger ctx carries.
+func GetCerebrasResponse(ctx context.Context, history []Message) (string, error) {
+    return GetCerebrasResponseWithModel(ctx, "", history)
 }
 
-// GetCerebrasResponse sends the conversation history to the Cerebras API.
-func GetCerebrasResponse(history []Message) (string, error) {
+// GetCerebrasResponseWithModel is GetCerebrasResponse with an explicit model.
+// An empty model falls back to CEREBRAS_MODEL and then DefaultModel.
+func GetCerebrasResponseWithModel(ctx context.Context, model string, history []Message) (string, error) {
+    completion, err := GetCerebrasCompletion(ctx, model, history)
+    return completion.Content, err
+}
+
+// Completion is a chat completion together with its token usage.
+type Completion struct {
+    Content string
+    Model   string
+    Usage   Usage
+}
+
+// GetCerebrasCompletion is GetCerebrasResponseWithModel, but also returns the
+// model used and the token usage reported by the API.
+func GetCerebrasCompletion(ctx context.Context, model string, history []Message) (Completion, error) {
     apiKey := os.Getenv("CEREBRAS_API_KEY")
     if apiKey == "" {
-        return "", fmt.Errorf("CEREBRAS_API_KEY not set")
+        return Completion{}, fmt.Errorf("CEREBRAS_API_KEY not set")
     }
 
     // The model and API endpoint may need updating based on Cerebras's current documentation
     url := "https://api.cerebras.ai/v1/chat/completions" 
-    model := "llama-3.3-70b" // Example model
+    if model == "" {
+        model = ConfiguredModel()
+    }
+
+    logger := logging.FromContext(ctx).With("model", model)
+    start := time.Now()
+    apiResp, err := callCerebras(ctx, url, apiKey, CerebrasRequest{Model: model, Messages: history})
+    latency := time.Since(start)
+    metrics.LLMLatency.Observe(latency.Seconds(), modelLabel(model))
+    recordProviderResult(err)
+    if err != nil {
+        logger.Error("llm call failed", "latency_ms", latency.Milliseconds(), "error", err)
+        return Completion{Model: model}, err
+    }
 
-    reqPayload := CerebrasRequest{
-        Model:    model,
-        Messages: history,
+    metrics.Tokens.Add(float64(apiResp.Usage.PromptTokens), "prompt")
+    metrics.Tokens.Add(float64(apiResp.Usage.CompletionTokens), "completion")
+    logger.Info("llm call completed",
+        "latency_ms", latency.Milliseconds(),
+        "prompt_tokens", apiResp.Usage.PromptTokens,
+        "completion_tokens", apiResp.Usage.CompletionTokens,
+    )
+
+    completion := Completion{Model: model, Usage: apiResp.Usage, Content: "Sorry, the AI did not provide a response."}
+    if len(apiResp.Choices) > 0 {
+        completion.Content = apiResp.Choices[0].Message.Content
     }
+    return completion, nil
+}
 
+// cerebrasClient bounds each completion request, on top of the caller's ctx.
+var cerebrasClient = &http.Client{Timeout: 2 * time.Minute}
+
+// callCerebras performs a single chat completion request and counts failures by type.
+func callCerebras(ctx context.Context, url, apiKey string, reqPayload CerebrasRequest) (CerebrasResponse, error) {
+    var apiResp CerebrasResponse
     body, _ := json.Marshal(reqPayload)
     
-    req, err := http.NewRequest("POST", url, bytes.NewBuffer(body))
+    req, err := http.NewRequestWithContext(ctx, "POST", url, bytes.NewBuffer(body))
     if err != nil {
-        return "", fmt.Errorf("creating request: %w", err)
+        return apiResp, fmt.Errorf("creating request: %w", err)
     }
 
     req.Header.Set("Content-Type", "application/json")
     req.Header.Set("Authorization", "Bearer " + apiKey)
 
-    client := &http.Client{}
-    resp, err := client.Do(req)
+    resp, err := cerebrasClient.Do(req)
     if err != nil {
-        return "", fmt.Errorf("making API call: %w", err)
+        metrics.Errors.Inc("llm_request")
+        return apiResp, fmt.Errorf("making API call: %w", err)
     }
     defer resp.Body.Close()
 
     if resp.StatusCode != http.StatusOK {
+        if resp.StatusCode == http.StatusTooManyRequests {
+            metrics.RateLimitHits.Inc("provider")
+        }
+        metrics.Errors.Inc("llm_status")
         var errBody bytes.Buffer
         errBody.ReadFrom(resp.Body)
-        return "", fmt.Errorf("API call failed with status %d: %s", resp.StatusCode, errBody.String())
+        return apiResp, fmt.Errorf("API call failed with status %d: %s", resp.StatusCode, errBody.String())
     }
 
-    var apiResp CerebrasResponse
     if err := json.NewDecoder(resp.Body).Decode(&apiResp); err != nil {
-        return "", fmt.Errorf("decoding API response: %w", err)
-    }
-
-    if len(apiResp.Choices) == 0 {
-        return "Sorry, the AI did not provide a response.", nil
+        metrics.Errors.Inc("llm_decode")
+        return apiResp, fmt.Errorf("decoding API response: %w", err)
     }
-
-    return apiResp.Choices[0].Message.Content, nil
+    return apiResp, nil
 }
@@ -0,0 +1,34 @@
+package ai
+
+import "testing"
+
+func TestModelAllowed(t *testing.T) {
+	tests := []struct {
+		name       string
+		configured string
+		allowlist  string
+		model      string
+		want       bool
+	}{
+		{"default model without allowlist", "", "", DefaultModel, true},
+		{"configured model without allowlist", "qwen-3", "", "qwen-3", true},
+		{"other model without allowlist", "", "", "gpt-oss-120b", false},
+		{"allowlisted model", "", "gpt-oss-120b, qwen-3", "qwen-3", true},
+		{"model outside allowlist", "", "gpt-oss-120b", "qwen-3", false},
+		{"empty entries don't allow empty models", "", "a,,b", "", false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Setenv("CEREBRAS_MODEL", tt.configured)
+			t.Setenv("ASK_MODELS", tt.allowlist)
+			if got := ModelAllowed(tt.model); got != tt.want {
+				t.Errorf("ModelAllowed(%q) = %v, want %v", tt.model, got, tt.want)
+			}
+		})
+	}
+
+	t.Setenv("ASK_MODELS", "")
+	if got := modelLabel("made-up-model"); got != "other" {
+		t.Errorf("modelLabel of an unknown model = %q, want %q", got, "other")
+	}
+}
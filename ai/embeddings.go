@@ -0,0 +1,185 @@
+package ai
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"hash/fnv"
+	"math"
+	"net/http"
+	"os"
+	"strings"
+	"time"
+	"unicode"
+)
+
+// Embedder turns texts into vectors that can be compared with CosineSimilarity.
+// Embed gives up once ctx is done.
+type Embedder interface {
+	Embed(ctx context.Context, texts []string) ([][]float32, error)
+}
+
+// NewEmbedderFromEnv returns an HTTPEmbedder when EMBEDDINGS_URL is set and a
+// local HashEmbedder otherwise, so retrieval works without any extra provider.
+func NewEmbedderFromEnv() Embedder {
+	url := os.Getenv("EMBEDDINGS_URL")
+	if url == "" {
+		return HashEmbedder{}
+	}
+	apiKey := os.Getenv("EMBEDDINGS_API_KEY")
+	if apiKey == "" {
+		apiKey = os.Getenv("CEREBRAS_API_KEY")
+	}
+	return HTTPEmbedder{URL: url, Model: os.Getenv("EMBEDDINGS_MODEL"), APIKey: apiKey}
+}
+
+// --- PROVIDER EMBEDDINGS ---
+
+// embeddingsClient bounds each embeddings call. Embeddings are computed on
+// every message, so a hung endpoint must not hold up replies for long.
+var embeddingsClient = &http.Client{Timeout: 15 * time.Second}
+
+// HTTPEmbedder calls an OpenAI-compatible embeddings endpoint (POST {model, input}).
+type HTTPEmbedder struct {
+	URL    string
+	Model  string
+	APIKey string
+}
+
+// EmbeddingsRequest models the embeddings API request payload.
+type EmbeddingsRequest struct {
+	Model string   `json:"model,omitempty"`
+	Input []string `json:"input"`
+}
+
+// EmbeddingsResponse models the embeddings API response structure.
+type EmbeddingsResponse struct {
+	Data []struct {
+		Index     int       `json:"index"`
+		Embedding []float32 `json:"embedding"`
+	} `json:"data"`
+}
+
+// Embed sends the texts to the embeddings endpoint and returns one vector per text.
+func (e HTTPEmbedder) Embed(ctx context.Context, texts []string) ([][]float32, error) {
+	body, _ := json.Marshal(EmbeddingsRequest{Model: e.Model, Input: texts})
+
+	req, err := http.NewRequestWithContext(ctx, "POST", e.URL, bytes.NewBuffer(body))
+	if err != nil {
+		return nil, fmt.Errorf("creating request: %w", err)
+	}
+	req.Header.Set("Content-Type", "application/json")
+	if e.APIKey != "" {
+		req.Header.Set("Authorization", "Bearer "+e.APIKey)
+	}
+
+	resp, err := embeddingsClient.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("making embeddings call: %w", err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		var errBody bytes.Buffer
+		errBody.ReadFrom(resp.Body)
+		return nil, fmt.Errorf("embeddings call failed with status %d: %s", resp.StatusCode, errBody.String())
+	}
+
+	var apiResp EmbeddingsResponse
+	if err := json.NewDecoder(resp.Body).Decode(&apiResp); err != nil {
+		return nil, fmt.Errorf("decoding embeddings response: %w", err)
+	}
+	if len(apiResp.Data) != len(texts) {
+		return nil, fmt.Errorf("embeddings response has %d vectors for %d inputs", len(apiResp.Data), len(texts))
+	}
+
+	vectors := make([][]float32, len(texts))
+	for _, d := range apiResp.Data {
+		if d.Index < 0 || d.Index >= len(vectors) {
+			return nil, fmt.Errorf("embeddings response has out of range index %d", d.Index)
+		}
+		vectors[d.Index] = d.Embedding
+	}
+	return vectors, nil
+}
+
+// --- LOCAL EMBEDDINGS ---
+
+// hashDims is the vector size used when HashEmbedder.Dims is zero.
+const hashDims = 256
+
+// HashEmbedder is a deterministic, offline embedder based on feature hashing of
+// words and word pairs. It only captures lexical overlap, but needs no network
+// and always returns the same vector for the same text.
+type HashEmbedder struct {
+	Dims int
+}
+
+// Embed hashes each text into a normalized vector.
+func (e HashEmbedder) Embed(_ context.Context, texts []string) ([][]float32, error) {
+	dims := e.Dims
+	if dims <= 0 {
+		dims = hashDims
+	}
+
+	vectors := make([][]float32, len(texts))
+	for n, text := range texts {
+		vec := make([]float32, dims)
+		words := strings.FieldsFunc(strings.ToLower(text), func(r rune) bool {
+			return !unicode.IsLetter(r) && !unicode.IsDigit(r)
+		})
+		for idx, w := range words {
+			addFeature(vec, w, 1)
+			if idx > 0 {
+				addFeature(vec, words[idx-1]+" "+w, 0.5)
+			}
+		}
+		normalize(vec)
+		vectors[n] = vec
+	}
+	return vectors, nil
+}
+
+// addFeature adds weight to the bucket a feature hashes to, using one hash bit as the sign.
+func addFeature(vec []float32, feature string, weight float32) {
+	h := fnv.New64a()
+	h.Write([]byte(feature))
+	sum := h.Sum64()
+	if sum&(1<<63) != 0 {
+		weight = -weight
+	}
+	vec[sum%uint64(len(vec))] += weight
+}
+
+func normalize(vec []float32) {
+	var norm float64
+	for _, v := range vec {
+		norm += float64(v) * float64(v)
+	}
+	if norm == 0 {
+		return
+	}
+	scale := float32(1 / math.Sqrt(norm))
+	for idx := range vec {
+		vec[idx] *= scale
+	}
+}
+
+// CosineSimilarity returns the cosine of the angle between a and b, or 0 when
+// the vectors differ in length or either is all zeros.
+func CosineSimilarity(a, b []float32) float32 {
+	if len(a) != len(b) || len(a) == 0 {
+		return 0
+	}
+	var dot, normA, normB float64
+	for idx := range a {
+		dot += float64(a[idx]) * float64(b[idx])
+		normA += float64(a[idx]) * float64(a[idx])
+		normB += float64(b[idx]) * float64(b[idx])
+	}
+	if normA == 0 || normB == 0 {
+		return 0
+	}
+	return float32(dot / (math.Sqrt(normA) * math.Sqrt(normB)))
+}
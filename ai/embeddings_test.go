@@ -0,0 +1,60 @@
+package ai
+
+import (
+	"context"
+	"math"
+	"testing"
+)
+
+func TestHashEmbedder(t *testing.T) {
+	ctx := context.Background()
+	vectors, err := HashEmbedder{}.Embed(ctx, []string{
+		"reset the router password",
+		"Reset the ROUTER password!",
+		"carbonara with eggs",
+		"",
+	})
+	if err != nil {
+		t.Fatalf("Embed: %v", err)
+	}
+	if len(vectors) != 4 || len(vectors[0]) != hashDims {
+		t.Fatalf("Embed returned %d vectors of %d dims, want 4 of %d", len(vectors), len(vectors[0]), hashDims)
+	}
+
+	tests := []struct {
+		name     string
+		a, b     []float32
+		min, max float32
+	}{
+		{"same words, different case and punctuation", vectors[0], vectors[1], 0.999, 1.001},
+		{"unrelated texts", vectors[0], vectors[2], -0.3, 0.3},
+		{"empty text", vectors[0], vectors[3], 0, 0},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := CosineSimilarity(tt.a, tt.b); got < tt.min || got > tt.max {
+				t.Errorf("CosineSimilarity = %v, want between %v and %v", got, tt.min, tt.max)
+			}
+		})
+	}
+
+	again, _ := HashEmbedder{}.Embed(ctx, []string{"reset the router password"})
+	for idx := range again[0] {
+		if again[0][idx] != vectors[0][idx] {
+			t.Fatal("Embed is not deterministic")
+		}
+	}
+	var norm float64
+	for _, v := range vectors[0] {
+		norm += float64(v) * float64(v)
+	}
+	if math.Abs(norm-1) > 1e-5 {
+		t.Errorf("vector norm² = %v, want 1", norm)
+	}
+}
+
+func TestCosineSimilarityMismatchedLengths(t *testing.T) {
+	if got := CosineSimilarity([]float32{1, 0}, []float32{1}); got != 0 {
+		t.Errorf("CosineSimilarity of mismatched lengths = %v, want 0", got)
+	}
+}
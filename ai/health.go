@@ -0,0 +1,39 @@
+package ai
+
+import (
+	"fmt"
+	"sync"
+	"time"
+)
+
+// Provider call outcomes, used by the readiness probe.
+var (
+	providerMu      sync.Mutex
+	lastSuccess     time.Time
+	lastFailure     time.Time
+	lastFailureText string
+)
+
+func recordProviderResult(err error) {
+	providerMu.Lock()
+	defer providerMu.Unlock()
+	if err != nil {
+		lastFailure = time.Now()
+		lastFailureText = err.Error()
+		return
+	}
+	lastSuccess = time.Now()
+}
+
+// ProviderHealthy reports whether the LLM provider looks reachable: no call has
+// failed yet, the latest call succeeded, or a call succeeded within window.
+// When unhealthy, the returned error describes the latest failure.
+func ProviderHealthy(window time.Duration) error {
+	providerMu.Lock()
+	defer providerMu.Unlock()
+
+	if lastFailure.IsZero() || lastSuccess.After(lastFailure) || time.Since(lastSuccess) < window {
+		return nil
+	}
+	return fmt.Errorf("last provider call failed %s ago: %s", time.Since(lastFailure).Round(time.Second), lastFailureText)
+}
@@ -0,0 +1,83 @@
+package main
+
+import (
+	"context"
+	"log/slog"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"discord-ai-bot/db"
+)
+
+// defaultBackupKeep is how many scheduled backups are kept. Override with BACKUP_KEEP.
+const defaultBackupKeep = 7
+
+// scheduleBackups writes a backup to BACKUP_DIR (default "backups") every
+// BACKUP_INTERVAL until ctx is cancelled, keeping the newest BACKUP_KEEP files.
+// It does nothing when BACKUP_INTERVAL is unset.
+func scheduleBackups(ctx context.Context, store db.Store, dbPath string) {
+	interval, err := time.ParseDuration(os.Getenv("BACKUP_INTERVAL"))
+	if err != nil || interval <= 0 {
+		return
+	}
+	dir := os.Getenv("BACKUP_DIR")
+	if dir == "" {
+		dir = "backups"
+	}
+	keep := defaultBackupKeep
+	if n, err := strconv.Atoi(os.Getenv("BACKUP_KEEP")); err == nil && n > 0 {
+		keep = n
+	}
+	if err := os.MkdirAll(dir, 0700); err != nil {
+		slog.Error("scheduled backups disabled: creating backup directory failed", "dir", dir, "error", err)
+		return
+	}
+	slog.Info("scheduled backups enabled", "interval", interval.String(), "dir", dir, "keep", keep)
+
+	prefix := filepath.Base(dbPath) + ".scheduled-"
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+
+		path := filepath.Join(dir, prefix+time.Now().UTC().Format("20060102T150405Z"))
+		n, err := db.BackupToFile(store, path)
+		if err != nil {
+			slog.Error("scheduled backup failed", "path", path, "error", err)
+			continue
+		}
+		slog.Info("scheduled backup written", "path", path, "bytes", n)
+		pruneBackups(dir, prefix, keep)
+	}
+}
+
+// pruneBackups deletes all but the newest keep backups starting with prefix.
+// The UTC timestamp in the name makes lexical order chronological.
+func pruneBackups(dir, prefix string, keep int) {
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		slog.Warn("listing backups failed", "dir", dir, "error", err)
+		return
+	}
+	var names []string
+	for _, e := range entries {
+		if strings.HasPrefix(e.Name(), prefix) && !strings.HasSuffix(e.Name(), ".tmp") {
+			names = append(names, e.Name())
+		}
+	}
+	sort.Strings(names)
+	for len(names) > keep {
+		if err := os.Remove(filepath.Join(dir, names[0])); err != nil {
+			slog.Warn("deleting old backup failed", "path", names[0], "error", err)
+		}
+		names = names[1:]
+	}
+}
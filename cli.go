@@ -0,0 +1,233 @@
+package main
+
+import (
+	"encoding/json"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"text/tabwriter"
+	"time"
+
+	"discord-ai-bot/db"
+)
+
+const cliUsage = `Usage: discord-ai-bot [command] [flags]
+
+Commands:
+  run       Start the bot (default)
+  backup    Write a consistent snapshot of the database file (bolt: stop the bot first)
+  restore   Replace the database file with a backup (stop the bot first)
+  export    Write every bucket and key as JSON
+  import    Merge a JSON export into the database
+  inspect   List buckets, keys and value sizes
+  feedback  Write the 👍/👎 ratings of AI replies as JSON lines
+
+Every command except run accepts -db (default $DB_PATH or bot_memory.db) and
+-backend (default $DB_BACKEND or bolt). Run "discord-ai-bot <command> -h" for
+the command's own flags.
+
+backup, export, inspect and feedback open the file read-only and never
+migrate it. A running bot holds an exclusive lock on a bolt file, so these
+commands fail with "file is locked" until it stops; the bot takes its own
+backups with BACKUP_INTERVAL. SQLite files can be read while the bot runs.
+`
+
+// runCommand runs a maintenance subcommand and returns the process exit code.
+func runCommand(name string, args []string) int {
+	fs := flag.NewFlagSet(name, flag.ContinueOnError)
+	dbPath := fs.String("db", defaultDBPath(), "database file")
+	backend := fs.String("backend", os.Getenv("DB_BACKEND"), "storage backend (bolt or sqlite)")
+
+	var run func() error
+	switch name {
+	case "backup":
+		out := fs.String("o", "", "output file, - for stdout (default <db>.backup-<timestamp>)")
+		run = func() error { return cmdBackup(*backend, *dbPath, *out) }
+	case "restore":
+		from := fs.String("from", "", "backup file to restore (required)")
+		run = func() error { return cmdRestore(*backend, *dbPath, *from) }
+	case "export":
+		out := fs.String("o", "-", "output file, - for stdout")
+		run = func() error { return cmdExport(*backend, *dbPath, *out) }
+	case "import":
+		from := fs.String("from", "-", "export file to import, - for stdin")
+		run = func() error { return cmdImport(*backend, *dbPath, *from) }
+	case "inspect":
+		keys := fs.Bool("keys", false, "list every key with its size")
+		run = func() error { return cmdInspect(*backend, *dbPath, *keys) }
+	case "feedback":
+		out := fs.String("o", "-", "output file, - for stdout")
+		rating := fs.String("rating", "", "only export this rating (up or down)")
+		run = func() error { return cmdFeedback(*backend, *dbPath, *out, *rating) }
+	case "help", "-h", "-help", "--help":
+		fmt.Print(cliUsage)
+		return 0
+	default:
+		fmt.Fprintf(os.Stderr, "unknown command %q\n\n%s", name, cliUsage)
+		return 2
+	}
+
+	if err := fs.Parse(args); err != nil {
+		return 2
+	}
+	if err := run(); err != nil {
+		fmt.Fprintf(os.Stderr, "%s: %v\n", name, err)
+		return 1
+	}
+	return 0
+}
+
+func defaultDBPath() string {
+	if path := os.Getenv("DB_PATH"); path != "" {
+		return path
+	}
+	return "bot_memory.db"
+}
+
+// withStore opens the database read-only for a command and closes it
+// afterwards. The file is left exactly as it is, even if its schema is old.
+func withStore(backend, dbPath string, fn func(store db.Store) error) error {
+	if backend == db.BackendMemory {
+		return fmt.Errorf("the memory backend has nothing on disk to work with")
+	}
+	if _, err := os.Stat(dbPath); err != nil {
+		return err
+	}
+	store, err := db.OpenReadOnly(backend, dbPath)
+	if err != nil {
+		return err
+	}
+	if err := fn(store); err != nil {
+		store.Close()
+		return err
+	}
+	return store.Close()
+}
+
+func cmdBackup(backend, dbPath, out string) error {
+	return withStore(backend, dbPath, func(store db.Store) error {
+		if out == "-" {
+			_, err := store.Backup(os.Stdout)
+			return err
+		}
+		if out == "" {
+			out = fmt.Sprintf("%s.backup-%s", dbPath, time.Now().UTC().Format("20060102T150405Z"))
+		}
+		n, err := db.BackupToFile(store, out)
+		if err != nil {
+			return err
+		}
+		fmt.Fprintf(os.Stderr, "wrote %d bytes to %s\n", n, out)
+		return nil
+	})
+}
+
+func cmdRestore(backend, dbPath, from string) error {
+	if from == "" {
+		return fmt.Errorf("-from is required")
+	}
+	previous, err := db.Restore(backend, from, dbPath)
+	if err != nil {
+		return err
+	}
+	if previous != "" {
+		fmt.Fprintf(os.Stderr, "previous database kept as %s\n", previous)
+	}
+	fmt.Fprintf(os.Stderr, "restored %s from %s\n", dbPath, from)
+	return nil
+}
+
+func cmdExport(backend, dbPath, out string) error {
+	return withStore(backend, dbPath, func(store db.Store) error {
+		if out == "-" {
+			return store.Export(os.Stdout)
+		}
+		f, err := os.OpenFile(out, os.O_WRONLY|os.O_CREATE|os.O_TRUNC, 0600)
+		if err != nil {
+			return err
+		}
+		if err := store.Export(f); err != nil {
+			f.Close()
+			return err
+		}
+		return f.Close()
+	})
+}
+
+func cmdImport(backend, dbPath, from string) error {
+	var r io.Reader = os.Stdin
+	if from != "-" {
+		f, err := os.Open(from)
+		if err != nil {
+			return err
+		}
+		defer f.Close()
+		r = f
+	}
+	// Importing into a fresh file is allowed, so don't require it to exist.
+	store, err := db.Open(backend, dbPath)
+	if err != nil {
+		return err
+	}
+	if err := store.Import(r); err != nil {
+		store.Close()
+		return err
+	}
+	fmt.Fprintf(os.Stderr, "imported into %s\n", dbPath)
+	return store.Close()
+}
+
+func cmdFeedback(backend, dbPath, out, rating string) error {
+	if rating != "" && rating != db.RatingUp && rating != db.RatingDown {
+		return fmt.Errorf("-rating must be %q or %q", db.RatingUp, db.RatingDown)
+	}
+	return withStore(backend, dbPath, func(store db.Store) error {
+		all, err := store.LoadFeedback()
+		if err != nil {
+			return err
+		}
+		w := io.Writer(os.Stdout)
+		if out != "-" {
+			f, err := os.OpenFile(out, os.O_WRONLY|os.O_CREATE|os.O_TRUNC, 0600)
+			if err != nil {
+				return err
+			}
+			defer f.Close()
+			w = f
+		}
+		enc := json.NewEncoder(w)
+		n := 0
+		for _, fb := range all {
+			if rating != "" && fb.Rating != rating {
+				continue
+			}
+			if err := enc.Encode(fb); err != nil {
+				return err
+			}
+			n++
+		}
+		fmt.Fprintf(os.Stderr, "exported %d rating(s)\n", n)
+		return nil
+	})
+}
+
+func cmdInspect(backend, dbPath string, showKeys bool) error {
+	return withStore(backend, dbPath, func(store db.Store) error {
+		buckets, err := store.Inspect()
+		if err != nil {
+			return err
+		}
+		w := tabwriter.NewWriter(os.Stdout, 0, 4, 2, ' ', 0)
+		fmt.Fprintln(w, "BUCKET\tKEYS\tBYTES\tSEQUENCE")
+		for _, b := range buckets {
+			fmt.Fprintf(w, "%s\t%d\t%d\t%d\n", b.Path, len(b.Keys), b.Bytes(), b.Sequence)
+			if showKeys {
+				for _, k := range b.Keys {
+					fmt.Fprintf(w, "  %s\t\t%d\t\n", k.DisplayKey(), k.Size)
+				}
+			}
+		}
+		return w.Flush()
+	})
+}
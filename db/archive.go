@@ -0,0 +1,88 @@
+package db
+
+import (
+	"encoding/binary"
+	"encoding/json"
+	"sort"
+	"time"
+
+	"discord-ai-bot/ai"
+)
+
+// archiveBucket holds exchanges that have scrolled out of the rolling history,
+// together with their embeddings, so they can still be retrieved by similarity.
+const archiveBucket = "archived_exchanges"
+
+// ArchivedExchange is one user question and the bot's answer, with its embedding.
+type ArchivedExchange struct {
+	Question  string    `json:"question"`
+	Answer    string    `json:"answer"`
+	Vector    []float32 `json:"vector"`
+	CreatedAt time.Time `json:"created_at"`
+	// Scope and UserID say where the exchange came from and who asked, for
+	// retention and privacy deletion. Older entries leave them empty.
+	Scope  string `json:"scope,omitempty"`
+	UserID string `json:"user_id,omitempty"`
+}
+
+// ScoredExchange is an ArchivedExchange returned from a similarity search.
+type ScoredExchange struct {
+	ArchivedExchange
+	Score float32
+}
+
+// ArchiveExchanges appends exchanges to the archive in a single transaction.
+func (s *kvStore) ArchiveExchanges(exchanges []ArchivedExchange) error {
+	return s.kv.Update(func(tx kvTx) error {
+		for _, ex := range exchanges {
+			id, err := tx.NextSequence(archiveBucket)
+			if err != nil {
+				return err
+			}
+			if err := putJSON(tx, archiveBucket, string(itob(id)), ex); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+}
+
+// SearchArchive returns up to k archived exchanges from scope most similar to vector,
+// skipping anything scoring below minScore. It is a brute-force scan, which is
+// fine for a single bot's history.
+func (s *kvStore) SearchArchive(scope string, vector []float32, k int, minScore float32) ([]ScoredExchange, error) {
+	var results []ScoredExchange
+	err := s.kv.View(func(tx kvTx) error {
+		return tx.Scan(archiveBucket, "", func(_ string, v []byte) error {
+			var ex ArchivedExchange
+			if err := json.Unmarshal(v, &ex); err != nil {
+				return nil // Skip corrupt entries rather than failing the whole search
+			}
+			if ex.scope() != scope {
+				return nil
+			}
+			score := ai.CosineSimilarity(vector, ex.Vector)
+			if score < minScore {
+				return nil
+			}
+			results = append(results, ScoredExchange{ArchivedExchange: ex, Score: score})
+			return nil
+		})
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	sort.Slice(results, func(a, b int) bool { return results[a].Score > results[b].Score })
+	if len(results) > k {
+		results = results[:k]
+	}
+	return results, nil
+}
+
+// itob encodes a sequence number as a big-endian key so bolt keeps them ordered.
+func itob(v uint64) []byte {
+	b := make([]byte, 8)
+	binary.BigEndian.PutUint64(b, v)
+	return b
+}
@@ -0,0 +1,559 @@
+package db
+
+import (
+	"bytes"
+	"database/sql"
+	"encoding/base64"
+	"encoding/binary"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+	"unicode"
+	"unicode/utf8"
+
+	bolt "github.com/boltdb/bolt"
+)
+
+// ErrBackupUnsupported is returned by Backup for stores that have no file to
+// snapshot. Export works for every backend.
+var ErrBackupUnsupported = errors.New("this storage backend does not support backups, use export instead")
+
+// snapshotter is implemented by backends that can write a consistent copy of
+// their file while it stays open.
+type snapshotter interface {
+	WriteSnapshot(w io.Writer) (int64, error)
+}
+
+// WriteSnapshot copies the file inside one read transaction, so writers keep
+// going and the copy is a consistent point in time.
+func (b *boltKV) WriteSnapshot(w io.Writer) (int64, error) {
+	var n int64
+	err := b.db.View(func(tx *bolt.Tx) error {
+		var err error
+		n, err = tx.WriteTo(w)
+		return err
+	})
+	return n, err
+}
+
+// WriteSnapshot uses VACUUM INTO, which produces a compact standalone file.
+func (s *sqliteKV) WriteSnapshot(w io.Writer) (int64, error) {
+	dir, err := os.MkdirTemp("", "bot-snapshot-")
+	if err != nil {
+		return 0, err
+	}
+	defer os.RemoveAll(dir)
+
+	path := filepath.Join(dir, "snapshot.db")
+	if _, err := s.db.Exec(`VACUUM INTO ?`, path); err != nil {
+		return 0, fmt.Errorf("vacuum into snapshot: %w", err)
+	}
+	f, err := os.Open(path)
+	if err != nil {
+		return 0, err
+	}
+	defer f.Close()
+	return io.Copy(w, f)
+}
+
+// Backup writes a consistent snapshot of the database file to w.
+func (s *kvStore) Backup(w io.Writer) (int64, error) {
+	snap, ok := s.kv.(snapshotter)
+	if !ok {
+		return 0, ErrBackupUnsupported
+	}
+	return snap.WriteSnapshot(w)
+}
+
+// BackupToFile writes a snapshot to path, via a temporary file so a crash
+// never leaves a truncated backup under the final name.
+func BackupToFile(store Store, path string) (int64, error) {
+	tmp := path + ".tmp"
+	f, err := os.OpenFile(tmp, os.O_WRONLY|os.O_CREATE|os.O_TRUNC, 0600)
+	if err != nil {
+		return 0, err
+	}
+	n, err := store.Backup(f)
+	if err == nil {
+		err = f.Sync()
+	}
+	if closeErr := f.Close(); err == nil {
+		err = closeErr
+	}
+	if err == nil {
+		err = os.Rename(tmp, path)
+	}
+	if err != nil {
+		os.Remove(tmp)
+		return 0, err
+	}
+	return n, nil
+}
+
+// Restore replaces the database file at path with the backup at backupPath.
+// The bot must not be running. The backup is checked first and the current
+// file is kept next to it; the name it was moved to is returned ("" if there
+// was no file).
+func Restore(backend, backupPath, path string) (string, error) {
+	if err := verifyBackup(backend, backupPath); err != nil {
+		return "", fmt.Errorf("checking backup %s: %w", backupPath, err)
+	}
+
+	var previous string
+	if _, err := os.Stat(path); err == nil {
+		// Opening the current file makes sure nothing else holds it and, for
+		// SQLite, folds the write-ahead log back into the main file.
+		kv, err := openRaw(backend, path)
+		if err != nil {
+			return "", err
+		}
+		if err := kv.Close(); err != nil {
+			return "", err
+		}
+		// The log was just checkpointed; a leftover one must not be replayed
+		// into the restored file.
+		if backend == BackendSQLite {
+			os.Remove(path + "-wal")
+			os.Remove(path + "-shm")
+		}
+		previous = fmt.Sprintf("%s.pre-restore-%s", path, time.Now().UTC().Format("20060102T150405Z"))
+		if err := os.Rename(path, previous); err != nil {
+			return "", err
+		}
+	}
+
+	tmp := path + ".restore-tmp"
+	if err := copyFile(backupPath, tmp); err != nil {
+		os.Remove(tmp)
+		return previous, err
+	}
+	return previous, os.Rename(tmp, path)
+}
+
+// openRaw opens a backend's file without migrating it.
+func openRaw(backend, path string) (kvBackend, error) {
+	switch backend {
+	case "", BackendBolt:
+		db, err := bolt.Open(path, 0600, &bolt.Options{Timeout: boltLockTimeout})
+		if err == bolt.ErrTimeout {
+			return nil, fmt.Errorf("%s is locked by another process (is the bot running?)", path)
+		}
+		if err != nil {
+			return nil, err
+		}
+		return &boltKV{db: db}, nil
+	case BackendSQLite:
+		kv, err := openSQLite(path)
+		if err != nil {
+			return nil, err
+		}
+		if _, err := kv.db.Exec(`PRAGMA wal_checkpoint(TRUNCATE)`); err != nil {
+			kv.Close()
+			return nil, err
+		}
+		return kv, nil
+	default:
+		return nil, ErrBackupUnsupported
+	}
+}
+
+// verifyBackup opens a backup read-only and runs the backend's integrity check.
+func verifyBackup(backend, path string) error {
+	switch backend {
+	case "", BackendBolt:
+		db, err := bolt.Open(path, 0600, &bolt.Options{ReadOnly: true, Timeout: boltLockTimeout})
+		if err != nil {
+			return err
+		}
+		defer db.Close()
+		return db.View(func(tx *bolt.Tx) error {
+			for err := range tx.Check() {
+				return err
+			}
+			if v := readSchemaVersion(tx); v > latestSchemaVersion() {
+				return fmt.Errorf("schema version %d is newer than this binary supports (%d)", v, latestSchemaVersion())
+			}
+			return nil
+		})
+	case BackendSQLite:
+		db, err := sql.Open("sqlite", "file:"+path+"?mode=ro")
+		if err != nil {
+			return err
+		}
+		defer db.Close()
+		var result string
+		if err := db.QueryRow(`PRAGMA integrity_check`).Scan(&result); err != nil {
+			return err
+		}
+		if result != "ok" {
+			return fmt.Errorf("integrity check: %s", result)
+		}
+		var n int
+		return db.QueryRow(`SELECT count(*) FROM kv`).Scan(&n)
+	default:
+		return ErrBackupUnsupported
+	}
+}
+
+func copyFile(src, dst string) error {
+	in, err := os.Open(src)
+	if err != nil {
+		return err
+	}
+	defer in.Close()
+	out, err := os.OpenFile(dst, os.O_WRONLY|os.O_CREATE|os.O_TRUNC, 0600)
+	if err != nil {
+		return err
+	}
+	if _, err := io.Copy(out, in); err != nil {
+		out.Close()
+		return err
+	}
+	if err := out.Sync(); err != nil {
+		out.Close()
+		return err
+	}
+	return out.Close()
+}
+
+// --- EXPORT / IMPORT ---
+
+// exportFormat identifies export files.
+const exportFormat = "discord-ai-bot-export"
+
+// Export is the JSON document written by Store.Export.
+type Export struct {
+	Format        string         `json:"format"`
+	SchemaVersion int            `json:"schema_version"`
+	ExportedAt    time.Time      `json:"exported_at"`
+	Buckets       []ExportBucket `json:"buckets"`
+}
+
+// ExportBucket is one bucket, named by its "/"-separated path.
+type ExportBucket struct {
+	Path     string        `json:"path"`
+	Sequence uint64        `json:"sequence,omitempty"`
+	Entries  []ExportEntry `json:"entries"`
+}
+
+// ExportEntry is one key/value pair. Values that are stored as compact JSON
+// are embedded as-is; other values are a JSON string ("text") or base64.
+// Keys that aren't printable text (sequence-numbered keys) are base64 too.
+type ExportEntry struct {
+	Key           string          `json:"key"`
+	KeyEncoding   string          `json:"key_encoding,omitempty"`
+	Value         json.RawMessage `json:"value"`
+	ValueEncoding string          `json:"value_encoding,omitempty"`
+}
+
+// Export writes every bucket and key as a JSON document, stamped with the
+// schema version the data is actually in.
+func (s *kvStore) Export(w io.Writer) error {
+	doc := Export{Format: exportFormat, ExportedAt: time.Now().UTC()}
+	err := s.kv.View(func(tx kvTx) error {
+		var err error
+		if doc.SchemaVersion, err = storedSchemaVersion(tx); err != nil {
+			return err
+		}
+		return walkBuckets(tx, "", func(path string) error {
+			bucket := ExportBucket{Path: path, Entries: []ExportEntry{}}
+			var err error
+			if bucket.Sequence, err = tx.Sequence(path); err != nil {
+				return err
+			}
+			err = tx.Scan(path, "", func(key string, value []byte) error {
+				bucket.Entries = append(bucket.Entries, encodeEntry(key, value))
+				return nil
+			})
+			if err != nil {
+				return err
+			}
+			doc.Buckets = append(doc.Buckets, bucket)
+			return nil
+		})
+	})
+	if err != nil {
+		return err
+	}
+
+	enc := json.NewEncoder(w)
+	enc.SetIndent("", "  ")
+	return enc.Encode(doc)
+}
+
+// Import writes every key in an export document in one transaction,
+// overwriting keys that already exist and leaving all others alone. Exports
+// from an older schema version are migrated first.
+func (s *kvStore) Import(r io.Reader) error {
+	var doc Export
+	if err := json.NewDecoder(r).Decode(&doc); err != nil {
+		return fmt.Errorf("reading export: %w", err)
+	}
+	if doc.Format != exportFormat {
+		return fmt.Errorf("not an export file (format %q)", doc.Format)
+	}
+	if doc.SchemaVersion > latestSchemaVersion() {
+		return fmt.Errorf("export schema version %d is newer than this binary supports (%d)", doc.SchemaVersion, latestSchemaVersion())
+	}
+
+	if doc.SchemaVersion < latestSchemaVersion() {
+		migrated, err := migrateExport(doc)
+		if err != nil {
+			return fmt.Errorf("migrating export from schema version %d: %w", doc.SchemaVersion, err)
+		}
+		doc = migrated
+	}
+	return s.kv.Update(func(tx kvTx) error { return importBuckets(tx, doc.Buckets) })
+}
+
+// importBuckets writes the entries and sequences of buckets. The meta bucket
+// is skipped: the schema version and health check data belong to the store
+// being written, not to the export.
+func importBuckets(tx kvTx, buckets []ExportBucket) error {
+	for _, bucket := range buckets {
+		if bucket.Path == metaBucket || strings.HasPrefix(bucket.Path, metaBucket+"/") {
+			continue
+		}
+		for _, entry := range bucket.Entries {
+			key, value, err := decodeEntry(entry)
+			if err != nil {
+				return fmt.Errorf("bucket %s: %w", bucket.Path, err)
+			}
+			if err := tx.Put(bucket.Path, key, value); err != nil {
+				return err
+			}
+		}
+		if bucket.Sequence > 0 {
+			current, err := tx.Sequence(bucket.Path)
+			if err != nil {
+				return err
+			}
+			// Never move a counter backwards, or new IDs would collide.
+			if bucket.Sequence > current {
+				if err := tx.SetSequence(bucket.Path, bucket.Sequence); err != nil {
+					return err
+				}
+			}
+		}
+	}
+	return nil
+}
+
+// storedSchemaVersion is the schema version of the data in a store. Bolt
+// files record it in the meta bucket, except files from before versioning,
+// which have the legacy conversations bucket instead. The other backends
+// always start with the current layout.
+func storedSchemaVersion(tx kvTx) (int, error) {
+	data, err := tx.Get(metaBucket, schemaVersionKey)
+	if err != nil {
+		return 0, err
+	}
+	if len(data) == 8 {
+		return int(binary.BigEndian.Uint64(data)), nil
+	}
+	names, err := tx.Buckets("")
+	if err != nil {
+		return 0, err
+	}
+	for _, name := range names {
+		if name == legacyConversationBucket {
+			return 0, nil
+		}
+	}
+	return latestSchemaVersion(), nil
+}
+
+// migrateExport upgrades an export from an older schema version by loading
+// it into a scratch bolt file, running the migrations there and exporting the
+// result again.
+func migrateExport(doc Export) (Export, error) {
+	dir, err := os.MkdirTemp("", "bot-import-")
+	if err != nil {
+		return Export{}, err
+	}
+	defer os.RemoveAll(dir)
+	path := filepath.Join(dir, "import.db")
+
+	raw, err := bolt.Open(path, 0600, nil)
+	if err != nil {
+		return Export{}, err
+	}
+	err = raw.Update(func(tx *bolt.Tx) error {
+		if err := importBuckets(boltTx{tx}, doc.Buckets); err != nil {
+			return err
+		}
+		return writeSchemaVersion(tx, doc.SchemaVersion)
+	})
+	raw.Close()
+	if err != nil {
+		return Export{}, err
+	}
+
+	store, err := Open(BackendBolt, path)
+	if err != nil {
+		return Export{}, err
+	}
+	defer store.Close()
+	var buf bytes.Buffer
+	if err := store.Export(&buf); err != nil {
+		return Export{}, err
+	}
+	var migrated Export
+	if err := json.Unmarshal(buf.Bytes(), &migrated); err != nil {
+		return Export{}, err
+	}
+	return migrated, nil
+}
+
+func encodeEntry(key string, value []byte) ExportEntry {
+	entry := ExportEntry{Key: key}
+	if !printable(key) {
+		entry.Key = base64.StdEncoding.EncodeToString([]byte(key))
+		entry.KeyEncoding = "base64"
+	}
+
+	switch {
+	case isCompactJSON(value):
+		entry.Value = value
+	case utf8.Valid(value):
+		entry.Value, _ = json.Marshal(string(value))
+		entry.ValueEncoding = "text"
+	default:
+		entry.Value, _ = json.Marshal(base64.StdEncoding.EncodeToString(value))
+		entry.ValueEncoding = "base64"
+	}
+	return entry
+}
+
+func decodeEntry(entry ExportEntry) (string, []byte, error) {
+	key := entry.Key
+	switch entry.KeyEncoding {
+	case "":
+	case "base64":
+		raw, err := base64.StdEncoding.DecodeString(entry.Key)
+		if err != nil {
+			return "", nil, fmt.Errorf("key %q: %w", entry.Key, err)
+		}
+		key = string(raw)
+	default:
+		return "", nil, fmt.Errorf("key %q: unknown encoding %q", entry.Key, entry.KeyEncoding)
+	}
+
+	switch entry.ValueEncoding {
+	case "":
+		var compact bytes.Buffer
+		if err := json.Compact(&compact, entry.Value); err != nil {
+			return "", nil, fmt.Errorf("key %q: %w", entry.Key, err)
+		}
+		return key, compact.Bytes(), nil
+	case "text", "base64":
+		var text string
+		if err := json.Unmarshal(entry.Value, &text); err != nil {
+			return "", nil, fmt.Errorf("key %q: %w", entry.Key, err)
+		}
+		if entry.ValueEncoding == "text" {
+			return key, []byte(text), nil
+		}
+		raw, err := base64.StdEncoding.DecodeString(text)
+		if err != nil {
+			return "", nil, fmt.Errorf("key %q: %w", entry.Key, err)
+		}
+		return key, raw, nil
+	default:
+		return "", nil, fmt.Errorf("key %q: unknown value encoding %q", entry.Key, entry.ValueEncoding)
+	}
+}
+
+// isCompactJSON reports whether value is a JSON object or array exactly as
+// json.Marshal writes it, so exporting and importing it round-trips byte for byte.
+func isCompactJSON(value []byte) bool {
+	if len(value) == 0 || (value[0] != '{' && value[0] != '[') {
+		return false
+	}
+	var compact bytes.Buffer
+	return json.Compact(&compact, value) == nil && bytes.Equal(compact.Bytes(), value)
+}
+
+// printable reports whether a key can be shown and exported as plain text.
+func printable(s string) bool {
+	return utf8.ValidString(s) && strings.IndexFunc(s, func(r rune) bool { return !unicode.IsPrint(r) }) < 0
+}
+
+// --- INSPECT ---
+
+// BucketInfo summarises one bucket for inspection.
+type BucketInfo struct {
+	Path     string
+	Sequence uint64
+	Keys     []KeyInfo
+}
+
+// KeyInfo is a key and the size of its value in bytes.
+type KeyInfo struct {
+	Key  string
+	Size int
+}
+
+// Bytes returns the total size of the bucket's values.
+func (b BucketInfo) Bytes() int {
+	total := 0
+	for _, k := range b.Keys {
+		total += k.Size
+	}
+	return total
+}
+
+// DisplayKey renders a key for humans, decoding sequence-numbered keys.
+func (k KeyInfo) DisplayKey() string {
+	if len(k.Key) == 8 && !printable(k.Key) {
+		return fmt.Sprintf("#%d", binary.BigEndian.Uint64([]byte(k.Key)))
+	}
+	return fmt.Sprintf("%q", k.Key)
+}
+
+// Inspect lists every bucket with its keys and value sizes.
+func (s *kvStore) Inspect() ([]BucketInfo, error) {
+	var buckets []BucketInfo
+	err := s.kv.View(func(tx kvTx) error {
+		return walkBuckets(tx, "", func(path string) error {
+			info := BucketInfo{Path: path}
+			var err error
+			if info.Sequence, err = tx.Sequence(path); err != nil {
+				return err
+			}
+			err = tx.Scan(path, "", func(key string, value []byte) error {
+				info.Keys = append(info.Keys, KeyInfo{Key: key, Size: len(value)})
+				return nil
+			})
+			buckets = append(buckets, info)
+			return err
+		})
+	})
+	return buckets, err
+}
+
+// walkBuckets calls fn for every bucket under parent, parents before children.
+func walkBuckets(tx kvTx, parent string, fn func(path string) error) error {
+	names, err := tx.Buckets(parent)
+	if err != nil {
+		return err
+	}
+	for _, name := range names {
+		path := name
+		if parent != "" {
+			path = bucketPath(parent, name)
+		}
+		if err := fn(path); err != nil {
+			return err
+		}
+		if err := walkBuckets(tx, path, fn); err != nil {
+			return err
+		}
+	}
+	return nil
+}
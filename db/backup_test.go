@@ -0,0 +1,142 @@
+package db
+
+import (
+	"bytes"
+	"encoding/json"
+	"path/filepath"
+	"reflect"
+	"testing"
+	"time"
+)
+
+// exportOf exports store and decodes the document.
+func exportOf(t *testing.T, store Store) Export {
+	t.Helper()
+	var buf bytes.Buffer
+	if err := store.Export(&buf); err != nil {
+		t.Fatalf("Export: %v", err)
+	}
+	var doc Export
+	if err := json.Unmarshal(buf.Bytes(), &doc); err != nil {
+		t.Fatalf("decoding export: %v", err)
+	}
+	return doc
+}
+
+// dataBuckets drops the meta bucket and empty buckets, which differ between
+// backends and aren't carried over by Import.
+func dataBuckets(doc Export) map[string]ExportBucket {
+	buckets := make(map[string]ExportBucket)
+	for _, b := range doc.Buckets {
+		if b.Path != metaBucket && len(b.Entries) > 0 {
+			buckets[b.Path] = b
+		}
+	}
+	return buckets
+}
+
+func TestExportImportRoundTrip(t *testing.T) {
+	forEachBackend(t, func(t *testing.T, store Store) {
+		now := time.Now().UTC().Truncate(time.Second)
+		mustDo(t, store.SaveHistory(GlobalScope, []Turn{turn("user", "hi", "1", now), turn("assistant", "hello", "1", now)}))
+		mustDo(t, store.SavePersonality("You are a pirate."))
+		mustDo(t, store.AddUserFact("1", "likes tea"))
+		mustDo(t, store.AddUserFact("1", "uses Arch"))
+		mustDo(t, store.ArchiveExchanges([]ArchivedExchange{{Question: "q", Answer: "a", Vector: []float32{1, 0}}}))
+		mustDo(t, store.RecordUsage("g", "1", 10, 5))
+		mustDo(t, store.Ping())
+
+		doc := exportOf(t, store)
+		if doc.SchemaVersion != latestSchemaVersion() {
+			t.Errorf("export schema version = %d, want %d", doc.SchemaVersion, latestSchemaVersion())
+		}
+
+		for _, backend := range []string{BackendMemory, BackendBolt, BackendSQLite} {
+			t.Run("into "+backend, func(t *testing.T) {
+				target, err := Open(backend, filepath.Join(t.TempDir(), "bot.db"))
+				if err != nil {
+					t.Fatalf("Open(%q): %v", backend, err)
+				}
+				defer target.Close()
+				var buf bytes.Buffer
+				json.NewEncoder(&buf).Encode(doc)
+				if err := target.Import(&buf); err != nil {
+					t.Fatalf("Import: %v", err)
+				}
+
+				if got, want := dataBuckets(exportOf(t, target)), dataBuckets(doc); !reflect.DeepEqual(got, want) {
+					t.Errorf("re-exported buckets = %+v, want %+v", got, want)
+				}
+				if personality, _ := target.LoadPersonality(); personality != "You are a pirate." {
+					t.Errorf("personality after Import = %q", personality)
+				}
+				// Sequences were restored, so new facts don't reuse IDs
+				mustDo(t, target.AddUserFact("1", "new"))
+				facts, _ := target.LoadUserFacts("1")
+				if len(facts) != 3 || facts[2].ID <= facts[1].ID {
+					t.Errorf("facts after Import and add = %+v, want 3 with increasing IDs", facts)
+				}
+			})
+		}
+	})
+}
+
+func TestImportSkipsMeta(t *testing.T) {
+	forEachBackend(t, func(t *testing.T, store Store) {
+		doc := Export{Format: exportFormat, SchemaVersion: latestSchemaVersion(), Buckets: []ExportBucket{
+			{Path: metaBucket, Entries: []ExportEntry{encodeEntry(schemaVersionKey, itob(1)), encodeEntry("last_ping", []byte("then"))}},
+		}}
+		var buf bytes.Buffer
+		json.NewEncoder(&buf).Encode(doc)
+		if err := store.Import(&buf); err != nil {
+			t.Fatalf("Import: %v", err)
+		}
+		if got := exportOf(t, store).SchemaVersion; got != latestSchemaVersion() {
+			t.Errorf("schema version after Import = %d, want %d", got, latestSchemaVersion())
+		}
+	})
+}
+
+func TestImportMigratesLegacyExport(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "legacy.db")
+	writeLegacyFile(t, path)
+	legacy, err := OpenReadOnly(BackendBolt, path)
+	if err != nil {
+		t.Fatalf("OpenReadOnly: %v", err)
+	}
+	doc := exportOf(t, legacy)
+	legacy.Close()
+	if doc.SchemaVersion != 0 {
+		t.Fatalf("export of an unmigrated file has schema version %d, want 0", doc.SchemaVersion)
+	}
+
+	forEachBackend(t, func(t *testing.T, store Store) {
+		var buf bytes.Buffer
+		json.NewEncoder(&buf).Encode(doc)
+		if err := store.Import(&buf); err != nil {
+			t.Fatalf("Import: %v", err)
+		}
+		if history, _ := store.LoadHistory(GlobalScope); len(history) != 2 {
+			t.Errorf("history after importing a legacy export = %+v, want the legacy conversation", history)
+		}
+		if personality, _ := store.LoadPersonality(); personality != "You are a pirate." {
+			t.Errorf("personality after importing a legacy export = %q", personality)
+		}
+		after := exportOf(t, store)
+		if after.SchemaVersion != latestSchemaVersion() {
+			t.Errorf("schema version after Import = %d, want %d", after.SchemaVersion, latestSchemaVersion())
+		}
+		if _, ok := dataBuckets(after)[legacyConversationBucket]; ok {
+			t.Error("Import wrote the legacy conversations bucket into a current store")
+		}
+	})
+}
+
+func TestImportRejectsNewerSchema(t *testing.T) {
+	store := NewMemoryStore()
+	var buf bytes.Buffer
+	json.NewEncoder(&buf).Encode(Export{Format: exportFormat, SchemaVersion: latestSchemaVersion() + 1})
+	if err := store.Import(&buf); err == nil {
+		t.Error("Import accepted an export with a newer schema version")
+	}
+}
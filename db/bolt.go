@@ -1,174 +1,213 @@
 package db
 
 import (
-    "encoding/json"
-    "log"
-    "os"
-    "sync"
+	"bytes"
+	"fmt"
+	"strings"
+	"time"
 
-    "discord-ai-bot/ai"
-
-    bolt "github.com/boltdb/bolt"
-    "github.com/bwmarrin/discordgo"
+	bolt "github.com/boltdb/bolt"
 )
 
-var db *bolt.DB
-var once sync.Once
-
-// --- Global Constants ---
-const conversationBucket = "conversations"
-const globalKey = "global_conversation"
-const personalityKey = "bot_personality"
-const statusKey = "bot_status_data" // <-- New key for status
-// ------------------------
-
-// InitDB initializes the BoltDB connection (safe to call multiple times)
-func InitDB(dbPath string) {
-    once.Do(func() {
-        var err error
-        db, err = bolt.Open(dbPath, 0600, nil)
-        if err != nil {
-            log.Fatalf("Error opening BoltDB: %v", err)
-        }
-        
-        // Ensure the bucket exists
-        err = db.Update(func(tx *bolt.Tx) error {
-            _, err := tx.CreateBucketIfNotExists([]byte(conversationBucket))
-            return err
-        })
-        if err != nil {
-            log.Fatalf("Error creating BoltDB bucket: %v", err)
-        }
-    })
-}
-
-// --- CONVERSATION HISTORY ---
-
-// LoadGlobalHistory loads the bot's entire conversation history.
-func LoadGlobalHistory() []ai.Message {
-    var history []ai.Message
-    err := db.View(func(tx *bolt.Tx) error {
-        b := tx.Bucket([]byte(conversationBucket))
-        if b == nil {
-            return nil
-        }
-        data := b.Get([]byte(globalKey))
-        if data == nil {
-            return nil
-        }
-        return json.Unmarshal(data, &history)
-    })
-
-    if err != nil {
-        log.Printf("Warning: Error loading history (returning empty): %v", err)
-    }
-    return history
-}
-
-// SaveGlobalHistory saves the updated history.
-func SaveGlobalHistory(history []ai.Message) {
-    data, err := json.Marshal(history)
-    if err != nil {
-        log.Printf("Error marshalling history: %v", err)
-        return
-    }
-
-    err = db.Update(func(tx *bolt.Tx) error {
-        b := tx.Bucket([]byte(conversationBucket))
-        if b == nil {
-            return os.ErrNotExist
-        }
-        return b.Put([]byte(globalKey), data)
-    })
-    
-    if err != nil {
-        log.Printf("Error saving history: %v", err)
-    }
-}
-
-// --- PERSONALITY ---
-
-// LoadPersonality loads the bot's system prompt (personality).
-func LoadPersonality() string {
-    var personality string
-    err := db.View(func(tx *bolt.Tx) error {
-        b := tx.Bucket([]byte(conversationBucket))
-        if b == nil {
-            return nil
-        }
-        data := b.Get([]byte(personalityKey))
-        if data != nil {
-            personality = string(data)
-        }
-        return nil
-    })
-
-    if err != nil {
-        log.Printf("Warning: Error loading personality: %v", err)
-    }
-    // Set a default personality if none is found
-    if personality == "" {
-        personality = "You are a member in a semi toxic discord server named vanir, you use gen alpha terms like ts which means this shit and sybau which means shut your bullshit ass up. you also cuss often and you never reply in paragraphs"
-    }
-    return personality
-}
-
-// SavePersonality saves the new system prompt (personality).
-func SavePersonality(personality string) {
-    err := db.Update(func(tx *bolt.Tx) error {
-        b := tx.Bucket([]byte(conversationBucket))
-        if b == nil {
-            return os.ErrNotExist
-        }
-        return b.Put([]byte(personalityKey), []byte(personality))
-    })
-    
-    if err != nil {
-        log.Printf("Error saving personality: %v", err)
-    }
-}
-
-// --- STATUS / RPC ---
-
-// LoadStatus loads the bot's custom status data.
-func LoadStatus() *discordgo.UpdateStatusData {
-    var statusData discordgo.UpdateStatusData
-    err := db.View(func(tx *bolt.Tx) error {
-        b := tx.Bucket([]byte(conversationBucket))
-        if b == nil {
-            return nil
-        }
-        data := b.Get([]byte(statusKey))
-        if data == nil {
-            return nil
-        }
-        return json.Unmarshal(data, &statusData)
-    })
-
-    if err != nil {
-        // Not a critical error, just return nil so the bot starts with default status
-        return nil
-    }
-    return &statusData
-}
-
-// SaveStatus saves the bot's custom status data.
-func SaveStatus(statusData discordgo.UpdateStatusData) {
-    data, err := json.Marshal(statusData)
-    if err != nil {
-        log.Printf("Error marshalling status data: %v", err)
-        return
-    }
-
-    err = db.Update(func(tx *bolt.Tx) error {
-        b := tx.Bucket([]byte(conversationBucket))
-        if b == nil {
-            return os.ErrNotExist
-        }
-        return b.Put([]byte(statusKey), data)
-    })
-    
-    if err != nil {
-        log.Printf("Error saving status: %v", err)
-    }
+// boltKV stores buckets as nested bolt buckets, so "knowledge_base/<guild>"
+// is the guild's bucket inside knowledge_base.
+type boltKV struct {
+	db *bolt.DB
+}
+
+// boltLockTimeout is how long to wait for another process to release the file.
+const boltLockTimeout = 2 * time.Second
+
+// openBolt opens the BoltDB file at path and migrates it to the current schema.
+func openBolt(path string) (*boltKV, error) {
+	db, err := bolt.Open(path, 0600, &bolt.Options{Timeout: boltLockTimeout})
+	if err == bolt.ErrTimeout {
+		return nil, fmt.Errorf("opening BoltDB %s: file is locked by another process (is the bot running?)", path)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("opening BoltDB %s: %w", path, err)
+	}
+
+	// Create buckets and upgrade older files in place
+	if err := migrate(db, path); err != nil {
+		db.Close()
+		return nil, fmt.Errorf("migrating BoltDB %s: %w", path, err)
+	}
+	return &boltKV{db: db}, nil
+}
+
+// openBoltReadOnly opens the BoltDB file at path without migrating or
+// writing to it. The shared lock it takes still conflicts with a running bot.
+func openBoltReadOnly(path string) (*boltKV, error) {
+	db, err := bolt.Open(path, 0600, &bolt.Options{Timeout: boltLockTimeout, ReadOnly: true})
+	if err == bolt.ErrTimeout {
+		return nil, fmt.Errorf("opening BoltDB %s: file is locked by another process (is the bot running?)", path)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("opening BoltDB %s: %w", path, err)
+	}
+	return &boltKV{db: db}, nil
+}
+
+func (b *boltKV) View(fn func(tx kvTx) error) error {
+	return b.db.View(func(tx *bolt.Tx) error { return fn(boltTx{tx}) })
+}
+
+func (b *boltKV) Update(fn func(tx kvTx) error) error {
+	return b.db.Update(func(tx *bolt.Tx) error { return fn(boltTx{tx}) })
+}
+
+func (b *boltKV) Close() error {
+	return b.db.Close()
+}
+
+type boltTx struct {
+	tx *bolt.Tx
+}
+
+// bucket walks a bucket path, creating missing buckets when create is set. It
+// returns nil if a bucket is missing and create is false.
+func (t boltTx) bucket(path string, create bool) (*bolt.Bucket, error) {
+	var b *bolt.Bucket
+	for i, name := range strings.Split(path, "/") {
+		var next *bolt.Bucket
+		if i == 0 {
+			next = t.tx.Bucket([]byte(name))
+		} else {
+			next = b.Bucket([]byte(name))
+		}
+		if next == nil {
+			if !create {
+				return nil, nil
+			}
+			var err error
+			if i == 0 {
+				next, err = t.tx.CreateBucket([]byte(name))
+			} else {
+				next, err = b.CreateBucket([]byte(name))
+			}
+			if err != nil {
+				return nil, fmt.Errorf("creating bucket %s: %w", path, err)
+			}
+		}
+		b = next
+	}
+	return b, nil
+}
+
+func (t boltTx) Get(bucket, key string) ([]byte, error) {
+	b, err := t.bucket(bucket, false)
+	if b == nil || err != nil {
+		return nil, err
+	}
+	// Bolt values are only valid for the life of the transaction.
+	if data := b.Get([]byte(key)); data != nil {
+		return append([]byte(nil), data...), nil
+	}
+	return nil, nil
+}
+
+func (t boltTx) Put(bucket, key string, value []byte) error {
+	b, err := t.bucket(bucket, true)
+	if err != nil {
+		return err
+	}
+	return b.Put([]byte(key), value)
+}
+
+func (t boltTx) Delete(bucket, key string) error {
+	b, err := t.bucket(bucket, false)
+	if b == nil || err != nil {
+		return err
+	}
+	return b.Delete([]byte(key))
+}
+
+func (t boltTx) Scan(bucket, prefix string, fn func(key string, value []byte) error) error {
+	b, err := t.bucket(bucket, false)
+	if b == nil || err != nil {
+		return err
+	}
+	c := b.Cursor()
+	for k, v := c.Seek([]byte(prefix)); k != nil && bytes.HasPrefix(k, []byte(prefix)); k, v = c.Next() {
+		if v == nil {
+			continue // Nested bucket
+		}
+		if err := fn(string(k), append([]byte(nil), v...)); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (t boltTx) NextSequence(bucket string) (uint64, error) {
+	b, err := t.bucket(bucket, true)
+	if err != nil {
+		return 0, err
+	}
+	return b.NextSequence()
+}
+
+func (t boltTx) Sequence(bucket string) (uint64, error) {
+	b, err := t.bucket(bucket, false)
+	if b == nil || err != nil {
+		return 0, err
+	}
+	return b.Sequence(), nil
+}
+
+func (t boltTx) SetSequence(bucket string, value uint64) error {
+	b, err := t.bucket(bucket, true)
+	if err != nil {
+		return err
+	}
+	return b.SetSequence(value)
+}
+
+func (t boltTx) DeleteBucket(bucket string) error {
+	var err error
+	if parent, name, nested := cutLast(bucket); nested {
+		var b *bolt.Bucket
+		if b, err = t.bucket(parent, false); b == nil || err != nil {
+			return err
+		}
+		err = b.DeleteBucket([]byte(name))
+	} else {
+		err = t.tx.DeleteBucket([]byte(bucket))
+	}
+	if err == bolt.ErrBucketNotFound {
+		return nil
+	}
+	return err
+}
+
+func (t boltTx) Buckets(parent string) ([]string, error) {
+	var names []string
+	if parent == "" {
+		err := t.tx.ForEach(func(name []byte, _ *bolt.Bucket) error {
+			names = append(names, string(name))
+			return nil
+		})
+		return names, err
+	}
+	b, err := t.bucket(parent, false)
+	if b == nil || err != nil {
+		return nil, err
+	}
+	err = b.ForEach(func(k, v []byte) error {
+		if v == nil {
+			names = append(names, string(k))
+		}
+		return nil
+	})
+	return names, err
+}
+
+// cutLast splits "a/b/c" into "a/b" and "c".
+func cutLast(path string) (parent, name string, nested bool) {
+	idx := strings.LastIndex(path, "/")
+	if idx < 0 {
+		return "", path, false
+	}
+	return path[:idx], path[idx+1:], true
 }
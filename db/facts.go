@@ -0,0 +1,82 @@
+package db
+
+import (
+	"time"
+)
+
+// factsBucket holds long-term user facts, keyed by Discord user ID. It is kept
+// apart from the conversation bucket so clearing history never drops facts.
+const factsBucket = "user_facts"
+
+// MaxFactsPerUser caps how many facts a single user can store.
+const MaxFactsPerUser = 50
+
+// MaxFactLength caps the length of a single fact in characters.
+const MaxFactLength = 300
+
+// UserFact is a single thing a user asked the bot to remember about them.
+type UserFact struct {
+	ID        uint64    `json:"id"`
+	Content   string    `json:"content"`
+	CreatedAt time.Time `json:"created_at"`
+}
+
+// LoadUserFacts returns every fact stored for a user, oldest first.
+func (s *kvStore) LoadUserFacts(userID string) ([]UserFact, error) {
+	var facts []UserFact
+	err := s.kv.View(func(tx kvTx) error {
+		_, err := getJSON(tx, factsBucket, userID, &facts)
+		return err
+	})
+	return facts, err
+}
+
+// AddUserFact stores a new fact for a user. When the user is already at
+// MaxFactsPerUser the oldest fact is dropped to make room.
+func (s *kvStore) AddUserFact(userID, content string) error {
+	if runes := []rune(content); len(runes) > MaxFactLength {
+		content = string(runes[:MaxFactLength])
+	}
+
+	return s.kv.Update(func(tx kvTx) error {
+		var facts []UserFact
+		if _, err := getJSON(tx, factsBucket, userID, &facts); err != nil {
+			return err
+		}
+
+		id, err := tx.NextSequence(factsBucket)
+		if err != nil {
+			return err
+		}
+		facts = append(facts, UserFact{ID: id, Content: content, CreatedAt: time.Now().UTC()})
+		if len(facts) > MaxFactsPerUser {
+			facts = facts[len(facts)-MaxFactsPerUser:]
+		}
+		return putJSON(tx, factsBucket, userID, facts)
+	})
+}
+
+// DeleteUserFact removes a single fact by ID. It reports whether a fact was removed.
+func (s *kvStore) DeleteUserFact(userID string, id uint64) (bool, error) {
+	removed := false
+	err := s.kv.Update(func(tx kvTx) error {
+		var facts []UserFact
+		if found, err := getJSON(tx, factsBucket, userID, &facts); err != nil || !found {
+			return err
+		}
+
+		kept := facts[:0]
+		for _, f := range facts {
+			if f.ID == id {
+				removed = true
+				continue
+			}
+			kept = append(kept, f)
+		}
+		if len(kept) == 0 {
+			return tx.Delete(factsBucket, userID)
+		}
+		return putJSON(tx, factsBucket, userID, kept)
+	})
+	return removed && err == nil, err
+}
@@ -0,0 +1,60 @@
+package db
+
+import (
+	"encoding/json"
+	"sort"
+	"time"
+)
+
+// feedbackBucket holds 👍/👎 ratings of AI replies, keyed by
+// "<message ID>:<rater ID>" so each member has one vote per reply.
+const feedbackBucket = "feedback"
+
+// Ratings accepted by SaveFeedback.
+const (
+	RatingUp   = "up"
+	RatingDown = "down"
+)
+
+// Feedback is one member's rating of an AI reply, stored with the exchange
+// it rated so it can be reviewed after the history has moved on.
+type Feedback struct {
+	MessageID string `json:"message_id"`
+	GuildID   string `json:"guild_id,omitempty"`
+	ChannelID string `json:"channel_id"`
+	// UserID rated the reply; AuthorID asked the question it answered.
+	UserID    string    `json:"user_id"`
+	AuthorID  string    `json:"author_id,omitempty"`
+	Rating    string    `json:"rating"`
+	Prompt    string    `json:"prompt,omitempty"`
+	Reply     string    `json:"reply"`
+	CreatedAt time.Time `json:"created_at"`
+}
+
+// SaveFeedback stores a rating, replacing the rater's earlier vote on the same reply.
+func (s *kvStore) SaveFeedback(f Feedback) error {
+	return s.kv.Update(func(tx kvTx) error {
+		return putJSON(tx, feedbackBucket, f.MessageID+":"+f.UserID, f)
+	})
+}
+
+// LoadFeedback returns every stored rating, oldest first.
+func (s *kvStore) LoadFeedback() ([]Feedback, error) {
+	var all []Feedback
+	err := s.kv.View(func(tx kvTx) error {
+		return tx.Scan(feedbackBucket, "", func(_ string, v []byte) error {
+			var f Feedback
+			if err := json.Unmarshal(v, &f); err != nil {
+				return nil // Skip entries we can't read
+			}
+			all = append(all, f)
+			return nil
+		})
+	})
+	if err != nil {
+		return nil, err
+	}
+	// Keys sort by message ID, so order by when the vote was cast instead
+	sort.SliceStable(all, func(i, j int) bool { return all[i].CreatedAt.Before(all[j].CreatedAt) })
+	return all, nil
+}
@@ -0,0 +1,38 @@
+package db
+
+// guildBucket holds per-guild settings, keyed by guild ID.
+const guildBucket = "guild_settings"
+
+// GuildSettings are the settings a guild's admins can change with slash commands.
+type GuildSettings struct {
+	// ModerationLevel is a moderation.Level name; empty means the default level.
+	ModerationLevel string `json:"moderation_level,omitempty"`
+	// ModLogChannelID receives a message whenever moderation acts.
+	ModLogChannelID string `json:"mod_log_channel_id,omitempty"`
+	// DailyTokenQuota and MonthlyTokenQuota override the QUOTA_* defaults:
+	// 0 uses the default and a negative value means unlimited.
+	DailyTokenQuota   int64 `json:"daily_token_quota,omitempty"`
+	MonthlyTokenQuota int64 `json:"monthly_token_quota,omitempty"`
+	// ThreadChannels are the channels where a ping starts a conversation thread.
+	ThreadChannels []string `json:"thread_channels,omitempty"`
+}
+
+// LoadGuildSettings loads a guild's settings, returning zero values if none are saved.
+func (s *kvStore) LoadGuildSettings(guildID string) (GuildSettings, error) {
+	var settings GuildSettings
+	err := s.kv.View(func(tx kvTx) error {
+		_, err := getJSON(tx, guildBucket, guildID, &settings)
+		return err
+	})
+	if err != nil {
+		return GuildSettings{}, err
+	}
+	return settings, nil
+}
+
+// SaveGuildSettings saves a guild's settings.
+func (s *kvStore) SaveGuildSettings(guildID string, settings GuildSettings) error {
+	return s.kv.Update(func(tx kvTx) error {
+		return putJSON(tx, guildBucket, guildID, settings)
+	})
+}
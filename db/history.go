@@ -0,0 +1,374 @@
+package db
+
+import (
+	"encoding/json"
+	"sort"
+	"strings"
+	"time"
+)
+
+// GlobalScope is the history scope shared by every channel.
+const GlobalScope = "global"
+
+// ThreadScope is the history scope of a conversation thread, isolated from
+// GlobalScope and from every other thread.
+func ThreadScope(threadID string) string {
+	return "thread:" + threadID
+}
+
+// Turn is one stored history message. UserID is the member the turn belongs
+// to: the author of a user turn, or the member an assistant turn answered.
+// MessageID is the Discord message the turn was posted as, when there is one.
+// Turns saved before attribution existed have no UserID or CreatedAt.
+type Turn struct {
+	Role      string    `json:"role"`
+	Content   string    `json:"content"`
+	UserID    string    `json:"user_id,omitempty"`
+	MessageID string    `json:"message_id,omitempty"`
+	CreatedAt time.Time `json:"created_at,omitempty"`
+}
+
+// LoadHistory loads the conversation history for a scope.
+func (s *kvStore) LoadHistory(scope string) ([]Turn, error) {
+	var history []Turn
+	err := s.kv.View(func(tx kvTx) error {
+		_, err := getJSON(tx, historyBucket, scope, &history)
+		return err
+	})
+	return history, err
+}
+
+// SaveHistory replaces the conversation history for a scope.
+func (s *kvStore) SaveHistory(scope string, history []Turn) error {
+	return s.kv.Update(func(tx kvTx) error {
+		return putJSON(tx, historyBucket, scope, history)
+	})
+}
+
+// DeleteHistory removes a scope's rolling history and every exchange archived from it.
+func (s *kvStore) DeleteHistory(scope string) error {
+	return s.kv.Update(func(tx kvTx) error {
+		if err := tx.Delete(historyBucket, scope); err != nil {
+			return err
+		}
+		var drop []string
+		err := tx.Scan(archiveBucket, "", func(key string, v []byte) error {
+			var ex ArchivedExchange
+			if json.Unmarshal(v, &ex) == nil && ex.scope() == scope {
+				drop = append(drop, key)
+			}
+			return nil
+		})
+		if err != nil {
+			return err
+		}
+		for _, key := range drop {
+			if err := tx.Delete(archiveBucket, key); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+}
+
+// RecentUserTurns returns up to limit user turns from the given scopes,
+// newest first. Turns saved before timestamps were recorded come last.
+func (s *kvStore) RecentUserTurns(scopes []string, limit int) ([]Turn, error) {
+	var turns []Turn
+	err := s.kv.View(func(tx kvTx) error {
+		for _, scope := range scopes {
+			var history []Turn
+			if _, err := getJSON(tx, historyBucket, scope, &history); err != nil {
+				return err
+			}
+			// Newest first within the scope, for turns without timestamps
+			for idx := len(history) - 1; idx >= 0; idx-- {
+				if history[idx].Role == "user" {
+					turns = append(turns, history[idx])
+				}
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	sort.SliceStable(turns, func(a, b int) bool {
+		return turns[a].CreatedAt.After(turns[b].CreatedAt)
+	})
+	if len(turns) > limit {
+		turns = turns[:limit]
+	}
+	return turns, nil
+}
+
+// --- RETENTION ---
+
+// RetentionPolicy limits how much conversation data is kept. Zero values
+// disable the corresponding limit.
+type RetentionPolicy struct {
+	// MaxAge drops turns and archived exchanges older than this. Turns saved
+	// before timestamps were recorded are never dropped by age.
+	MaxAge time.Duration
+	// MaxTurns caps the exchanges (a user message and its reply) kept per
+	// scope, counting the rolling history and the archive together.
+	MaxTurns int
+}
+
+// ApplyRetention enforces the policy on every scope in one transaction and
+// returns how many messages and archived exchanges it removed.
+func (s *kvStore) ApplyRetention(policy RetentionPolicy) (int, error) {
+	if policy.MaxAge <= 0 && policy.MaxTurns <= 0 {
+		return 0, nil
+	}
+	var cutoff time.Time
+	if policy.MaxAge > 0 {
+		cutoff = time.Now().UTC().Add(-policy.MaxAge)
+	}
+	expired := func(t time.Time) bool {
+		return !cutoff.IsZero() && !t.IsZero() && t.Before(cutoff)
+	}
+
+	removed := 0
+	err := s.kv.Update(func(tx kvTx) error {
+		// Rolling history first, remembering how many exchanges each scope keeps
+		// so the archive only gets what's left of MaxTurns.
+		recent := make(map[string]int)
+		histories := make(map[string][]Turn)
+		err := tx.Scan(historyBucket, "", func(scope string, v []byte) error {
+			var history []Turn
+			if err := json.Unmarshal(v, &history); err != nil {
+				return nil // Leave entries we can't read alone
+			}
+			histories[scope] = history
+			return nil
+		})
+		if err != nil {
+			return err
+		}
+		for scope, history := range histories {
+			kept := history[:0]
+			for _, t := range history {
+				if !expired(t.CreatedAt) {
+					kept = append(kept, t)
+				}
+			}
+			if policy.MaxTurns > 0 {
+				kept = lastExchanges(kept, policy.MaxTurns)
+			}
+			recent[scope] = countExchanges(kept)
+			if len(kept) == len(history) {
+				continue
+			}
+			removed += len(history) - len(kept)
+			if err := putJSON(tx, historyBucket, scope, kept); err != nil {
+				return err
+			}
+		}
+
+		// Archive keys are sequence numbers, so scanning goes oldest first.
+		var drop []string
+		byScope := make(map[string][]string)
+		err = tx.Scan(archiveBucket, "", func(key string, v []byte) error {
+			var ex ArchivedExchange
+			if err := json.Unmarshal(v, &ex); err != nil {
+				return nil
+			}
+			if expired(ex.CreatedAt) {
+				drop = append(drop, key)
+				return nil
+			}
+			byScope[ex.scope()] = append(byScope[ex.scope()], key)
+			return nil
+		})
+		if err != nil {
+			return err
+		}
+		if policy.MaxTurns > 0 {
+			for scope, keys := range byScope {
+				allowed := policy.MaxTurns - recent[scope]
+				if allowed < 0 {
+					allowed = 0
+				}
+				if len(keys) > allowed {
+					drop = append(drop, keys[:len(keys)-allowed]...)
+				}
+			}
+		}
+		for _, key := range drop {
+			if err := tx.Delete(archiveBucket, key); err != nil {
+				return err
+			}
+		}
+		removed += len(drop)
+		return nil
+	})
+	return removed, err
+}
+
+// countExchanges counts the user turns in a history.
+func countExchanges(history []Turn) int {
+	n := 0
+	for _, t := range history {
+		if t.Role == "user" {
+			n++
+		}
+	}
+	return n
+}
+
+// lastExchanges keeps the newest n exchanges, cutting before a user turn.
+func lastExchanges(history []Turn, n int) []Turn {
+	seen := 0
+	for idx := len(history) - 1; idx >= 0; idx-- {
+		if history[idx].Role != "user" {
+			continue
+		}
+		seen++
+		if seen == n {
+			return history[idx:]
+		}
+	}
+	return history
+}
+
+// --- PRIVACY ---
+
+// ForgetResult counts what ForgetUser removed.
+type ForgetResult struct {
+	Messages     int
+	Archived     int
+	Facts        int
+	UsageRecords int
+	Feedback     int
+	// Unattributed counts the user messages and archived exchanges that
+	// were saved before authors were recorded. They can't be matched to
+	// anyone, so they are kept.
+	Unattributed int
+}
+
+// ForgetUser removes everything stored about a user: their turns (and the
+// replies to them) in every history scope, their archived exchanges, their
+// facts, their per-user usage records and feedback they gave or that quotes
+// their prompts. Guild-wide usage totals stay, as
+// they don't identify anyone.
+func (s *kvStore) ForgetUser(userID string) (ForgetResult, error) {
+	var res ForgetResult
+	err := s.kv.Update(func(tx kvTx) error {
+		histories := make(map[string][]Turn)
+		err := tx.Scan(historyBucket, "", func(scope string, v []byte) error {
+			var history []Turn
+			if err := json.Unmarshal(v, &history); err != nil {
+				return nil
+			}
+			histories[scope] = history
+			return nil
+		})
+		if err != nil {
+			return err
+		}
+		for scope, history := range histories {
+			kept := history[:0]
+			for _, t := range history {
+				if t.UserID == userID {
+					continue
+				}
+				if t.UserID == "" && t.Role == "user" {
+					res.Unattributed++
+				}
+				kept = append(kept, t)
+			}
+			if n := len(history) - len(kept); n > 0 {
+				res.Messages += n
+				if err := putJSON(tx, historyBucket, scope, kept); err != nil {
+					return err
+				}
+			}
+		}
+
+		var drop []string
+		err = tx.Scan(archiveBucket, "", func(key string, v []byte) error {
+			var ex ArchivedExchange
+			if json.Unmarshal(v, &ex) != nil {
+				return nil
+			}
+			switch ex.UserID {
+			case userID:
+				drop = append(drop, key)
+			case "":
+				res.Unattributed++
+			}
+			return nil
+		})
+		if err != nil {
+			return err
+		}
+		for _, key := range drop {
+			if err := tx.Delete(archiveBucket, key); err != nil {
+				return err
+			}
+		}
+		res.Archived = len(drop)
+
+		var facts []UserFact
+		if found, err := getJSON(tx, factsBucket, userID, &facts); err != nil {
+			return err
+		} else if found {
+			res.Facts = len(facts)
+			if err := tx.Delete(factsBucket, userID); err != nil {
+				return err
+			}
+		}
+
+		scopes, err := tx.Buckets(usageBucket)
+		if err != nil {
+			return err
+		}
+		for _, scope := range scopes {
+			bucket := bucketPath(usageBucket, scope)
+			var keys []string
+			err := tx.Scan(bucket, "user:"+userID+":", func(key string, _ []byte) error {
+				keys = append(keys, key)
+				return nil
+			})
+			if err != nil {
+				return err
+			}
+			for _, key := range keys {
+				if err := tx.Delete(bucket, key); err != nil {
+					return err
+				}
+			}
+			res.UsageRecords += len(keys)
+		}
+
+		drop = drop[:0]
+		err = tx.Scan(feedbackBucket, "", func(key string, v []byte) error {
+			var f Feedback
+			if json.Unmarshal(v, &f) == nil && (f.UserID == userID || f.AuthorID == userID) {
+				drop = append(drop, key)
+			}
+			return nil
+		})
+		if err != nil {
+			return err
+		}
+		for _, key := range drop {
+			if err := tx.Delete(feedbackBucket, key); err != nil {
+				return err
+			}
+		}
+		res.Feedback = len(drop)
+		return nil
+	})
+	return res, err
+}
+
+// scope returns the history scope an exchange was archived from. Exchanges
+// archived before scopes were recorded belong to the global scope.
+func (ex ArchivedExchange) scope() string {
+	if strings.TrimSpace(ex.Scope) == "" {
+		return GlobalScope
+	}
+	return ex.Scope
+}
@@ -0,0 +1,62 @@
+package db
+
+import (
+	"encoding/json"
+	"sort"
+	"time"
+)
+
+// kbBucket holds one nested bucket per guild, each mapping a document name to
+// its chunked contents.
+const kbBucket = "knowledge_base"
+
+// KBDocument is an uploaded knowledge base document split into chunks.
+type KBDocument struct {
+	Name    string    `json:"name"`
+	AddedBy string    `json:"added_by"`
+	AddedAt time.Time `json:"added_at"`
+	Size    int       `json:"size"`
+	Chunks  []string  `json:"chunks"`
+}
+
+// SaveDocument stores a document for a guild, replacing any document with the same name.
+func (s *kvStore) SaveDocument(guildID string, doc KBDocument) error {
+	return s.kv.Update(func(tx kvTx) error {
+		return putJSON(tx, bucketPath(kbBucket, guildID), doc.Name, doc)
+	})
+}
+
+// LoadDocuments returns every document stored for a guild, sorted by name.
+func (s *kvStore) LoadDocuments(guildID string) ([]KBDocument, error) {
+	var docs []KBDocument
+	err := s.kv.View(func(tx kvTx) error {
+		return tx.Scan(bucketPath(kbBucket, guildID), "", func(_ string, v []byte) error {
+			var doc KBDocument
+			if err := json.Unmarshal(v, &doc); err != nil {
+				return err
+			}
+			docs = append(docs, doc)
+			return nil
+		})
+	})
+	if err != nil {
+		return nil, err
+	}
+	sort.Slice(docs, func(a, b int) bool { return docs[a].Name < docs[b].Name })
+	return docs, nil
+}
+
+// DeleteDocument removes a document from a guild. It reports whether the document existed.
+func (s *kvStore) DeleteDocument(guildID, name string) (bool, error) {
+	removed := false
+	err := s.kv.Update(func(tx kvTx) error {
+		bucket := bucketPath(kbBucket, guildID)
+		data, err := tx.Get(bucket, name)
+		if data == nil || err != nil {
+			return err
+		}
+		removed = true
+		return tx.Delete(bucket, name)
+	})
+	return removed && err == nil, err
+}
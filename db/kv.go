@@ -0,0 +1,81 @@
+package db
+
+import (
+	"encoding/json"
+	"strings"
+)
+
+// kvBackend is the small transactional key/value API the storage backends
+// provide. Everything in Store is written once on top of it.
+type kvBackend interface {
+	View(fn func(tx kvTx) error) error
+	Update(fn func(tx kvTx) error) error
+	Close() error
+}
+
+// kvTx is a read or read-write transaction. Buckets are named by
+// "/"-separated paths ("knowledge_base/<guild id>"); the bolt backend maps each
+// segment to a nested bucket, the others use the path as-is. Writing to a
+// bucket creates it, and reading a missing bucket behaves like an empty one.
+type kvTx interface {
+	// Get returns a copy of the value, or nil if the key doesn't exist.
+	Get(bucket, key string) ([]byte, error)
+	Put(bucket, key string, value []byte) error
+	Delete(bucket, key string) error
+	// Scan calls fn for every key in bucket starting with prefix, in byte order.
+	Scan(bucket, prefix string, fn func(key string, value []byte) error) error
+	// NextSequence returns an increasing per-bucket integer, starting at 1.
+	NextSequence(bucket string) (uint64, error)
+	// Sequence and SetSequence read and restore the counter for export/import.
+	Sequence(bucket string) (uint64, error)
+	SetSequence(bucket string, value uint64) error
+	// DeleteBucket removes a bucket and everything nested under it.
+	DeleteBucket(bucket string) error
+	// Buckets lists the names of the buckets directly under parent ("" for the top level).
+	Buckets(parent string) ([]string, error)
+}
+
+// bucketPath joins bucket path segments.
+func bucketPath(segments ...string) string {
+	return strings.Join(segments, "/")
+}
+
+// getJSON decodes the value at bucket/key into v. It reports whether the key existed.
+func getJSON(tx kvTx, bucket, key string, v interface{}) (bool, error) {
+	data, err := tx.Get(bucket, key)
+	if err != nil || data == nil {
+		return false, err
+	}
+	return true, json.Unmarshal(data, v)
+}
+
+// putJSON encodes v and stores it at bucket/key.
+func putJSON(tx kvTx, bucket, key string, v interface{}) error {
+	data, err := json.Marshal(v)
+	if err != nil {
+		return err
+	}
+	return tx.Put(bucket, key, data)
+}
+
+// childBuckets derives the direct children of parent from a set of full bucket
+// paths, for backends that store buckets as flat names.
+func childBuckets(paths []string, parent string) []string {
+	prefix := ""
+	if parent != "" {
+		prefix = parent + "/"
+	}
+	seen := make(map[string]bool)
+	var children []string
+	for _, p := range paths {
+		if !strings.HasPrefix(p, prefix) || p == parent {
+			continue
+		}
+		name := strings.SplitN(strings.TrimPrefix(p, prefix), "/", 2)[0]
+		if name != "" && !seen[name] {
+			seen[name] = true
+			children = append(children, name)
+		}
+	}
+	return children
+}
@@ -0,0 +1,152 @@
+package db
+
+import (
+	"sort"
+	"strings"
+	"sync"
+)
+
+// memoryKV keeps every bucket in maps. Update works on a copy of the maps and
+// swaps it in on success, so a failed transaction leaves nothing behind.
+type memoryKV struct {
+	mu   sync.RWMutex
+	data map[string]map[string][]byte
+	seqs map[string]uint64
+}
+
+func newMemoryKV() *memoryKV {
+	return &memoryKV{data: make(map[string]map[string][]byte), seqs: make(map[string]uint64)}
+}
+
+func (m *memoryKV) View(fn func(tx kvTx) error) error {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+	return fn(&memoryTx{data: m.data, seqs: m.seqs})
+}
+
+func (m *memoryKV) Update(fn func(tx kvTx) error) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	tx := &memoryTx{
+		data:     make(map[string]map[string][]byte, len(m.data)),
+		seqs:     make(map[string]uint64, len(m.seqs)),
+		writable: true,
+	}
+	for name, bucket := range m.data {
+		copied := make(map[string][]byte, len(bucket))
+		for k, v := range bucket {
+			copied[k] = v
+		}
+		tx.data[name] = copied
+	}
+	for name, seq := range m.seqs {
+		tx.seqs[name] = seq
+	}
+
+	if err := fn(tx); err != nil {
+		return err
+	}
+	m.data, m.seqs = tx.data, tx.seqs
+	return nil
+}
+
+func (m *memoryKV) Close() error {
+	return nil
+}
+
+// memoryTx never hands out or keeps references to caller-owned slices, so
+// stored values can be shared between the live maps and copies.
+type memoryTx struct {
+	data     map[string]map[string][]byte
+	seqs     map[string]uint64
+	writable bool
+}
+
+func (t *memoryTx) Get(bucket, key string) ([]byte, error) {
+	if v, ok := t.data[bucket][key]; ok {
+		return append([]byte(nil), v...), nil
+	}
+	return nil, nil
+}
+
+func (t *memoryTx) Put(bucket, key string, value []byte) error {
+	if !t.writable {
+		return errReadOnly
+	}
+	if t.data[bucket] == nil {
+		t.data[bucket] = make(map[string][]byte)
+	}
+	t.data[bucket][key] = append([]byte(nil), value...)
+	return nil
+}
+
+func (t *memoryTx) Delete(bucket, key string) error {
+	if !t.writable {
+		return errReadOnly
+	}
+	delete(t.data[bucket], key)
+	return nil
+}
+
+func (t *memoryTx) Scan(bucket, prefix string, fn func(key string, value []byte) error) error {
+	keys := make([]string, 0, len(t.data[bucket]))
+	for k := range t.data[bucket] {
+		if strings.HasPrefix(k, prefix) {
+			keys = append(keys, k)
+		}
+	}
+	sort.Strings(keys)
+	for _, k := range keys {
+		if err := fn(k, append([]byte(nil), t.data[bucket][k]...)); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (t *memoryTx) NextSequence(bucket string) (uint64, error) {
+	if !t.writable {
+		return 0, errReadOnly
+	}
+	t.seqs[bucket]++
+	return t.seqs[bucket], nil
+}
+
+func (t *memoryTx) Sequence(bucket string) (uint64, error) {
+	return t.seqs[bucket], nil
+}
+
+func (t *memoryTx) SetSequence(bucket string, value uint64) error {
+	if !t.writable {
+		return errReadOnly
+	}
+	t.seqs[bucket] = value
+	return nil
+}
+
+func (t *memoryTx) DeleteBucket(bucket string) error {
+	if !t.writable {
+		return errReadOnly
+	}
+	for name := range t.data {
+		if name == bucket || strings.HasPrefix(name, bucket+"/") {
+			delete(t.data, name)
+			delete(t.seqs, name)
+		}
+	}
+	return nil
+}
+
+func (t *memoryTx) Buckets(parent string) ([]string, error) {
+	paths := make([]string, 0, len(t.data)+len(t.seqs))
+	for name := range t.data {
+		paths = append(paths, name)
+	}
+	for name := range t.seqs {
+		paths = append(paths, name)
+	}
+	names := childBuckets(paths, parent)
+	sort.Strings(names)
+	return names, nil
+}
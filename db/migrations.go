@@ -0,0 +1,177 @@
+package db
+
+import (
+	"encoding/binary"
+	"fmt"
+	"log/slog"
+	"time"
+
+	bolt "github.com/boltdb/bolt"
+)
+
+// schemaVersionKey in the meta bucket stores the schema version as a big-endian uint64.
+const schemaVersionKey = "schema_version"
+
+// legacyConversationBucket is the single bucket older files kept everything in.
+const legacyConversationBucket = "conversations"
+
+// Keys used in legacyConversationBucket before schema version 2.
+const (
+	legacyGlobalKey      = "global_conversation"
+	legacyPersonalityKey = "bot_personality"
+	legacyStatusKey      = "bot_status_data"
+)
+
+// migration upgrades the file from version-1 to version inside one transaction.
+type migration struct {
+	version     int
+	description string
+	up          func(tx *bolt.Tx) error
+}
+
+// migrations are applied in order. Append new ones at the end; never edit or
+// reorder released migrations.
+var migrations = []migration{
+	{
+		version:     1,
+		description: "create per-feature buckets",
+		up: func(tx *bolt.Tx) error {
+			return createBuckets(tx, factsBucket, archiveBucket, kbBucket, guildBucket, usageBucket)
+		},
+	},
+	{
+		version:     2,
+		description: "move history and settings out of the conversations bucket",
+		up: func(tx *bolt.Tx) error {
+			if err := createBuckets(tx, historyBucket, settingsBucket); err != nil {
+				return err
+			}
+			legacy := tx.Bucket([]byte(legacyConversationBucket))
+			if legacy == nil {
+				return nil
+			}
+
+			moves := []struct{ from, bucket, to string }{
+				{legacyGlobalKey, historyBucket, GlobalScope},
+				{legacyPersonalityKey, settingsBucket, personalityKey},
+				{legacyStatusKey, settingsBucket, statusKey},
+			}
+			for _, mv := range moves {
+				data := legacy.Get([]byte(mv.from))
+				if data == nil {
+					continue
+				}
+				// Bolt values are only valid for the life of the transaction's
+				// mapping, so copy before writing into another bucket.
+				value := append([]byte(nil), data...)
+				if err := tx.Bucket([]byte(mv.bucket)).Put([]byte(mv.to), value); err != nil {
+					return err
+				}
+			}
+			return tx.DeleteBucket([]byte(legacyConversationBucket))
+		},
+	},
+	{
+		version:     3,
+		description: "create feedback bucket",
+		up: func(tx *bolt.Tx) error {
+			return createBuckets(tx, feedbackBucket)
+		},
+	},
+	{
+		version:     4,
+		description: "create status presets bucket",
+		up: func(tx *bolt.Tx) error {
+			return createBuckets(tx, presetBucket)
+		},
+	},
+}
+
+// latestSchemaVersion is the version a fully migrated file has.
+func latestSchemaVersion() int {
+	return migrations[len(migrations)-1].version
+}
+
+func createBuckets(tx *bolt.Tx, names ...string) error {
+	for _, name := range names {
+		if _, err := tx.CreateBucketIfNotExists([]byte(name)); err != nil {
+			return fmt.Errorf("creating bucket %s: %w", name, err)
+		}
+	}
+	return nil
+}
+
+func readSchemaVersion(tx *bolt.Tx) int {
+	b := tx.Bucket([]byte(metaBucket))
+	if b == nil {
+		return 0
+	}
+	data := b.Get([]byte(schemaVersionKey))
+	if len(data) != 8 {
+		return 0
+	}
+	return int(binary.BigEndian.Uint64(data))
+}
+
+func writeSchemaVersion(tx *bolt.Tx, version int) error {
+	b, err := tx.CreateBucketIfNotExists([]byte(metaBucket))
+	if err != nil {
+		return err
+	}
+	return b.Put([]byte(schemaVersionKey), itob(uint64(version)))
+}
+
+// migrate brings a bolt file up to latestSchemaVersion. The other backends
+// start empty with the current layout and need no migrations. Files that
+// already hold data are copied to a timestamped backup next to dbPath first.
+// Each migration runs in its own transaction together with its version bump,
+// so an interrupted upgrade resumes from the last completed step.
+func migrate(db *bolt.DB, dbPath string) error {
+	var current int
+	var hasData bool
+	err := db.View(func(tx *bolt.Tx) error {
+		current = readSchemaVersion(tx)
+		return tx.ForEach(func(name []byte, _ *bolt.Bucket) error {
+			if string(name) != metaBucket {
+				hasData = true
+			}
+			return nil
+		})
+	})
+	if err != nil {
+		return fmt.Errorf("reading schema version: %w", err)
+	}
+
+	latest := latestSchemaVersion()
+	if current > latest {
+		return fmt.Errorf("database schema version %d is newer than this binary supports (%d)", current, latest)
+	}
+	if current == latest {
+		return nil
+	}
+
+	if hasData {
+		backupPath := fmt.Sprintf("%s.backup-v%d-%s", dbPath, current, time.Now().UTC().Format("20060102T150405Z"))
+		if err := db.View(func(tx *bolt.Tx) error { return tx.CopyFile(backupPath, 0600) }); err != nil {
+			return fmt.Errorf("backing up database before migration: %w", err)
+		}
+		slog.Info("backed up database before migration", "backup", backupPath, "from_version", current, "to_version", latest)
+	}
+
+	for _, m := range migrations {
+		if m.version <= current {
+			continue
+		}
+		err := db.Update(func(tx *bolt.Tx) error {
+			if err := m.up(tx); err != nil {
+				return err
+			}
+			return writeSchemaVersion(tx, m.version)
+		})
+		if err != nil {
+			return fmt.Errorf("migration %d (%s): %w", m.version, m.description, err)
+		}
+		slog.Info("applied database migration", "version", m.version, "description", m.description)
+	}
+	return nil
+}
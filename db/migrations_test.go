@@ -0,0 +1,204 @@
+package db
+
+import (
+	"encoding/json"
+	"path/filepath"
+	"testing"
+
+	bolt "github.com/boltdb/bolt"
+	"github.com/bwmarrin/discordgo"
+)
+
+// writeLegacyFile creates a bolt file in the layout used before schema
+// versions existed: everything in one conversations bucket.
+func writeLegacyFile(t *testing.T, path string) {
+	t.Helper()
+	raw, err := bolt.Open(path, 0600, nil)
+	if err != nil {
+		t.Fatalf("creating legacy file: %v", err)
+	}
+	defer raw.Close()
+
+	history, _ := json.Marshal([]Turn{{Role: "user", Content: "hi"}, {Role: "assistant", Content: "hello"}})
+	status, _ := json.Marshal(discordgo.UpdateStatusData{Status: "idle"})
+	err = raw.Update(func(tx *bolt.Tx) error {
+		b, err := tx.CreateBucket([]byte(legacyConversationBucket))
+		if err != nil {
+			return err
+		}
+		for key, value := range map[string][]byte{
+			legacyGlobalKey:      history,
+			legacyPersonalityKey: []byte("You are a pirate."),
+			legacyStatusKey:      status,
+		} {
+			if err := b.Put([]byte(key), value); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		t.Fatalf("writing legacy file: %v", err)
+	}
+}
+
+func schemaVersionOf(t *testing.T, path string) int {
+	t.Helper()
+	raw, err := bolt.Open(path, 0600, nil)
+	if err != nil {
+		t.Fatalf("reopening file: %v", err)
+	}
+	defer raw.Close()
+	var version int
+	raw.View(func(tx *bolt.Tx) error {
+		version = readSchemaVersion(tx)
+		return nil
+	})
+	return version
+}
+
+func TestMigrateLegacyFile(t *testing.T) {
+	dir := t.TempDir()
+	path := filepath.Join(dir, "bot.db")
+	writeLegacyFile(t, path)
+
+	store, err := Open(BackendBolt, path)
+	if err != nil {
+		t.Fatalf("Open: %v", err)
+	}
+
+	history, err := store.LoadHistory(GlobalScope)
+	if err != nil || len(history) != 2 || history[1].Content != "hello" {
+		t.Errorf("LoadHistory after migration = %+v, %v; want the legacy conversation", history, err)
+	}
+	if personality, err := store.LoadPersonality(); err != nil || personality != "You are a pirate." {
+		t.Errorf("LoadPersonality after migration = %q, %v; want the legacy personality", personality, err)
+	}
+	if status, err := store.LoadStatus(); err != nil || status == nil || status.Status != "idle" {
+		t.Errorf("LoadStatus after migration = %+v, %v; want the legacy status", status, err)
+	}
+	// Buckets from every migration are usable
+	if err := store.SaveStatusPreset(StatusPreset{Name: "away", Status: discordgo.UpdateStatusData{Status: "idle"}}); err != nil {
+		t.Errorf("SaveStatusPreset after migration: %v", err)
+	}
+	if err := store.SaveFeedback(Feedback{MessageID: "m", UserID: "u", Rating: RatingUp}); err != nil {
+		t.Errorf("SaveFeedback after migration: %v", err)
+	}
+	store.Close()
+
+	if got, want := schemaVersionOf(t, path), latestSchemaVersion(); got != want {
+		t.Errorf("schema version after migration = %d, want %d", got, want)
+	}
+	backups, _ := filepath.Glob(path + ".backup-v0-*")
+	if len(backups) != 1 {
+		t.Errorf("found %d pre-migration backups, want 1", len(backups))
+	}
+
+	// Opening a migrated file again changes nothing and takes no new backup
+	store, err = Open(BackendBolt, path)
+	if err != nil {
+		t.Fatalf("reopening migrated file: %v", err)
+	}
+	store.Close()
+	if backups, _ := filepath.Glob(path + ".backup-*"); len(backups) != 1 {
+		t.Errorf("found %d backups after reopening, want 1", len(backups))
+	}
+}
+
+func TestMigrateResumesFromVersion(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "bot.db")
+	raw, err := bolt.Open(path, 0600, nil)
+	if err != nil {
+		t.Fatalf("creating file: %v", err)
+	}
+	// A file that stopped after migration 2
+	err = raw.Update(func(tx *bolt.Tx) error {
+		for _, m := range migrations[:2] {
+			if err := m.up(tx); err != nil {
+				return err
+			}
+		}
+		return writeSchemaVersion(tx, 2)
+	})
+	raw.Close()
+	if err != nil {
+		t.Fatalf("preparing version 2 file: %v", err)
+	}
+
+	store, err := Open(BackendBolt, path)
+	if err != nil {
+		t.Fatalf("Open: %v", err)
+	}
+	if _, err := store.LoadStatusPresets(); err != nil {
+		t.Errorf("LoadStatusPresets after migration: %v", err)
+	}
+	store.Close()
+	if got, want := schemaVersionOf(t, path), latestSchemaVersion(); got != want {
+		t.Errorf("schema version = %d, want %d", got, want)
+	}
+}
+
+func TestOpenRejectsNewerSchema(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "bot.db")
+	raw, err := bolt.Open(path, 0600, nil)
+	if err != nil {
+		t.Fatalf("creating file: %v", err)
+	}
+	raw.Update(func(tx *bolt.Tx) error { return writeSchemaVersion(tx, latestSchemaVersion()+1) })
+	raw.Close()
+
+	if store, err := Open(BackendBolt, path); err == nil {
+		store.Close()
+		t.Fatal("Open accepted a file with a newer schema version")
+	}
+}
+
+func TestOpenReadOnlyLeavesLegacyFileAlone(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "bot.db")
+	writeLegacyFile(t, path)
+
+	store, err := OpenReadOnly(BackendBolt, path)
+	if err != nil {
+		t.Fatalf("OpenReadOnly: %v", err)
+	}
+	buckets, err := store.Inspect()
+	if err != nil || len(buckets) != 1 || buckets[0].Path != legacyConversationBucket {
+		t.Errorf("Inspect = %+v, %v; want only the legacy bucket", buckets, err)
+	}
+	if err := store.SavePersonality("changed"); err == nil {
+		t.Error("SavePersonality succeeded on a read-only store")
+	}
+	if err := store.Ping(); err != nil {
+		t.Errorf("Ping on a read-only store: %v", err)
+	}
+	store.Close()
+
+	if version := schemaVersionOf(t, path); version != 0 {
+		t.Errorf("schema version after OpenReadOnly = %d, want 0", version)
+	}
+	if backups, _ := filepath.Glob(path + ".backup-*"); len(backups) != 0 {
+		t.Errorf("OpenReadOnly took %d backups, want none", len(backups))
+	}
+}
+
+func TestOpenReadOnlySQLite(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "bot.db")
+	store, err := Open(BackendSQLite, path)
+	if err != nil {
+		t.Fatalf("Open: %v", err)
+	}
+	mustDo(t, store.SavePersonality("You are a pirate."))
+	store.Close()
+
+	store, err = OpenReadOnly(BackendSQLite, path)
+	if err != nil {
+		t.Fatalf("OpenReadOnly: %v", err)
+	}
+	defer store.Close()
+	if personality, err := store.LoadPersonality(); err != nil || personality != "You are a pirate." {
+		t.Errorf("LoadPersonality = %q, %v; want the saved personality", personality, err)
+	}
+	if err := store.SavePersonality("changed"); err == nil {
+		t.Error("SavePersonality succeeded on a read-only store")
+	}
+}
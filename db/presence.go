@@ -0,0 +1,164 @@
+package db
+
+import (
+	"encoding/json"
+	"strings"
+	"time"
+
+	"github.com/bwmarrin/discordgo"
+)
+
+// rotationKey in settingsBucket holds the StatusRotation.
+const rotationKey = "status_rotation"
+
+// MaxRotationEntries caps the rotation so every entry fits in one select menu.
+const MaxRotationEntries = 25
+
+// StatusRotation cycles the bot's presence through a list of entries. Entry
+// text may contain placeholders such as {guild_count}, which are filled in
+// each time an entry is applied.
+type StatusRotation struct {
+	Enabled bool `json:"enabled"`
+	// IntervalMinutes is the time each entry is shown; it is ignored when
+	// Cron is set.
+	IntervalMinutes int `json:"interval_minutes,omitempty"`
+	// Cron is a five-field cron expression, in the bot's local time, for
+	// when to move on to the next entry.
+	Cron    string                       `json:"cron,omitempty"`
+	Entries []discordgo.UpdateStatusData `json:"entries"`
+}
+
+// MarshalJSON writes the entries in a form discordgo can decode again.
+func (r StatusRotation) MarshalJSON() ([]byte, error) {
+	type plain StatusRotation
+	entries := make([]storedStatus, len(r.Entries))
+	for idx, entry := range r.Entries {
+		entries[idx] = storable(entry)
+	}
+	return json.Marshal(struct {
+		plain
+		Entries []storedStatus `json:"entries"`
+	}{plain(r), entries})
+}
+
+// LoadStatusRotation loads the status rotation, returning a disabled, empty
+// one if none is saved.
+func (s *kvStore) LoadStatusRotation() (StatusRotation, error) {
+	var rotation StatusRotation
+	err := s.kv.View(func(tx kvTx) error {
+		_, err := getJSON(tx, settingsBucket, rotationKey, &rotation)
+		return err
+	})
+	if err != nil {
+		return StatusRotation{}, err
+	}
+	return rotation, nil
+}
+
+// SaveStatusRotation saves the status rotation.
+func (s *kvStore) SaveStatusRotation(rotation StatusRotation) error {
+	return s.kv.Update(func(tx kvTx) error {
+		return putJSON(tx, settingsBucket, rotationKey, rotation)
+	})
+}
+
+// aiStatusKey in settingsBucket holds the AIStatus.
+const aiStatusKey = "ai_status"
+
+// AIStatus has the bot write its own status line with the LLM every so often.
+type AIStatus struct {
+	Enabled bool `json:"enabled"`
+	// IntervalMinutes is the time between new lines; 0 uses the default.
+	IntervalMinutes int `json:"interval_minutes,omitempty"`
+	// Line is the last line the LLM wrote that passed validation.
+	Line        string    `json:"line,omitempty"`
+	GeneratedAt time.Time `json:"generated_at,omitempty"`
+}
+
+// LoadAIStatus loads the AI status settings, returning disabled ones if none are saved.
+func (s *kvStore) LoadAIStatus() (AIStatus, error) {
+	var status AIStatus
+	err := s.kv.View(func(tx kvTx) error {
+		_, err := getJSON(tx, settingsBucket, aiStatusKey, &status)
+		return err
+	})
+	if err != nil {
+		return AIStatus{}, err
+	}
+	return status, nil
+}
+
+// SaveAIStatus saves the AI status settings.
+func (s *kvStore) SaveAIStatus(status AIStatus) error {
+	return s.kv.Update(func(tx kvTx) error {
+		return putJSON(tx, settingsBucket, aiStatusKey, status)
+	})
+}
+
+// presetBucket holds named presences, keyed by lowercased name.
+const presetBucket = "status_presets"
+
+// MaxPresets caps the presets so they all fit in one select menu.
+const MaxPresets = 25
+
+// StatusPreset is a saved presence that can be loaded back in one click.
+type StatusPreset struct {
+	Name    string                     `json:"name"`
+	Status  discordgo.UpdateStatusData `json:"status"`
+	SavedBy string                     `json:"saved_by,omitempty"`
+	SavedAt time.Time                  `json:"saved_at"`
+}
+
+// MarshalJSON writes the status in a form discordgo can decode again.
+func (p StatusPreset) MarshalJSON() ([]byte, error) {
+	type plain StatusPreset
+	return json.Marshal(struct {
+		plain
+		Status storedStatus `json:"status"`
+	}{plain(p), storable(p.Status)})
+}
+
+// presetKey makes preset names case-insensitive.
+func presetKey(name string) string {
+	return strings.ToLower(strings.TrimSpace(name))
+}
+
+// SaveStatusPreset stores a preset, replacing any preset with the same name.
+func (s *kvStore) SaveStatusPreset(preset StatusPreset) error {
+	return s.kv.Update(func(tx kvTx) error {
+		return putJSON(tx, presetBucket, presetKey(preset.Name), preset)
+	})
+}
+
+// LoadStatusPresets returns every preset, sorted by name.
+func (s *kvStore) LoadStatusPresets() ([]StatusPreset, error) {
+	var presets []StatusPreset
+	err := s.kv.View(func(tx kvTx) error {
+		return tx.Scan(presetBucket, "", func(_ string, v []byte) error {
+			var preset StatusPreset
+			if err := json.Unmarshal(v, &preset); err != nil {
+				return err
+			}
+			presets = append(presets, preset)
+			return nil
+		})
+	})
+	if err != nil {
+		return nil, err
+	}
+	return presets, nil
+}
+
+// DeleteStatusPreset removes a preset. It reports whether the preset existed.
+func (s *kvStore) DeleteStatusPreset(name string) (bool, error) {
+	removed := false
+	err := s.kv.Update(func(tx kvTx) error {
+		data, err := tx.Get(presetBucket, presetKey(name))
+		if data == nil || err != nil {
+			return err
+		}
+		removed = true
+		return tx.Delete(presetBucket, presetKey(name))
+	})
+	return removed && err == nil, err
+}
@@ -0,0 +1,92 @@
+package db
+
+import (
+	"github.com/bwmarrin/discordgo"
+)
+
+// --- Global Constants ---
+const historyBucket = "history"   // Rolling conversation history, keyed by scope
+const settingsBucket = "settings" // Bot-wide settings
+const personalityKey = "personality"
+const statusKey = "status"
+const metaBucket = "meta" // Schema version, and the health check key older versions wrote
+
+// ------------------------
+
+// DefaultPersonality is the system prompt used until one is saved.
+const DefaultPersonality = "You are a member in a semi toxic discord server named vanir, you use gen alpha terms like ts which means this shit and sybau which means shut your bullshit ass up. you also cuss often and you never reply in paragraphs"
+
+// --- PERSONALITY ---
+
+// LoadPersonality loads the bot's system prompt (personality).
+func (s *kvStore) LoadPersonality() (string, error) {
+	var personality string
+	err := s.kv.View(func(tx kvTx) error {
+		data, err := tx.Get(settingsBucket, personalityKey)
+		personality = string(data)
+		return err
+	})
+	if err != nil {
+		return "", err
+	}
+	if personality == "" {
+		personality = DefaultPersonality
+	}
+	return personality, nil
+}
+
+// SavePersonality saves the new system prompt (personality).
+func (s *kvStore) SavePersonality(personality string) error {
+	return s.kv.Update(func(tx kvTx) error {
+		return tx.Put(settingsBucket, personalityKey, []byte(personality))
+	})
+}
+
+// --- STATUS / RPC ---
+
+// LoadStatus loads the bot's custom status data, or nil if none is saved.
+func (s *kvStore) LoadStatus() (*discordgo.UpdateStatusData, error) {
+	var statusData discordgo.UpdateStatusData
+	var found bool
+	err := s.kv.View(func(tx kvTx) error {
+		var err error
+		found, err = getJSON(tx, settingsBucket, statusKey, &statusData)
+		return err
+	})
+	if err != nil || !found {
+		return nil, err
+	}
+	return &statusData, nil
+}
+
+// SaveStatus saves the bot's custom status data.
+func (s *kvStore) SaveStatus(statusData discordgo.UpdateStatusData) error {
+	return s.kv.Update(func(tx kvTx) error {
+		return putJSON(tx, settingsBucket, statusKey, storable(statusData))
+	})
+}
+
+// storedStatus is how an UpdateStatusData is written. discordgo encodes an
+// activity's CreatedAt as an RFC 3339 string but only decodes Unix
+// milliseconds, so statuses with activities wouldn't load back otherwise.
+type storedStatus struct {
+	discordgo.UpdateStatusData
+	Activities []storedActivity `json:"activities"`
+}
+
+type storedActivity struct {
+	*discordgo.Activity
+	CreatedAt int64 `json:"created_at,omitempty"`
+}
+
+func storable(status discordgo.UpdateStatusData) storedStatus {
+	stored := storedStatus{UpdateStatusData: status}
+	for _, act := range status.Activities {
+		entry := storedActivity{Activity: act}
+		if act != nil && !act.CreatedAt.IsZero() {
+			entry.CreatedAt = act.CreatedAt.UnixMilli()
+		}
+		stored.Activities = append(stored.Activities, entry)
+	}
+	return stored
+}
@@ -0,0 +1,219 @@
+package db
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"sort"
+
+	_ "modernc.org/sqlite"
+)
+
+var errReadOnly = errors.New("write in a read-only transaction")
+
+// sqliteSchema keeps the same bucket/key layout as the bolt file in two
+// tables. Bucket paths are stored whole ("knowledge_base/<guild>").
+const sqliteSchema = `
+CREATE TABLE IF NOT EXISTS kv (
+	bucket TEXT NOT NULL,
+	key    BLOB NOT NULL,
+	value  BLOB NOT NULL,
+	PRIMARY KEY (bucket, key)
+) WITHOUT ROWID;
+CREATE TABLE IF NOT EXISTS sequences (
+	bucket TEXT PRIMARY KEY,
+	value  INTEGER NOT NULL
+);`
+
+// sqliteKV stores buckets in an SQLite database.
+type sqliteKV struct {
+	db *sql.DB
+}
+
+// openSQLite opens (creating if needed) the SQLite database at path.
+func openSQLite(path string) (*sqliteKV, error) {
+	db, err := sql.Open("sqlite", "file:"+path+"?_pragma=busy_timeout(5000)&_pragma=journal_mode(WAL)")
+	if err != nil {
+		return nil, fmt.Errorf("opening SQLite %s: %w", path, err)
+	}
+	// One connection serialises writers the way bolt does and avoids SQLITE_BUSY.
+	db.SetMaxOpenConns(1)
+	if _, err := db.Exec(sqliteSchema); err != nil {
+		db.Close()
+		return nil, fmt.Errorf("creating SQLite schema in %s: %w", path, err)
+	}
+	return &sqliteKV{db: db}, nil
+}
+
+// openSQLiteReadOnly opens the SQLite database at path without creating or
+// changing anything in it.
+func openSQLiteReadOnly(path string) (*sqliteKV, error) {
+	db, err := sql.Open("sqlite", "file:"+path+"?mode=ro&_pragma=busy_timeout(5000)")
+	if err != nil {
+		return nil, fmt.Errorf("opening SQLite %s: %w", path, err)
+	}
+	db.SetMaxOpenConns(1)
+	if err := db.Ping(); err != nil {
+		db.Close()
+		return nil, fmt.Errorf("opening SQLite %s: %w", path, err)
+	}
+	return &sqliteKV{db: db}, nil
+}
+
+func (s *sqliteKV) View(fn func(tx kvTx) error) error {
+	return s.run(false, fn)
+}
+
+func (s *sqliteKV) Update(fn func(tx kvTx) error) error {
+	return s.run(true, fn)
+}
+
+func (s *sqliteKV) run(writable bool, fn func(tx kvTx) error) error {
+	tx, err := s.db.BeginTx(context.Background(), &sql.TxOptions{ReadOnly: !writable})
+	if err != nil {
+		return err
+	}
+	if err := fn(&sqliteTx{tx: tx, writable: writable}); err != nil {
+		tx.Rollback()
+		return err
+	}
+	return tx.Commit()
+}
+
+func (s *sqliteKV) Close() error {
+	return s.db.Close()
+}
+
+type sqliteTx struct {
+	tx       *sql.Tx
+	writable bool
+}
+
+func (t *sqliteTx) Get(bucket, key string) ([]byte, error) {
+	var value []byte
+	err := t.tx.QueryRow(`SELECT value FROM kv WHERE bucket = ? AND key = ?`, bucket, []byte(key)).Scan(&value)
+	if err == sql.ErrNoRows {
+		return nil, nil
+	}
+	return value, err
+}
+
+func (t *sqliteTx) Put(bucket, key string, value []byte) error {
+	if !t.writable {
+		return errReadOnly
+	}
+	_, err := t.tx.Exec(`INSERT INTO kv (bucket, key, value) VALUES (?, ?, ?)
+		ON CONFLICT (bucket, key) DO UPDATE SET value = excluded.value`, bucket, []byte(key), value)
+	return err
+}
+
+func (t *sqliteTx) Delete(bucket, key string) error {
+	if !t.writable {
+		return errReadOnly
+	}
+	_, err := t.tx.Exec(`DELETE FROM kv WHERE bucket = ? AND key = ?`, bucket, []byte(key))
+	return err
+}
+
+func (t *sqliteTx) Scan(bucket, prefix string, fn func(key string, value []byte) error) error {
+	// BLOB comparison is bytewise, so this matches bolt's cursor order.
+	rows, err := t.tx.Query(`SELECT key, value FROM kv WHERE bucket = ? AND key >= ? ORDER BY key`, bucket, []byte(prefix))
+	if err != nil {
+		return err
+	}
+	type entry struct {
+		key   string
+		value []byte
+	}
+	var entries []entry
+	for rows.Next() {
+		var key, value []byte
+		if err := rows.Scan(&key, &value); err != nil {
+			rows.Close()
+			return err
+		}
+		if len(key) < len(prefix) || string(key[:len(prefix)]) != prefix {
+			break
+		}
+		entries = append(entries, entry{string(key), value})
+	}
+	if err := rows.Close(); err != nil {
+		return err
+	}
+	if err := rows.Err(); err != nil {
+		return err
+	}
+	// fn may issue its own statements, which the single connection can only
+	// run once the rows above are closed.
+	for _, e := range entries {
+		if err := fn(e.key, e.value); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (t *sqliteTx) NextSequence(bucket string) (uint64, error) {
+	if !t.writable {
+		return 0, errReadOnly
+	}
+	var value uint64
+	err := t.tx.QueryRow(`INSERT INTO sequences (bucket, value) VALUES (?, 1)
+		ON CONFLICT (bucket) DO UPDATE SET value = value + 1 RETURNING value`, bucket).Scan(&value)
+	return value, err
+}
+
+func (t *sqliteTx) Sequence(bucket string) (uint64, error) {
+	var value uint64
+	err := t.tx.QueryRow(`SELECT value FROM sequences WHERE bucket = ?`, bucket).Scan(&value)
+	if err == sql.ErrNoRows {
+		return 0, nil
+	}
+	return value, err
+}
+
+func (t *sqliteTx) SetSequence(bucket string, value uint64) error {
+	if !t.writable {
+		return errReadOnly
+	}
+	_, err := t.tx.Exec(`INSERT INTO sequences (bucket, value) VALUES (?, ?)
+		ON CONFLICT (bucket) DO UPDATE SET value = excluded.value`, bucket, value)
+	return err
+}
+
+func (t *sqliteTx) DeleteBucket(bucket string) error {
+	if !t.writable {
+		return errReadOnly
+	}
+	nested := bucket + "/"
+	for _, table := range []string{"kv", "sequences"} {
+		_, err := t.tx.Exec(`DELETE FROM `+table+` WHERE bucket = ? OR substr(bucket, 1, ?) = ?`, bucket, len(nested), nested)
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (t *sqliteTx) Buckets(parent string) ([]string, error) {
+	rows, err := t.tx.Query(`SELECT bucket FROM kv UNION SELECT bucket FROM sequences`)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var paths []string
+	for rows.Next() {
+		var name string
+		if err := rows.Scan(&name); err != nil {
+			return nil, err
+		}
+		paths = append(paths, name)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	names := childBuckets(paths, parent)
+	sort.Strings(names)
+	return names, nil
+}
@@ -0,0 +1,150 @@
+package db
+
+import (
+	"fmt"
+	"io"
+
+	"github.com/bwmarrin/discordgo"
+)
+
+// Store is everything the bot persists. Handlers receive a Store instead of
+// reaching for a global database, so tests can swap in NewMemoryStore and
+// deployments can pick a backend with DB_BACKEND.
+type Store interface {
+	// Ping checks that the store is open and readable. It never writes, so
+	// health probes don't cost a sync to disk.
+	Ping() error
+	// Close flushes and closes the store. Call it once all writers have stopped.
+	Close() error
+
+	// LoadHistory returns the rolling conversation history for a scope.
+	LoadHistory(scope string) ([]Turn, error)
+	SaveHistory(scope string, history []Turn) error
+	// RecentUserTurns returns the newest user turns in the given scopes.
+	RecentUserTurns(scopes []string, limit int) ([]Turn, error)
+	// DeleteHistory drops a scope's rolling history and archived exchanges.
+	DeleteHistory(scope string) error
+	// ApplyRetention drops history and archived exchanges the policy no longer allows.
+	ApplyRetention(policy RetentionPolicy) (int, error)
+	// ForgetUser deletes a user's messages, facts and usage records everywhere.
+	ForgetUser(userID string) (ForgetResult, error)
+
+	// LoadPersonality returns the system prompt, or DefaultPersonality if none is saved.
+	LoadPersonality() (string, error)
+	SavePersonality(personality string) error
+	// LoadStatus returns the saved presence, or nil if none is saved.
+	LoadStatus() (*discordgo.UpdateStatusData, error)
+	SaveStatus(status discordgo.UpdateStatusData) error
+	LoadStatusRotation() (StatusRotation, error)
+	SaveStatusRotation(rotation StatusRotation) error
+	LoadAIStatus() (AIStatus, error)
+	SaveAIStatus(status AIStatus) error
+
+	SaveStatusPreset(preset StatusPreset) error
+	LoadStatusPresets() ([]StatusPreset, error)
+	DeleteStatusPreset(name string) (bool, error)
+
+	LoadUserFacts(userID string) ([]UserFact, error)
+	AddUserFact(userID, content string) error
+	DeleteUserFact(userID string, id uint64) (bool, error)
+
+	ArchiveExchanges(exchanges []ArchivedExchange) error
+	SearchArchive(scope string, vector []float32, k int, minScore float32) ([]ScoredExchange, error)
+
+	SaveDocument(guildID string, doc KBDocument) error
+	LoadDocuments(guildID string) ([]KBDocument, error)
+	DeleteDocument(guildID, name string) (bool, error)
+
+	LoadGuildSettings(guildID string) (GuildSettings, error)
+	SaveGuildSettings(guildID string, settings GuildSettings) error
+
+	SaveFeedback(f Feedback) error
+	LoadFeedback() ([]Feedback, error)
+
+	RecordUsage(guildID, userID string, promptTokens, completionTokens int) error
+	GuildUsage(guildID, period string) (UsageRecord, error)
+	UserUsageFor(guildID, userID, period string) (UsageRecord, error)
+	TopUsers(guildID, period string, limit int) ([]UserUsage, error)
+
+	// Backup writes a consistent snapshot of the database file to w, or
+	// returns ErrBackupUnsupported.
+	Backup(w io.Writer) (int64, error)
+	// Export writes every bucket as JSON; Import merges such a document back in.
+	Export(w io.Writer) error
+	Import(r io.Reader) error
+	// Inspect lists every bucket with its keys and value sizes.
+	Inspect() ([]BucketInfo, error)
+}
+
+// Backend names accepted by Open.
+const (
+	BackendBolt   = "bolt"
+	BackendSQLite = "sqlite"
+	BackendMemory = "memory"
+)
+
+// Open opens the store for backend ("bolt" when empty) at path. Bolt files
+// are migrated to the current schema before Open returns.
+func Open(backend, path string) (Store, error) {
+	switch backend {
+	case "", BackendBolt:
+		kv, err := openBolt(path)
+		if err != nil {
+			return nil, err
+		}
+		return &kvStore{kv: kv}, nil
+	case BackendSQLite:
+		kv, err := openSQLite(path)
+		if err != nil {
+			return nil, err
+		}
+		return &kvStore{kv: kv}, nil
+	case BackendMemory:
+		return NewMemoryStore(), nil
+	default:
+		return nil, fmt.Errorf("unknown database backend %q (want bolt, sqlite or memory)", backend)
+	}
+}
+
+// OpenReadOnly opens an existing bolt or SQLite file for inspection. Unlike
+// Open it never migrates or creates anything, so older files are read as
+// they are; every write fails.
+func OpenReadOnly(backend, path string) (Store, error) {
+	switch backend {
+	case "", BackendBolt:
+		kv, err := openBoltReadOnly(path)
+		if err != nil {
+			return nil, err
+		}
+		return &kvStore{kv: kv}, nil
+	case BackendSQLite:
+		kv, err := openSQLiteReadOnly(path)
+		if err != nil {
+			return nil, err
+		}
+		return &kvStore{kv: kv}, nil
+	default:
+		return nil, fmt.Errorf("database backend %q has no file to open read-only (want bolt or sqlite)", backend)
+	}
+}
+
+// NewMemoryStore returns an empty Store kept entirely in memory.
+func NewMemoryStore() Store {
+	return &kvStore{kv: newMemoryKV()}
+}
+
+// kvStore implements Store on top of any kvBackend.
+type kvStore struct {
+	kv kvBackend
+}
+
+func (s *kvStore) Close() error {
+	return s.kv.Close()
+}
+
+func (s *kvStore) Ping() error {
+	return s.kv.View(func(tx kvTx) error {
+		_, err := tx.Get(metaBucket, schemaVersionKey)
+		return err
+	})
+}
@@ -0,0 +1,324 @@
+package db
+
+import (
+	"path/filepath"
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/bwmarrin/discordgo"
+)
+
+// forEachBackend runs test against a fresh store of every backend.
+func forEachBackend(t *testing.T, test func(t *testing.T, store Store)) {
+	t.Helper()
+	for _, backend := range []string{BackendMemory, BackendBolt, BackendSQLite} {
+		t.Run(backend, func(t *testing.T) {
+			store, err := Open(backend, filepath.Join(t.TempDir(), "bot.db"))
+			if err != nil {
+				t.Fatalf("Open(%q): %v", backend, err)
+			}
+			t.Cleanup(func() { store.Close() })
+			test(t, store)
+		})
+	}
+}
+
+func turn(role, content, userID string, at time.Time) Turn {
+	return Turn{Role: role, Content: content, UserID: userID, CreatedAt: at}
+}
+
+func TestHistory(t *testing.T) {
+	forEachBackend(t, func(t *testing.T, store Store) {
+		history, err := store.LoadHistory(GlobalScope)
+		if err != nil || history != nil {
+			t.Fatalf("LoadHistory on an empty store = %v, %v; want nil, nil", history, err)
+		}
+
+		now := time.Now().UTC().Truncate(time.Second)
+		global := []Turn{turn("user", "hi", "1", now), turn("assistant", "hello", "1", now)}
+		thread := []Turn{turn("user", "in a thread", "2", now)}
+		if err := store.SaveHistory(GlobalScope, global); err != nil {
+			t.Fatalf("SaveHistory: %v", err)
+		}
+		if err := store.SaveHistory(ThreadScope("7"), thread); err != nil {
+			t.Fatalf("SaveHistory: %v", err)
+		}
+
+		for scope, want := range map[string][]Turn{GlobalScope: global, ThreadScope("7"): thread} {
+			got, err := store.LoadHistory(scope)
+			if err != nil {
+				t.Fatalf("LoadHistory(%q): %v", scope, err)
+			}
+			if !reflect.DeepEqual(got, want) {
+				t.Errorf("LoadHistory(%q) = %+v, want %+v", scope, got, want)
+			}
+		}
+
+		err = store.ArchiveExchanges([]ArchivedExchange{
+			{Question: "old thread question", Vector: []float32{1, 0}, Scope: ThreadScope("7")},
+			{Question: "old global question", Vector: []float32{1, 0}},
+		})
+		if err != nil {
+			t.Fatalf("ArchiveExchanges: %v", err)
+		}
+		if err := store.DeleteHistory(ThreadScope("7")); err != nil {
+			t.Fatalf("DeleteHistory: %v", err)
+		}
+		if got, _ := store.LoadHistory(ThreadScope("7")); got != nil {
+			t.Errorf("history after DeleteHistory = %+v, want none", got)
+		}
+		if got, _ := store.SearchArchive(ThreadScope("7"), []float32{1, 0}, 5, 0); len(got) != 0 {
+			t.Errorf("archive after DeleteHistory = %+v, want none", got)
+		}
+		if got, _ := store.SearchArchive(GlobalScope, []float32{1, 0}, 5, 0); len(got) != 1 {
+			t.Errorf("DeleteHistory removed other scopes' archive: %d global exchanges left, want 1", len(got))
+		}
+		if got, _ := store.LoadHistory(GlobalScope); len(got) != 2 {
+			t.Errorf("DeleteHistory removed other scopes' history: %d global turns left, want 2", len(got))
+		}
+	})
+}
+
+func TestRecentUserTurns(t *testing.T) {
+	forEachBackend(t, func(t *testing.T, store Store) {
+		both := []string{GlobalScope, ThreadScope("7")}
+		if turns, err := store.RecentUserTurns(both, 5); err != nil || len(turns) != 0 {
+			t.Fatalf("RecentUserTurns on an empty store = %v, %v; want none", turns, err)
+		}
+
+		now := time.Now().UTC().Truncate(time.Second)
+		mustDo(t, store.SaveHistory(GlobalScope, []Turn{
+			turn("user", "legacy", "", time.Time{}),
+			turn("user", "global old", "1", now.Add(-time.Hour)), turn("assistant", "reply", "1", now.Add(-time.Hour)),
+		}))
+		mustDo(t, store.SaveHistory(ThreadScope("7"), []Turn{
+			turn("user", "thread", "2", now.Add(-time.Minute)), turn("assistant", "reply", "2", now.Add(-time.Minute)),
+			turn("user", "thread newest", "2", now),
+		}))
+
+		tests := []struct {
+			scopes []string
+			limit  int
+			want   []string
+		}{
+			{both, 5, []string{"thread newest", "thread", "global old", "legacy"}},
+			{both, 2, []string{"thread newest", "thread"}},
+			{both, 0, nil},
+			{[]string{GlobalScope}, 5, []string{"global old", "legacy"}},
+			{[]string{ThreadScope("8")}, 5, nil},
+		}
+		for _, tt := range tests {
+			turns, err := store.RecentUserTurns(tt.scopes, tt.limit)
+			if err != nil {
+				t.Fatalf("RecentUserTurns(%q, %d): %v", tt.scopes, tt.limit, err)
+			}
+			var got []string
+			for _, turn := range turns {
+				got = append(got, turn.Content)
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("RecentUserTurns(%q, %d) = %q, want %q", tt.scopes, tt.limit, got, tt.want)
+			}
+		}
+	})
+}
+
+func TestApplyRetention(t *testing.T) {
+	now := time.Now().UTC()
+	old := now.Add(-48 * time.Hour)
+	tests := []struct {
+		name        string
+		policy      RetentionPolicy
+		history     []Turn
+		archived    int
+		wantHistory []string
+		wantArchive int
+		wantRemoved int
+	}{
+		{
+			name:        "no limits",
+			history:     []Turn{turn("user", "a", "1", old), turn("assistant", "b", "1", old)},
+			archived:    2,
+			wantHistory: []string{"a", "b"},
+			wantArchive: 2,
+		},
+		{
+			name:   "max age",
+			policy: RetentionPolicy{MaxAge: 24 * time.Hour},
+			history: []Turn{
+				turn("user", "legacy", "", time.Time{}),
+				turn("user", "old", "1", old), turn("assistant", "old reply", "1", old),
+				turn("user", "new", "1", now), turn("assistant", "new reply", "1", now),
+			},
+			archived:    2,
+			wantHistory: []string{"legacy", "new", "new reply"},
+			wantRemoved: 4,
+		},
+		{
+			name:   "max turns counts history and archive together",
+			policy: RetentionPolicy{MaxTurns: 3},
+			history: []Turn{
+				turn("user", "q1", "1", now), turn("assistant", "a1", "1", now),
+				turn("user", "q2", "1", now), turn("assistant", "a2", "1", now),
+			},
+			archived:    3,
+			wantHistory: []string{"q1", "a1", "q2", "a2"},
+			wantArchive: 1,
+			wantRemoved: 2,
+		},
+		{
+			name:   "max turns trims history on exchange boundaries",
+			policy: RetentionPolicy{MaxTurns: 1},
+			history: []Turn{
+				turn("user", "q1", "1", now), turn("assistant", "a1", "1", now),
+				turn("user", "q2", "1", now), turn("assistant", "a2", "1", now),
+			},
+			archived:    1,
+			wantHistory: []string{"q2", "a2"},
+			wantRemoved: 3,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			forEachBackend(t, func(t *testing.T, store Store) {
+				if err := store.SaveHistory(GlobalScope, tt.history); err != nil {
+					t.Fatalf("SaveHistory: %v", err)
+				}
+				var exchanges []ArchivedExchange
+				for n := 0; n < tt.archived; n++ {
+					exchanges = append(exchanges, ArchivedExchange{Question: "archived", Vector: []float32{1}, CreatedAt: old})
+				}
+				if err := store.ArchiveExchanges(exchanges); err != nil {
+					t.Fatalf("ArchiveExchanges: %v", err)
+				}
+
+				removed, err := store.ApplyRetention(tt.policy)
+				if err != nil {
+					t.Fatalf("ApplyRetention: %v", err)
+				}
+				if removed != tt.wantRemoved {
+					t.Errorf("ApplyRetention removed %d, want %d", removed, tt.wantRemoved)
+				}
+				history, _ := store.LoadHistory(GlobalScope)
+				var got []string
+				for _, turn := range history {
+					got = append(got, turn.Content)
+				}
+				if !reflect.DeepEqual(got, tt.wantHistory) {
+					t.Errorf("history after retention = %q, want %q", got, tt.wantHistory)
+				}
+				archive, _ := store.SearchArchive(GlobalScope, []float32{1}, 100, 0)
+				if len(archive) != tt.wantArchive {
+					t.Errorf("archive after retention has %d exchanges, want %d", len(archive), tt.wantArchive)
+				}
+			})
+		})
+	}
+}
+
+func TestForgetUser(t *testing.T) {
+	forEachBackend(t, func(t *testing.T, store Store) {
+		now := time.Now().UTC()
+		mustDo(t, store.SaveHistory(GlobalScope, []Turn{
+			turn("user", "mine", "1", now), turn("assistant", "reply to me", "1", now),
+			turn("user", "theirs", "2", now), turn("assistant", "reply to them", "2", now),
+		}))
+		mustDo(t, store.SaveHistory(ThreadScope("9"), []Turn{
+			turn("user", "thread", "1", now),
+			turn("user", "legacy", "", time.Time{}), turn("assistant", "legacy reply", "", time.Time{}),
+		}))
+		mustDo(t, store.ArchiveExchanges([]ArchivedExchange{
+			{Question: "mine", Vector: []float32{1}, UserID: "1"},
+			{Question: "theirs", Vector: []float32{1}, UserID: "2"},
+			{Question: "legacy", Vector: []float32{1}},
+		}))
+		mustDo(t, store.AddUserFact("1", "likes tea"))
+		mustDo(t, store.AddUserFact("2", "likes coffee"))
+		mustDo(t, store.RecordUsage("g", "1", 10, 5))
+		mustDo(t, store.RecordUsage("g", "2", 1, 1))
+		mustDo(t, store.SaveFeedback(Feedback{MessageID: "m1", UserID: "2", AuthorID: "1", Rating: RatingUp}))
+		mustDo(t, store.SaveFeedback(Feedback{MessageID: "m2", UserID: "2", AuthorID: "2", Rating: RatingDown}))
+
+		res, err := store.ForgetUser("1")
+		if err != nil {
+			t.Fatalf("ForgetUser: %v", err)
+		}
+		want := ForgetResult{Messages: 3, Archived: 1, Facts: 1, UsageRecords: 1, Feedback: 1, Unattributed: 2}
+		if res != want {
+			t.Errorf("ForgetUser = %+v, want %+v", res, want)
+		}
+
+		history, _ := store.LoadHistory(GlobalScope)
+		if len(history) != 2 || history[0].UserID != "2" {
+			t.Errorf("global history after ForgetUser = %+v, want only user 2's exchange", history)
+		}
+		if thread, _ := store.LoadHistory(ThreadScope("9")); len(thread) != 2 || thread[0].Content != "legacy" {
+			t.Errorf("thread history after ForgetUser = %+v, want only the unattributed exchange", thread)
+		}
+		if archive, _ := store.SearchArchive(GlobalScope, []float32{1}, 10, 0); len(archive) != 2 {
+			t.Errorf("archive after ForgetUser = %+v, want user 2's and the unattributed exchange", archive)
+		}
+		if facts, _ := store.LoadUserFacts("1"); len(facts) != 0 {
+			t.Errorf("facts after ForgetUser = %+v, want none", facts)
+		}
+		if facts, _ := store.LoadUserFacts("2"); len(facts) != 1 {
+			t.Errorf("ForgetUser removed another user's facts: %+v", facts)
+		}
+		day := now.Format(dayLayout)
+		if usage, _ := store.UserUsageFor("g", "1", day); usage.Requests != 0 {
+			t.Errorf("usage after ForgetUser = %+v, want none", usage)
+		}
+		if usage, _ := store.GuildUsage("g", day); usage.Requests != 2 {
+			t.Errorf("guild usage after ForgetUser = %+v, want both requests kept", usage)
+		}
+		if feedback, _ := store.LoadFeedback(); len(feedback) != 1 || feedback[0].MessageID != "m2" {
+			t.Errorf("feedback after ForgetUser = %+v, want only m2", feedback)
+		}
+	})
+}
+
+func TestStatusRoundTrip(t *testing.T) {
+	forEachBackend(t, func(t *testing.T, store Store) {
+		started := time.UnixMilli(1700000000000)
+		status := discordgo.UpdateStatusData{Status: "idle", Activities: []*discordgo.Activity{
+			{Type: discordgo.ActivityTypeGame, Name: "Minecraft", CreatedAt: started},
+			{Type: discordgo.ActivityTypeCustom, State: "brb"},
+		}}
+		check := func(what string, got discordgo.UpdateStatusData) {
+			t.Helper()
+			if got.Status != "idle" || len(got.Activities) != 2 || got.Activities[0].Name != "Minecraft" ||
+				!got.Activities[0].CreatedAt.Equal(started) || got.Activities[1].State != "brb" {
+				t.Errorf("%s after a round trip = %+v, want %+v", what, got, status)
+			}
+		}
+
+		mustDo(t, store.SaveStatus(status))
+		loaded, err := store.LoadStatus()
+		if err != nil || loaded == nil {
+			t.Fatalf("LoadStatus = %v, %v", loaded, err)
+		}
+		check("status", *loaded)
+
+		mustDo(t, store.SaveStatusRotation(StatusRotation{Enabled: true, IntervalMinutes: 5, Entries: []discordgo.UpdateStatusData{status}}))
+		rotation, err := store.LoadStatusRotation()
+		if err != nil || len(rotation.Entries) != 1 || !rotation.Enabled || rotation.IntervalMinutes != 5 {
+			t.Fatalf("LoadStatusRotation = %+v, %v", rotation, err)
+		}
+		check("rotation entry", rotation.Entries[0])
+
+		mustDo(t, store.SaveStatusPreset(StatusPreset{Name: "Away", Status: status, SavedBy: "1"}))
+		presets, err := store.LoadStatusPresets()
+		if err != nil || len(presets) != 1 || presets[0].Name != "Away" || presets[0].SavedBy != "1" {
+			t.Fatalf("LoadStatusPresets = %+v, %v", presets, err)
+		}
+		check("preset", presets[0].Status)
+	})
+}
+
+func mustDo(t *testing.T, err error) {
+	t.Helper()
+	if err != nil {
+		t.Fatal(err)
+	}
+}
@@ -0,0 +1,132 @@
+package db
+
+import (
+	"encoding/json"
+	"sort"
+	"strings"
+	"time"
+)
+
+// usageBucket holds one nested bucket per guild ("dm" for direct messages) with
+// daily token totals under "day:YYYY-MM-DD" and per-user daily totals under
+// "user:<id>:YYYY-MM-DD".
+const usageBucket = "token_usage"
+
+// DMUsageScope is the usage scope for messages outside of any guild.
+const DMUsageScope = "dm"
+
+const dayLayout = "2006-01-02"
+
+// UsageRecord is the token consumption accumulated for a guild or user.
+type UsageRecord struct {
+	Requests         int64 `json:"requests"`
+	PromptTokens     int64 `json:"prompt_tokens"`
+	CompletionTokens int64 `json:"completion_tokens"`
+}
+
+// Total returns prompt plus completion tokens.
+func (u UsageRecord) Total() int64 {
+	return u.PromptTokens + u.CompletionTokens
+}
+
+func (u *UsageRecord) add(o UsageRecord) {
+	u.Requests += o.Requests
+	u.PromptTokens += o.PromptTokens
+	u.CompletionTokens += o.CompletionTokens
+}
+
+// UserUsage is a user's usage over some period.
+type UserUsage struct {
+	UserID string
+	UsageRecord
+}
+
+func usageScope(guildID string) string {
+	if guildID == "" {
+		return DMUsageScope
+	}
+	return guildID
+}
+
+// RecordUsage adds one request's token usage to the guild's and the user's totals for today (UTC).
+func (s *kvStore) RecordUsage(guildID, userID string, promptTokens, completionTokens int) error {
+	day := time.Now().UTC().Format(dayLayout)
+	delta := UsageRecord{Requests: 1, PromptTokens: int64(promptTokens), CompletionTokens: int64(completionTokens)}
+	bucket := bucketPath(usageBucket, usageScope(guildID))
+
+	return s.kv.Update(func(tx kvTx) error {
+		for _, key := range []string{"day:" + day, "user:" + userID + ":" + day} {
+			var rec UsageRecord
+			if _, err := getJSON(tx, bucket, key, &rec); err != nil {
+				return err
+			}
+			rec.add(delta)
+			if err := putJSON(tx, bucket, key, rec); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+}
+
+// GuildUsage sums a guild's usage for keys starting with "day:"+period, where
+// period is a day (2006-01-02) or a month (2006-01).
+func (s *kvStore) GuildUsage(guildID, period string) (UsageRecord, error) {
+	var total UsageRecord
+	err := s.scanUsage(guildID, "day:"+period, func(_ string, rec UsageRecord) {
+		total.add(rec)
+	})
+	return total, err
+}
+
+// UserUsageFor sums one user's usage in a guild for a day or month period.
+func (s *kvStore) UserUsageFor(guildID, userID, period string) (UsageRecord, error) {
+	var total UsageRecord
+	err := s.scanUsage(guildID, "user:"+userID+":"+period, func(_ string, rec UsageRecord) {
+		total.add(rec)
+	})
+	return total, err
+}
+
+// TopUsers returns the guild's heaviest users for a day or month period, most tokens first.
+func (s *kvStore) TopUsers(guildID, period string, limit int) ([]UserUsage, error) {
+	byUser := make(map[string]*UsageRecord)
+	err := s.scanUsage(guildID, "user:", func(key string, rec UsageRecord) {
+		// key is user:<id>:<day>
+		parts := strings.SplitN(key, ":", 3)
+		if len(parts) != 3 || !strings.HasPrefix(parts[2], period) {
+			return
+		}
+		if byUser[parts[1]] == nil {
+			byUser[parts[1]] = &UsageRecord{}
+		}
+		byUser[parts[1]].add(rec)
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	users := make([]UserUsage, 0, len(byUser))
+	for id, rec := range byUser {
+		users = append(users, UserUsage{UserID: id, UsageRecord: *rec})
+	}
+	sort.Slice(users, func(a, b int) bool { return users[a].Total() > users[b].Total() })
+	if len(users) > limit {
+		users = users[:limit]
+	}
+	return users, nil
+}
+
+// scanUsage calls fn for every usage key in the guild's bucket starting with prefix.
+func (s *kvStore) scanUsage(guildID, prefix string, fn func(key string, rec UsageRecord)) error {
+	return s.kv.View(func(tx kvTx) error {
+		return tx.Scan(bucketPath(usageBucket, usageScope(guildID)), prefix, func(key string, v []byte) error {
+			var rec UsageRecord
+			if err := json.Unmarshal(v, &rec); err != nil {
+				return nil // Skip corrupt entries
+			}
+			fn(key, rec)
+			return nil
+		})
+	})
+}
@@ -0,0 +1,68 @@
+package db
+
+import (
+	"reflect"
+	"testing"
+	"time"
+)
+
+// putUsage writes a usage record for an arbitrary day, as RecordUsage would
+// have on that day.
+func putUsage(t *testing.T, store Store, guildID, key string, rec UsageRecord) {
+	t.Helper()
+	mustDo(t, store.(*kvStore).kv.Update(func(tx kvTx) error {
+		return putJSON(tx, bucketPath(usageBucket, usageScope(guildID)), key, rec)
+	}))
+}
+
+func TestUsageTotals(t *testing.T) {
+	forEachBackend(t, func(t *testing.T, store Store) {
+		now := time.Now().UTC()
+		today, month := now.Format(dayLayout), now.Format("2006-01")
+		// Two days that are never today: one earlier this month when
+		// possible, and one in the previous month
+		earlier := time.Date(now.Year(), now.Month(), 1, 0, 0, 0, 0, time.UTC)
+		lastMonth := earlier.AddDate(0, 0, -1).Format(dayLayout)
+
+		mustDo(t, store.RecordUsage("g", "1", 10, 5))
+		mustDo(t, store.RecordUsage("g", "1", 20, 5))
+		mustDo(t, store.RecordUsage("g", "2", 1, 1))
+		mustDo(t, store.RecordUsage("", "1", 100, 100))
+		putUsage(t, store, "g", "day:"+lastMonth, UsageRecord{Requests: 1, PromptTokens: 1000})
+		putUsage(t, store, "g", "user:2:"+lastMonth, UsageRecord{Requests: 1, PromptTokens: 1000})
+		wantMonth := UsageRecord{Requests: 3, PromptTokens: 31, CompletionTokens: 11}
+		if earlier.Format(dayLayout) != today {
+			putUsage(t, store, "g", "day:"+earlier.Format(dayLayout), UsageRecord{Requests: 1, PromptTokens: 7})
+			putUsage(t, store, "g", "user:2:"+earlier.Format(dayLayout), UsageRecord{Requests: 1, PromptTokens: 7})
+			wantMonth = UsageRecord{Requests: 4, PromptTokens: 38, CompletionTokens: 11}
+		}
+
+		tests := []struct {
+			name string
+			get  func() (UsageRecord, error)
+			want UsageRecord
+		}{
+			{"guild today", func() (UsageRecord, error) { return store.GuildUsage("g", today) }, UsageRecord{Requests: 3, PromptTokens: 31, CompletionTokens: 11}},
+			{"guild this month", func() (UsageRecord, error) { return store.GuildUsage("g", month) }, wantMonth},
+			{"guild last month", func() (UsageRecord, error) { return store.GuildUsage("g", lastMonth[:7]) }, UsageRecord{Requests: 1, PromptTokens: 1000}},
+			{"user today", func() (UsageRecord, error) { return store.UserUsageFor("g", "1", today) }, UsageRecord{Requests: 2, PromptTokens: 30, CompletionTokens: 10}},
+			{"DMs are separate", func() (UsageRecord, error) { return store.GuildUsage("", today) }, UsageRecord{Requests: 1, PromptTokens: 100, CompletionTokens: 100}},
+			{"unused guild", func() (UsageRecord, error) { return store.GuildUsage("other", month) }, UsageRecord{}},
+		}
+		for _, tt := range tests {
+			got, err := tt.get()
+			if err != nil || got != tt.want {
+				t.Errorf("%s = %+v, %v; want %+v", tt.name, got, err, tt.want)
+			}
+		}
+
+		top, err := store.TopUsers("g", lastMonth[:7], 5)
+		if want := []UserUsage{{UserID: "2", UsageRecord: UsageRecord{Requests: 1, PromptTokens: 1000}}}; err != nil || !reflect.DeepEqual(top, want) {
+			t.Errorf("TopUsers last month = %+v, %v; want %+v", top, err, want)
+		}
+		top, err = store.TopUsers("g", today, 1)
+		if err != nil || len(top) != 1 || top[0].UserID != "1" || top[0].Total() != 40 {
+			t.Errorf("TopUsers today, limit 1 = %+v, %v; want user 1 with 40 tokens", top, err)
+		}
+	})
+}
@@ -0,0 +1,180 @@
+package handler
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"time"
+
+	"discord-ai-bot/db"
+	"discord-ai-bot/logging"
+	"discord-ai-bot/presence"
+
+	"github.com/bwmarrin/discordgo"
+)
+
+// Custom IDs for the AI status menu opened from /config
+const (
+	buttonIDAIStatus         = "button_ai_status_config"
+	buttonIDAIStatusToggle   = "ai_status_toggle"
+	buttonIDAIStatusInterval = "ai_status_interval"
+	buttonIDAIStatusGenerate = "ai_status_generate"
+	modalIDAIStatusInterval  = "modal_ai_status_interval"
+)
+
+// AI status button -> shows the AI status menu in place of /config's
+func (h *Handler) handleAIStatusMenu(ctx context.Context, s *discordgo.Session, i *discordgo.InteractionCreate) {
+	h.updateAIStatus(ctx, s, i, func(*db.AIStatus) (string, bool) { return "", false })
+}
+
+// Turn on/off button -> toggles AI status
+func (h *Handler) handleAIStatusToggle(ctx context.Context, s *discordgo.Session, i *discordgo.InteractionCreate) {
+	h.updateAIStatus(ctx, s, i, func(settings *db.AIStatus) (string, bool) {
+		settings.Enabled = !settings.Enabled
+		if settings.Enabled {
+			return "AI status turned on. The first line shows up in a moment.\n\n", true
+		}
+		return "AI status turned off.\n\n", true
+	})
+}
+
+// Interval button -> opens a modal for the time between lines
+func (h *Handler) handleAIStatusInterval(ctx context.Context, s *discordgo.Session, i *discordgo.InteractionCreate) {
+	settings, err := h.store.LoadAIStatus()
+	if err != nil {
+		logging.FromContext(ctx).Error("loading AI status failed", "error", err)
+		respondEphemeral(ctx, s, i, "Couldn't load the AI status settings, try again later.")
+		return
+	}
+	modal := discordgo.InteractionResponseData{
+		CustomID: modalIDAIStatusInterval,
+		Title:    "AI Status Interval",
+		Components: []discordgo.MessageComponent{
+			discordgo.ActionsRow{Components: []discordgo.MessageComponent{
+				discordgo.TextInput{
+					CustomID:    "interval_input",
+					Label:       fmt.Sprintf("Time between lines (at least %s)", presence.MinAIStatusInterval),
+					Style:       discordgo.TextInputShort,
+					Placeholder: presence.DefaultAIStatusInterval.String(),
+					Required:    true,
+					MaxLength:   20,
+					Value:       presence.AIStatusInterval(settings).String(),
+				},
+			}},
+		},
+	}
+	err = s.InteractionRespond(i.Interaction, &discordgo.InteractionResponse{Type: discordgo.InteractionResponseModal, Data: &modal})
+	if err != nil {
+		logging.FromContext(ctx).Error("opening AI status interval modal failed", "error", err)
+	}
+}
+
+// AI status interval modal -> saves the interval
+func (h *Handler) handleAIStatusIntervalSubmit(ctx context.Context, s *discordgo.Session, i *discordgo.InteractionCreate) {
+	text := strings.TrimSpace(i.ModalSubmitData().Components[0].(*discordgo.ActionsRow).Components[0].(*discordgo.TextInput).Value)
+	interval, err := time.ParseDuration(text)
+	if err != nil || interval < presence.MinAIStatusInterval {
+		respondEphemeral(ctx, s, i, fmt.Sprintf("The interval must be a duration of at least %s, like 30m or 2h.", presence.MinAIStatusInterval))
+		return
+	}
+	h.updateAIStatus(ctx, s, i, func(settings *db.AIStatus) (string, bool) {
+		settings.IntervalMinutes = int(interval / time.Minute)
+		return "Interval saved.\n\n", true
+	})
+}
+
+// Generate now button -> writes a new line right away
+func (h *Handler) handleAIStatusGenerate(ctx context.Context, s *discordgo.Session, i *discordgo.InteractionCreate) {
+	// The LLM can take longer than the 3 seconds Discord waits
+	err := s.InteractionRespond(i.Interaction, &discordgo.InteractionResponse{
+		Type: discordgo.InteractionResponseDeferredMessageUpdate,
+	})
+	if err != nil {
+		logging.FromContext(ctx).Error("deferring AI status generation failed", "error", err)
+		return
+	}
+
+	prefix := "New line written.\n\n"
+	if _, err := h.presence.Generate(ctx); err != nil {
+		logging.FromContext(ctx).Warn("generating AI status failed", "error", err)
+		prefix = fmt.Sprintf("Couldn't write a usable line (%s). Try again.\n\n", err)
+	} else {
+		h.presence.Refresh()
+	}
+
+	settings, err := h.store.LoadAIStatus()
+	if err != nil {
+		logging.FromContext(ctx).Error("loading AI status failed", "error", err)
+		followupEphemeral(ctx, s, i, "Couldn't load the AI status settings, try again later.")
+		return
+	}
+	data := aiStatusResponse(settings, prefix)
+	_, err = s.InteractionResponseEdit(i.Interaction, &discordgo.WebhookEdit{Content: &data.Content, Components: &data.Components})
+	if err != nil {
+		logging.FromContext(ctx).Error("editing AI status menu failed", "error", err)
+	}
+}
+
+// updateAIStatus applies change to the saved AI status settings and redraws
+// the AI status menu, like updateRotation.
+func (h *Handler) updateAIStatus(ctx context.Context, s *discordgo.Session, i *discordgo.InteractionCreate, change func(*db.AIStatus) (string, bool)) {
+	settings, err := h.store.LoadAIStatus()
+	if err != nil {
+		logging.FromContext(ctx).Error("loading AI status failed", "error", err)
+		respondEphemeral(ctx, s, i, "Couldn't load the AI status settings, try again later.")
+		return
+	}
+	notice, changed := change(&settings)
+	if changed {
+		if err := h.store.SaveAIStatus(settings); err != nil {
+			logging.FromContext(ctx).Error("saving AI status failed", "error", err)
+			respondEphemeral(ctx, s, i, "Couldn't save the AI status settings, try again later.")
+			return
+		}
+		h.presence.Refresh()
+		logging.FromContext(ctx).Info("AI status updated", "enabled", settings.Enabled, "interval_minutes", settings.IntervalMinutes)
+	}
+
+	err = s.InteractionRespond(i.Interaction, &discordgo.InteractionResponse{
+		Type: discordgo.InteractionResponseUpdateMessage,
+		Data: aiStatusResponse(settings, notice),
+	})
+	if err != nil {
+		logging.FromContext(ctx).Error("updating AI status menu failed", "error", err)
+	}
+}
+
+// aiStatusResponse renders the AI status menu.
+func aiStatusResponse(settings db.AIStatus, prefix string) *discordgo.InteractionResponseData {
+	state := "off"
+	if settings.Enabled {
+		state = "on"
+	}
+
+	var sb strings.Builder
+	sb.WriteString(prefix)
+	fmt.Fprintf(&sb, "**AI Status** is **%s**, writing a new line every %s.\n", state, presence.AIStatusInterval(settings))
+	sb.WriteString("The bot picks its own custom status from its personality and recent topics in the shared channel history (never threads). " +
+		"While on, it replaces the status rotation and the saved activity.\n\n")
+	if settings.Line == "" {
+		sb.WriteString("No line written yet.")
+	} else {
+		fmt.Fprintf(&sb, "Current line: *%s* (written <t:%d:R>)", settings.Line, settings.GeneratedAt.Unix())
+	}
+
+	toggle := discordgo.Button{Label: "Turn On", Style: discordgo.SuccessButton, CustomID: buttonIDAIStatusToggle}
+	if settings.Enabled {
+		toggle.Label, toggle.Style = "Turn Off", discordgo.DangerButton
+	}
+	return &discordgo.InteractionResponseData{
+		Content: truncate(sb.String(), 2000),
+		Components: []discordgo.MessageComponent{
+			discordgo.ActionsRow{Components: []discordgo.MessageComponent{
+				toggle,
+				discordgo.Button{Label: "Interval", Style: discordgo.SecondaryButton, CustomID: buttonIDAIStatusInterval},
+				discordgo.Button{Label: "Generate Now", Style: discordgo.PrimaryButton, CustomID: buttonIDAIStatusGenerate},
+				discordgo.Button{Label: "Back", Style: discordgo.SecondaryButton, CustomID: buttonIDConfigBack},
+			}},
+		},
+	}
+}
@@ -0,0 +1,88 @@
+package handler
+
+import (
+	"context"
+	"os"
+	"strings"
+	"time"
+
+	"discord-ai-bot/ai"
+	"discord-ai-bot/logging"
+	"discord-ai-bot/metrics"
+
+	"github.com/bwmarrin/discordgo"
+)
+
+// /ask -> answers a prompt like a mention would, delivered as a followup
+func (h *Handler) handleAskCommand(ctx context.Context, s *discordgo.Session, i *discordgo.InteractionCreate) {
+	req := chatRequest{
+		GuildID:   i.GuildID,
+		ChannelID: i.ChannelID,
+		Author:    interactionUser(i),
+		Scope:     historyScope(s, i.ChannelID),
+	}
+	ephemeral := false
+	for _, opt := range i.ApplicationCommandData().Options {
+		switch opt.Name {
+		case "prompt":
+			req.Prompt = strings.TrimSpace(opt.StringValue())
+		case "ephemeral":
+			ephemeral = opt.BoolValue()
+		case "persona":
+			req.Persona = strings.TrimSpace(opt.StringValue())
+		case "model":
+			req.Model = strings.TrimSpace(opt.StringValue())
+		}
+	}
+	if req.Model != "" && !ai.ModelAllowed(req.Model) {
+		allowed := ai.ConfiguredModel()
+		if extra := strings.TrimSpace(os.Getenv("ASK_MODELS")); extra != "" {
+			allowed += ", " + extra
+		}
+		respondEphemeral(ctx, s, i, "That model isn't available. Allowed models: "+allowed)
+		return
+	}
+
+	var flags discordgo.MessageFlags
+	if ephemeral {
+		flags = discordgo.MessageFlagsEphemeral
+	}
+
+	// The AI can take longer than the 3 second interaction deadline
+	err := s.InteractionRespond(i.Interaction, &discordgo.InteractionResponse{
+		Type: discordgo.InteractionResponseDeferredChannelMessageWithSource,
+		Data: &discordgo.InteractionResponseData{Flags: flags},
+	})
+	if err != nil {
+		logging.FromContext(ctx).Error("deferring /ask command failed", "error", err)
+		return
+	}
+
+	handledAt := time.Now()
+	res := h.chat(ctx, s, req)
+	defer func() {
+		metrics.Messages.Inc(res.Outcome)
+		logging.FromContext(ctx).Info("ask handled", "outcome", res.Outcome, "latency_ms", time.Since(handledAt).Milliseconds())
+	}()
+
+	params := &discordgo.WebhookParams{
+		Content:         sanitizeMentions(s, i.GuildID, res.Content),
+		AllowedMentions: allowedMentions(),
+		Flags:           flags,
+	}
+	// Private answers aren't in the history the buttons work on
+	if !ephemeral {
+		params.Components = res.components()
+	}
+	msg, err := s.FollowupMessageCreate(i.Interaction, true, params)
+	if err != nil {
+		metrics.Errors.Inc("discord_send")
+		logging.FromContext(ctx).Error("sending /ask answer failed", "error", err)
+		return
+	}
+
+	// Private answers stay out of the shared history
+	if !ephemeral {
+		h.saveExchange(ctx, req.Scope, res.Exchange, "", msg.ID)
+	}
+}
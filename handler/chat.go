@@ -0,0 +1,159 @@
+package handler
+
+import (
+	"context"
+	"time"
+
+	"discord-ai-bot/ai"
+	"discord-ai-bot/db"
+	"discord-ai-bot/logging"
+
+	"github.com/bwmarrin/discordgo"
+)
+
+// chatRequest is one prompt for the AI, from a mention, /ask or /chat.
+type chatRequest struct {
+	GuildID   string
+	ChannelID string
+	Author    *discordgo.User
+	Prompt    string
+	// Scope is the history the prompt continues: GlobalScope or a thread's.
+	Scope string
+	// Premoderated skips the input check for prompts the caller already checked.
+	Premoderated bool
+	// Persona replaces the saved personality when set.
+	Persona string
+	// Model overrides CEREBRAS_MODEL when set.
+	Model string
+	// Typing shows the typing indicator in ChannelID while the AI works.
+	Typing bool
+}
+
+// chatResult is what the bot should post for a chatRequest.
+type chatResult struct {
+	Content string
+	// Outcome labels the result for metrics and logs.
+	Outcome string
+	// Exchange holds the user and assistant turns to append to the history
+	// once the reply is posted. It is nil when nothing should be stored.
+	Exchange []db.Turn
+}
+
+// chat runs a prompt through moderation, quotas and the AI with the same
+// context (personality, facts, archive, knowledge base, history) for every
+// entry point. It never posts anything itself.
+func (h *Handler) chat(ctx context.Context, s *discordgo.Session, req chatRequest) chatResult {
+	logger := logging.FromContext(ctx)
+
+	if req.Prompt == "" {
+		return chatResult{Content: "Hello! Ping me with a question.", Outcome: "empty"}
+	}
+
+	// Moderate the prompt before it is stored or sent anywhere
+	if !req.Premoderated && !h.moderateInput(ctx, s, req, req.Prompt) {
+		return chatResult{Content: "I can't help with that message.", Outcome: "refused_input"}
+	}
+	// A persona replaces the system prompt, so it gets the same check even
+	// when the prompt was already moderated
+	if req.Persona != "" && !h.moderateInput(ctx, s, req, req.Persona) {
+		return chatResult{Content: "I can't use that persona.", Outcome: "refused_input"}
+	}
+
+	// "remember that ..." stores a long-term fact instead of asking the AI
+	if fact, ok := parseRememberRequest(req.Prompt); ok {
+		if err := h.store.AddUserFact(req.Author.ID, fact); err != nil {
+			logger.Error("saving fact failed", "error", err)
+			return chatResult{Content: "Couldn't save that, try again later.", Outcome: "error"}
+		}
+		return chatResult{Content: "Got it, I'll remember that.", Outcome: "remembered"}
+	}
+
+	// Refuse politely once the guild has used up its token quota
+	if notice, exceeded := h.quotaExceeded(ctx, req.GuildID); exceeded {
+		return chatResult{Content: notice, Outcome: "quota_exceeded"}
+	}
+
+	history, err := h.store.LoadHistory(req.Scope)
+	if err != nil {
+		logger.Error("loading history failed", "error", err)
+		return chatResult{Content: "AI Error. Check logs.", Outcome: "error"}
+	}
+	return h.answer(ctx, s, req, history)
+}
+
+// answer asks the AI to reply to req.Prompt following history. Callers have
+// already checked the prompt and the guild's quota.
+func (h *Handler) answer(ctx context.Context, s *discordgo.Session, req chatRequest, history []db.Turn) chatResult {
+	logger := logging.FromContext(ctx)
+
+	if req.Typing {
+		s.ChannelTyping(req.ChannelID)
+	}
+	startTime := time.Now()
+
+	personality := req.Persona
+	if personality == "" {
+		var err error
+		personality, err = h.store.LoadPersonality()
+		if err != nil {
+			logger.Warn("loading personality failed, using default", "error", err)
+			personality = db.DefaultPersonality
+		}
+	}
+
+	kbContext, sources := h.kbPrompt(ctx, req.GuildID, req.Prompt)
+	personality += h.factsPrompt(ctx, req.Author, req.Prompt) + h.retrievalPrompt(ctx, req.Scope, req.Prompt) + kbContext
+
+	userTurn := db.Turn{Role: "user", Content: req.Prompt, UserID: req.Author.ID, CreatedAt: time.Now().UTC()}
+
+	fullHistory := []ai.Message{{Role: "system", Content: personality}}
+	fullHistory = append(fullHistory, turnMessages(history)...)
+	fullHistory = append(fullHistory, ai.Message{Role: userTurn.Role, Content: userTurn.Content})
+
+	completion, err := ai.GetCerebrasCompletion(ctx, req.Model, fullHistory)
+
+	if elapsed := time.Since(startTime); req.Typing && elapsed < time.Second {
+		time.Sleep(time.Second - elapsed)
+	}
+
+	if err != nil {
+		logger.Error("cerebras API error", "error", err)
+		return chatResult{Content: "AI Error. Check logs.", Outcome: "error"}
+	}
+	if err := h.store.RecordUsage(req.GuildID, req.Author.ID, completion.Usage.PromptTokens, completion.Usage.CompletionTokens); err != nil {
+		logger.Error("recording token usage failed", "error", err)
+	}
+
+	reply, allowed := h.moderateOutput(ctx, s, req, completion.Content)
+	if !allowed {
+		return chatResult{Content: "I came up with a reply I'm not allowed to send. Try asking differently.", Outcome: "refused_output"}
+	}
+
+	assistantTurn := db.Turn{Role: "assistant", Content: reply, UserID: req.Author.ID, CreatedAt: time.Now().UTC()}
+	return chatResult{
+		Content:  citeSources(reply, sources),
+		Outcome:  "replied",
+		Exchange: []db.Turn{userTurn, assistantTurn},
+	}
+}
+
+// saveExchange appends a posted exchange to a scope's history, archiving
+// whatever falls out of the window. promptID is the user's message, if there
+// is one, and replyID the bot's reply.
+func (h *Handler) saveExchange(ctx context.Context, scope string, exchange []db.Turn, promptID, replyID string) {
+	if len(exchange) != 2 {
+		return
+	}
+	exchange[0].MessageID = promptID
+	exchange[1].MessageID = replyID
+	logger := logging.FromContext(ctx)
+	history, err := h.store.LoadHistory(scope)
+	if err != nil {
+		logger.Error("loading history failed", "error", err)
+		return
+	}
+	history = append(history, exchange...)
+	if err := h.store.SaveHistory(scope, h.trimHistory(ctx, scope, history)); err != nil {
+		logger.Error("saving history failed", "error", err)
+	}
+}
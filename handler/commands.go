@@ -0,0 +1,238 @@
+package handler
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+
+	"discord-ai-bot/db"
+
+	"github.com/bwmarrin/discordgo"
+)
+
+// command ties a slash command definition to the method that handles it.
+// Adding a command means adding one entry to commandRegistry.
+type command struct {
+	def    *discordgo.ApplicationCommand
+	handle func(h *Handler, ctx context.Context, s *discordgo.Session, i *discordgo.InteractionCreate)
+}
+
+var (
+	manageGuild  int64 = discordgo.PermissionManageServer
+	dmPermission       = false
+	minQuota           = float64(-1)
+)
+
+// commandRegistry is every slash command the bot offers.
+var commandRegistry = []command{
+	{
+		def: &discordgo.ApplicationCommand{
+			Name:                     "config",
+			Description:              "Open the UI to edit Bot Status, Activity, and RPC Assets",
+			DefaultMemberPermissions: &manageGuild,
+			DMPermission:             &dmPermission,
+		},
+		handle: (*Handler).handleConfigCommand,
+	},
+	{
+		def: &discordgo.ApplicationCommand{
+			Name:                     "personality",
+			Description:              "Open the UI to edit the AI Personality",
+			DefaultMemberPermissions: &manageGuild,
+			DMPermission:             &dmPermission,
+		},
+		handle: (*Handler).handlePersonalityCommand,
+	},
+	{
+		def: &discordgo.ApplicationCommand{
+			Name:        "ask",
+			Description: "Ask the AI a question without pinging the bot",
+			Options: []*discordgo.ApplicationCommandOption{
+				{Type: discordgo.ApplicationCommandOptionString, Name: "prompt", Description: "Your question", Required: true, MaxLength: 2000},
+				{Type: discordgo.ApplicationCommandOptionBoolean, Name: "ephemeral", Description: "Only show the answer to you (it isn't added to the conversation history)"},
+				{Type: discordgo.ApplicationCommandOptionString, Name: "persona", Description: "System prompt to use instead of the saved personality", MaxLength: 2000},
+				{Type: discordgo.ApplicationCommandOptionString, Name: "model", Description: "Model to use instead of the default (from the ASK_MODELS allowlist)", MaxLength: 100},
+			},
+		},
+		handle: (*Handler).handleAskCommand,
+	},
+	{
+		def: &discordgo.ApplicationCommand{
+			Name:         "chat",
+			Description:  "Start a conversation thread where the bot answers without pings",
+			DMPermission: &dmPermission,
+			Options: []*discordgo.ApplicationCommandOption{
+				{Type: discordgo.ApplicationCommandOptionString, Name: "prompt", Description: "What do you want to talk about?", Required: true, MaxLength: 2000},
+			},
+		},
+		handle: (*Handler).handleChatCommand,
+	},
+	{
+		def: &discordgo.ApplicationCommand{
+			Name:                     "threads",
+			Description:              "Choose whether pings in a channel start conversation threads",
+			DefaultMemberPermissions: &manageGuild,
+			DMPermission:             &dmPermission,
+			Options: []*discordgo.ApplicationCommandOption{
+				{Type: discordgo.ApplicationCommandOptionBoolean, Name: "enabled", Description: "Start a thread for every ping in the channel", Required: true},
+				{
+					Type:         discordgo.ApplicationCommandOptionChannel,
+					Name:         "channel",
+					Description:  "Channel to change (defaults to this one)",
+					ChannelTypes: []discordgo.ChannelType{discordgo.ChannelTypeGuildText},
+				},
+			},
+		},
+		handle: (*Handler).handleThreadsCommand,
+	},
+	{
+		def: &discordgo.ApplicationCommand{
+			Name:        "remember",
+			Description: "Ask the bot to remember something about you",
+			Options: []*discordgo.ApplicationCommandOption{
+				{
+					Type:        discordgo.ApplicationCommandOptionString,
+					Name:        "fact",
+					Description: "What should the bot remember? e.g. I use Arch",
+					Required:    true,
+					MaxLength:   db.MaxFactLength,
+				},
+			},
+		},
+		handle: (*Handler).handleRememberCommand,
+	},
+	{
+		def: &discordgo.ApplicationCommand{
+			Name:        "memories",
+			Description: "View and delete the things the bot remembers about you",
+		},
+		handle: (*Handler).handleMemoriesCommand,
+	},
+	{
+		def: &discordgo.ApplicationCommand{
+			Name:                     "kb",
+			Description:              "Manage the server knowledge base the bot answers from",
+			DefaultMemberPermissions: &manageGuild,
+			DMPermission:             &dmPermission,
+			Options: []*discordgo.ApplicationCommandOption{
+				{
+					Type:        discordgo.ApplicationCommandOptionSubCommand,
+					Name:        "add",
+					Description: "Upload a text document (FAQ, rules, ...)",
+					Options: []*discordgo.ApplicationCommandOption{
+						{Type: discordgo.ApplicationCommandOptionAttachment, Name: "file", Description: "A .txt, .md, .csv or .json file", Required: true},
+						{Type: discordgo.ApplicationCommandOptionString, Name: "name", Description: "Document name (defaults to the file name)", MaxLength: 100},
+					},
+				},
+				{
+					Type:        discordgo.ApplicationCommandOptionSubCommand,
+					Name:        "list",
+					Description: "List the documents in the knowledge base",
+				},
+				{
+					Type:        discordgo.ApplicationCommandOptionSubCommand,
+					Name:        "remove",
+					Description: "Remove a document from the knowledge base",
+					Options: []*discordgo.ApplicationCommandOption{
+						{Type: discordgo.ApplicationCommandOptionString, Name: "name", Description: "Document name as shown by /kb list", Required: true},
+					},
+				},
+			},
+		},
+		handle: (*Handler).handleKBCommand,
+	},
+	{
+		def: &discordgo.ApplicationCommand{
+			Name:                     "moderation",
+			Description:              "View or change how strictly prompts and AI replies are moderated",
+			DefaultMemberPermissions: &manageGuild,
+			DMPermission:             &dmPermission,
+			Options: []*discordgo.ApplicationCommandOption{
+				{
+					Type:        discordgo.ApplicationCommandOptionString,
+					Name:        "level",
+					Description: "Moderation strictness",
+					Choices: []*discordgo.ApplicationCommandOptionChoice{
+						{Name: "off", Value: "off"},
+						{Name: "low (redact replies)", Value: "low"},
+						{Name: "medium (refuse prompts, redact replies)", Value: "medium"},
+						{Name: "high (also use the moderation model)", Value: "high"},
+					},
+				},
+				{
+					Type:         discordgo.ApplicationCommandOptionChannel,
+					Name:         "log_channel",
+					Description:  "Channel that receives moderation reports",
+					ChannelTypes: []discordgo.ChannelType{discordgo.ChannelTypeGuildText},
+				},
+				{
+					Type:        discordgo.ApplicationCommandOptionBoolean,
+					Name:        "disable_log",
+					Description: "Stop sending moderation reports",
+				},
+			},
+		},
+		handle: (*Handler).handleModerationCommand,
+	},
+	{
+		def: &discordgo.ApplicationCommand{
+			Name:        "usage",
+			Description: "Show AI token usage for this server and for you",
+		},
+		handle: (*Handler).handleUsageCommand,
+	},
+	{
+		def: &discordgo.ApplicationCommand{
+			Name:                     "quota",
+			Description:              "Set this server's daily and monthly AI token quotas",
+			DefaultMemberPermissions: &manageGuild,
+			DMPermission:             &dmPermission,
+			Options: []*discordgo.ApplicationCommandOption{
+				{Type: discordgo.ApplicationCommandOptionInteger, Name: "daily", Description: "Tokens per day (0 = bot default, -1 = unlimited)", MinValue: &minQuota},
+				{Type: discordgo.ApplicationCommandOptionInteger, Name: "monthly", Description: "Tokens per month (0 = bot default, -1 = unlimited)", MinValue: &minQuota},
+			},
+		},
+		handle: (*Handler).handleQuotaCommand,
+	},
+	{
+		def: &discordgo.ApplicationCommand{
+			Name:        "forgetme",
+			Description: "Delete your messages, memories and usage records from the bot",
+		},
+		handle: (*Handler).handleForgetMeCommand,
+	},
+}
+
+// commandHandlers indexes commandRegistry by name.
+var commandHandlers = func() map[string]command {
+	byName := make(map[string]command, len(commandRegistry))
+	for _, c := range commandRegistry {
+		if _, dup := byName[c.def.Name]; dup {
+			panic("duplicate slash command " + c.def.Name)
+		}
+		byName[c.def.Name] = c
+	}
+	return byName
+}()
+
+// SyncCommands makes the registered slash commands exactly match
+// commandRegistry with one bulk overwrite, which also removes commands that
+// are no longer defined. With a guild ID the commands are registered to that
+// guild only, where changes show up instantly; use it for development.
+func SyncCommands(s *discordgo.Session, guildID string) error {
+	defs := make([]*discordgo.ApplicationCommand, 0, len(commandRegistry))
+	for _, c := range commandRegistry {
+		defs = append(defs, c.def)
+	}
+
+	registered, err := s.ApplicationCommandBulkOverwrite(s.State.User.ID, guildID, defs)
+	if err != nil {
+		return fmt.Errorf("overwriting slash commands: %w", err)
+	}
+	scope := "global"
+	if guildID != "" {
+		scope = "guild " + guildID
+	}
+	slog.Info("slash commands synced", "scope", scope, "count", len(registered))
+	return nil
+}
@@ -0,0 +1,20 @@
+package handler
+
+import "testing"
+
+func TestAdminCommandsNeedManageServer(t *testing.T) {
+	for _, name := range []string{"config", "personality", "threads", "kb", "moderation", "quota"} {
+		t.Run(name, func(t *testing.T) {
+			c, ok := commandHandlers[name]
+			if !ok {
+				t.Fatalf("no /%s command", name)
+			}
+			if perms := c.def.DefaultMemberPermissions; perms == nil || *perms != manageGuild {
+				t.Errorf("/%s default permissions = %v, want Manage Server", name, perms)
+			}
+			if dm := c.def.DMPermission; dm == nil || *dm {
+				t.Errorf("/%s is usable in DMs", name)
+			}
+		})
+	}
+}
@@ -0,0 +1,81 @@
+package handler
+
+import (
+	"context"
+	"fmt"
+	"strings"
+
+	"discord-ai-bot/logging"
+	"discord-ai-bot/presence"
+
+	"github.com/bwmarrin/discordgo"
+)
+
+// Custom IDs for editing the custom status from /config
+const (
+	buttonIDCustomStatus = "button_custom_status_config"
+	modalIDCustomStatus  = "modal_custom_status"
+)
+
+// Custom status button -> opens a modal for the custom status text and emoji
+func (h *Handler) handleCustomStatus(ctx context.Context, s *discordgo.Session, i *discordgo.InteractionCreate) {
+	status, err := h.store.LoadStatus()
+	if err != nil {
+		logging.FromContext(ctx).Error("loading status failed", "error", err)
+		respondEphemeral(ctx, s, i, "Couldn't load the current status, try again later.")
+		return
+	}
+	text, emoji := "", ""
+	if custom := customActivity(status); custom != nil {
+		text, emoji = custom.State, emojiText(custom.Emoji)
+	}
+
+	modal := discordgo.InteractionResponseData{
+		CustomID: modalIDCustomStatus,
+		Title:    "Custom Status",
+		Components: []discordgo.MessageComponent{
+			discordgo.ActionsRow{Components: []discordgo.MessageComponent{
+				discordgo.TextInput{CustomID: "custom_text_input", Label: "Status Text (leave both empty to clear)", Style: discordgo.TextInputShort, Placeholder: "Event tonight at 8!", Required: false, MaxLength: presence.MaxActivityText, Value: text},
+			}},
+			discordgo.ActionsRow{Components: []discordgo.MessageComponent{
+				discordgo.TextInput{CustomID: "custom_emoji_input", Label: "Emoji (🎉 or a server emoji like <:name:id>)", Style: discordgo.TextInputShort, Placeholder: "Optional", Required: false, MaxLength: 60, Value: emoji},
+			}},
+		},
+	}
+	err = s.InteractionRespond(i.Interaction, &discordgo.InteractionResponse{Type: discordgo.InteractionResponseModal, Data: &modal})
+	if err != nil {
+		logging.FromContext(ctx).Error("opening custom status modal failed", "error", err)
+	}
+}
+
+// Custom status modal -> previews the presence with the new custom status
+func (h *Handler) handleCustomStatusSubmit(ctx context.Context, s *discordgo.Session, i *discordgo.InteractionCreate) {
+	data := i.ModalSubmitData()
+	text := strings.TrimSpace(data.Components[0].(*discordgo.ActionsRow).Components[0].(*discordgo.TextInput).Value)
+	emojiInput := data.Components[1].(*discordgo.ActionsRow).Components[0].(*discordgo.TextInput).Value
+	emoji, ok := parseEmoji(emojiInput)
+	if !ok {
+		respondEphemeral(ctx, s, i, problemsMessage([]string{fmt.Sprintf("%q isn't an emoji. Use a single emoji like 🎉, or a server emoji typed as <:name:id>.", strings.TrimSpace(emojiInput))}))
+		return
+	}
+
+	// Only the custom status is replaced; the rest of the saved status is
+	// whatever it is when the preview is applied
+	h.updateStatus(ctx, s, i, "Custom status", func(status *discordgo.UpdateStatusData) {
+		kept := status.Activities[:0]
+		for _, act := range status.Activities {
+			if act.Type != discordgo.ActivityTypeCustom {
+				kept = append(kept, act)
+			}
+		}
+		status.Activities = kept
+		if text != "" || emoji.Name != "" {
+			status.Activities = append(status.Activities, &discordgo.Activity{
+				Type:  discordgo.ActivityTypeCustom,
+				Name:  "Custom Status",
+				State: text,
+				Emoji: emoji,
+			})
+		}
+	})
+}
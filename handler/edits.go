@@ -0,0 +1,196 @@
+package handler
+
+import (
+	"context"
+
+	"discord-ai-bot/db"
+	"discord-ai-bot/logging"
+
+	"github.com/bwmarrin/discordgo"
+)
+
+// messageScope returns the history scope a message's turn lives in. A ping
+// that started a conversation thread sits in the parent channel but belongs
+// to the thread, which shares the message's ID.
+func messageScope(s *discordgo.Session, channelID, messageID string) string {
+	if ch, err := s.State.Channel(messageID); err == nil && ch.IsThread() && ch.OwnerID == s.State.User.ID {
+		return db.ThreadScope(messageID)
+	}
+	return historyScope(s, channelID)
+}
+
+// replyChannel returns where the bot replied to a message in scope: inside
+// the thread for thread starters, in the message's own channel otherwise.
+func replyChannel(scope, channelID, messageID string) string {
+	if scope == db.ThreadScope(messageID) {
+		return messageID
+	}
+	return channelID
+}
+
+// turnIndex returns the index of the turn posted as messageID, or -1.
+func turnIndex(history []db.Turn, messageID string) int {
+	for idx := len(history) - 1; idx >= 0; idx-- {
+		if history[idx].MessageID == messageID {
+			return idx
+		}
+	}
+	return -1
+}
+
+// MessageUpdate keeps the history in step with edited prompts. With
+// REPLY_REGENERATE_ON_EDIT the bot also rewrites its reply to the latest
+// exchange to answer the new text.
+func (h *Handler) MessageUpdate(s *discordgo.Session, m *discordgo.MessageUpdate) {
+	// Embed unfurls also arrive as updates, without an author or content
+	if m.Author == nil || m.Author.ID == s.State.User.ID {
+		return
+	}
+	if !beginWork() {
+		return
+	}
+	defer endWork()
+
+	ctx, logger := messageContext(m.Message)
+	scope := messageScope(s, m.ChannelID, m.ID)
+	history, err := h.store.LoadHistory(scope)
+	if err != nil {
+		logger.Error("loading history failed", "error", err)
+		return
+	}
+	idx := turnIndex(history, m.ID)
+	if idx < 0 || history[idx].Role != "user" {
+		return
+	}
+	prompt := promptText(s, m.Content)
+	if prompt == history[idx].Content {
+		return
+	}
+
+	req := chatRequest{GuildID: m.GuildID, ChannelID: m.ChannelID, Author: m.Author, Prompt: prompt, Scope: scope}
+	if prompt == "" || !h.moderateInput(ctx, s, req, prompt) {
+		// Nothing usable is left, so forget the exchange altogether
+		h.dropTurns(ctx, scope, m.ID)
+		logger.Info("edited prompt removed from history")
+		return
+	}
+
+	h.replaceTurn(ctx, scope, m.ID, prompt)
+	logger.Info("edited prompt updated in history")
+
+	// Only the latest reply is rewritten; later turns built on older ones
+	replyIdx := idx + 1
+	if !envBool("REPLY_REGENERATE_ON_EDIT", false) || replyIdx >= len(history) ||
+		history[replyIdx].Role != "assistant" || latestReply(history, history[replyIdx].MessageID) != replyIdx {
+		return
+	}
+	if _, exceeded := h.quotaExceeded(ctx, m.GuildID); exceeded {
+		return
+	}
+	res := h.answer(ctx, s, req, history[:idx])
+	if res.Exchange == nil {
+		return
+	}
+	content := sanitizeMentions(s, m.GuildID, res.Content)
+	_, err = s.ChannelMessageEditComplex(&discordgo.MessageEdit{
+		ID:              history[replyIdx].MessageID,
+		Channel:         replyChannel(scope, m.ChannelID, m.ID),
+		Content:         &content,
+		Components:      &replyButtons,
+		AllowedMentions: allowedMentions(),
+	})
+	if err != nil {
+		logger.Error("editing reply to edited prompt failed", "error", err)
+		return
+	}
+	h.replaceTurn(ctx, scope, history[replyIdx].MessageID, res.Exchange[1].Content)
+	logger.Info("reply regenerated for edited prompt")
+}
+
+// MessageDelete drops deleted prompts and replies from the history. Either
+// one takes the rest of its exchange with it; with REPLY_DELETE_ON_DELETE the bot also
+// deletes the reply message.
+func (h *Handler) MessageDelete(s *discordgo.Session, m *discordgo.MessageDelete) {
+	if !beginWork() {
+		return
+	}
+	defer endWork()
+
+	ctx, _ := messageContext(m.Message)
+	h.forgetMessages(ctx, s, m.ChannelID, []string{m.ID})
+}
+
+// MessageDeleteBulk is MessageDelete for purges.
+func (h *Handler) MessageDeleteBulk(s *discordgo.Session, m *discordgo.MessageDeleteBulk) {
+	if !beginWork() {
+		return
+	}
+	defer endWork()
+
+	ctx, _ := messageContext(&discordgo.Message{GuildID: m.GuildID, ChannelID: m.ChannelID})
+	h.forgetMessages(ctx, s, m.ChannelID, m.Messages)
+}
+
+// forgetMessages removes the turns posted as messageIDs from their history
+// scopes, deleting orphaned replies when configured to.
+func (h *Handler) forgetMessages(ctx context.Context, s *discordgo.Session, channelID string, messageIDs []string) {
+	logger := logging.FromContext(ctx)
+	deleteReplies := envBool("REPLY_DELETE_ON_DELETE", false)
+	for _, id := range messageIDs {
+		scope := messageScope(s, channelID, id)
+		reply := h.dropTurns(ctx, scope, id)
+		if reply == "" || !deleteReplies {
+			continue
+		}
+		if err := s.ChannelMessageDelete(replyChannel(scope, channelID, id), reply); err != nil {
+			logger.Warn("deleting reply to deleted prompt failed", "reply_id", reply, "error", err)
+		}
+	}
+}
+
+// dropTurns removes the exchange containing the turn posted as messageID
+// from a scope's history. The reply's message ID is returned when a prompt
+// was deleted, so the caller can delete the reply too.
+func (h *Handler) dropTurns(ctx context.Context, scope, messageID string) string {
+	logger := logging.FromContext(ctx)
+	history, err := h.store.LoadHistory(scope)
+	if err != nil {
+		logger.Error("loading history failed", "error", err)
+		return ""
+	}
+	kept, reply, removed := dropExchange(history, messageID)
+	if removed == 0 {
+		return ""
+	}
+	if err := h.store.SaveHistory(scope, kept); err != nil {
+		logger.Error("saving history failed", "error", err)
+		return ""
+	}
+	logger.Info("message removed from history", "turns", removed)
+	return reply
+}
+
+// dropExchange removes the turn posted as messageID together with the other
+// half of its exchange, so the history never holds a prompt without its reply
+// or a reply without its prompt. It returns the remaining turns, the reply's
+// message ID when messageID was the prompt, and how many turns were removed.
+func dropExchange(history []db.Turn, messageID string) ([]db.Turn, string, int) {
+	idx := turnIndex(history, messageID)
+	if idx < 0 {
+		return history, "", 0
+	}
+	start, end := idx, idx+1
+	reply := ""
+	switch history[idx].Role {
+	case "user":
+		if end < len(history) && history[end].Role == "assistant" {
+			reply = history[end].MessageID
+			end++
+		}
+	case "assistant":
+		if start > 0 && history[start-1].Role == "user" {
+			start--
+		}
+	}
+	return append(history[:start:start], history[end:]...), reply, end - start
+}
@@ -0,0 +1,84 @@
+package handler
+
+import (
+	"reflect"
+	"testing"
+
+	"discord-ai-bot/db"
+)
+
+// posted returns a turn posted as messageID.
+func posted(role, messageID string) db.Turn {
+	return db.Turn{Role: role, Content: role + " " + messageID, MessageID: messageID}
+}
+
+func TestTurnIndex(t *testing.T) {
+	history := []db.Turn{posted("user", "1"), posted("assistant", "2"), {Role: "user", Content: "legacy"}, posted("user", "1")}
+	tests := []struct {
+		messageID string
+		want      int
+	}{
+		{"2", 1},
+		{"1", 3}, // the latest turn wins
+		{"9", -1},
+		{"", 2},
+	}
+	for _, tt := range tests {
+		if got := turnIndex(history, tt.messageID); got != tt.want {
+			t.Errorf("turnIndex(%q) = %d, want %d", tt.messageID, got, tt.want)
+		}
+	}
+	if got := turnIndex(nil, "1"); got != -1 {
+		t.Errorf("turnIndex on empty history = %d, want -1", got)
+	}
+}
+
+func TestDropExchange(t *testing.T) {
+	history := []db.Turn{
+		posted("user", "p1"), posted("assistant", "r1"),
+		posted("user", "p2"), posted("assistant", "r2"),
+		posted("user", "p3"),
+	}
+	ids := func(turns []db.Turn) []string {
+		var out []string
+		for _, t := range turns {
+			out = append(out, t.MessageID)
+		}
+		return out
+	}
+	tests := []struct {
+		name      string
+		messageID string
+		want      []string
+		reply     string
+		removed   int
+	}{
+		{"prompt takes its reply", "p1", []string{"p2", "r2", "p3"}, "r1", 2},
+		{"reply takes its prompt", "r2", []string{"p1", "r1", "p3"}, "", 2},
+		{"unanswered prompt", "p3", []string{"p1", "r1", "p2", "r2"}, "", 1},
+		{"unknown message", "x", []string{"p1", "r1", "p2", "r2", "p3"}, "", 0},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			kept, reply, removed := dropExchange(history, tt.messageID)
+			if !reflect.DeepEqual(ids(kept), tt.want) || reply != tt.reply || removed != tt.removed {
+				t.Errorf("dropExchange(%q) = %q, %q, %d; want %q, %q, %d", tt.messageID, ids(kept), reply, removed, tt.want, tt.reply, tt.removed)
+			}
+			for idx := 1; idx < len(kept); idx++ {
+				if kept[idx].Role == kept[idx-1].Role {
+					t.Errorf("dropExchange(%q) left two %s turns in a row: %q", tt.messageID, kept[idx].Role, ids(kept))
+				}
+			}
+		})
+	}
+
+	t.Run("reply without a prompt", func(t *testing.T) {
+		kept, _, removed := dropExchange([]db.Turn{posted("assistant", "r0"), posted("user", "p1")}, "r0")
+		if removed != 1 || len(kept) != 1 || kept[0].MessageID != "p1" {
+			t.Errorf("dropExchange = %+v, %d; want only p1 left", kept, removed)
+		}
+	})
+	if got := ids(history); !reflect.DeepEqual(got, []string{"p1", "r1", "p2", "r2", "p3"}) {
+		t.Errorf("dropExchange modified its input: %q", got)
+	}
+}
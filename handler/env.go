@@ -0,0 +1,30 @@
+package handler
+
+import (
+	"os"
+	"strconv"
+)
+
+// envInt reads an integer setting from the environment, falling back to def.
+func envInt(key string, def int) int {
+	if v, err := strconv.Atoi(os.Getenv(key)); err == nil {
+		return v
+	}
+	return def
+}
+
+// envFloat reads a float setting from the environment, falling back to def.
+func envFloat(key string, def float64) float64 {
+	if v, err := strconv.ParseFloat(os.Getenv(key), 64); err == nil {
+		return v
+	}
+	return def
+}
+
+// envBool reads a boolean setting from the environment, falling back to def.
+func envBool(key string, def bool) bool {
+	if v, err := strconv.ParseBool(os.Getenv(key)); err == nil {
+		return v
+	}
+	return def
+}
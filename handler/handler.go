@@ -0,0 +1,25 @@
+package handler
+
+import (
+	"sync"
+
+	"discord-ai-bot/db"
+	"discord-ai-bot/presence"
+)
+
+// Handler holds what the Discord event handlers share. Register its
+// Message*, InteractionCreate, ThreadDelete and RateLimit methods with the
+// session.
+type Handler struct {
+	store    db.Store
+	presence *presence.Manager
+	// drafts holds the statusDraft previewed in each /config menu until it
+	// is applied, discarded or expires.
+	drafts sync.Map
+}
+
+// New returns a Handler that persists everything in store and applies
+// presence changes made in /config through pres.
+func New(store db.Store, pres *presence.Manager) *Handler {
+	return &Handler{store: store, presence: pres}
+}
@@ -1,10 +1,14 @@
 package handler
 
 import (
-	"log"
+	"context"
+	"log/slog"
 	"strings"
+	"time"
 
 	"discord-ai-bot/db"
+	"discord-ai-bot/logging"
+	"discord-ai-bot/presence"
 
 	"github.com/bwmarrin/discordgo"
 )
@@ -12,9 +16,13 @@ import (
 // --- Command and Component IDs ---
 const modalIDGeneral = "modal_general_config"
 const modalIDAssets = "modal_assets_config"
+const modalIDPersonality = "personality_modal"
 // Custom IDs for the buttons
 const buttonIDGeneral = "button_general_config"
 const buttonIDAssets = "button_assets_config"
+// Custom IDs for the select menus
+const selectIDOnlineStatus = "select_online_status"
+const selectIDActivityType = "select_activity_type"
 
 // --- END IDs ---
 
@@ -23,25 +31,64 @@ func ptr(s string) *string {
     return &s
 }
 
+// interactionContext returns a context carrying a logger tagged with a fresh
+// correlation ID and the interaction's guild, channel, user and command.
+func interactionContext(i *discordgo.InteractionCreate) (context.Context, *slog.Logger) {
+	logger := slog.Default().With(
+		"correlation_id", logging.NewID(),
+		"interaction_id", i.ID,
+		"interaction_type", i.Type.String(),
+		"guild_id", i.GuildID,
+		"channel_id", i.ChannelID,
+	)
+	if user := interactionUser(i); user != nil {
+		logger = logger.With("user_id", user.ID)
+	}
+	switch i.Type {
+	case discordgo.InteractionApplicationCommand:
+		logger = logger.With("command", i.ApplicationCommandData().Name)
+	case discordgo.InteractionMessageComponent:
+		logger = logger.With("custom_id", i.MessageComponentData().CustomID)
+	case discordgo.InteractionModalSubmit:
+		logger = logger.With("custom_id", i.ModalSubmitData().CustomID)
+	}
+	return logging.WithLogger(rootContext(), logger), logger
+}
+
 // InteractionCreate handles all slash commands and component/modal submissions
-func InteractionCreate(s *discordgo.Session, i *discordgo.InteractionCreate) {
+func (h *Handler) InteractionCreate(s *discordgo.Session, i *discordgo.InteractionCreate) {
+	ctx, logger := interactionContext(i)
+	if !beginWork() {
+		if i.Type != discordgo.InteractionApplicationCommandAutocomplete {
+			respondEphemeral(ctx, s, i, "The bot is restarting, try again in a moment.")
+		}
+		return
+	}
+	defer endWork()
+
+	start := time.Now()
+	defer func() {
+		logger.Info("interaction handled", "latency_ms", time.Since(start).Milliseconds())
+	}()
+
 	switch i.Type {
 	case discordgo.InteractionApplicationCommand:
-		handleCommand(s, i)
+		h.handleCommand(ctx, s, i)
 	case discordgo.InteractionMessageComponent:
-		handleComponent(s, i)
+		h.handleComponent(ctx, s, i)
 	case discordgo.InteractionModalSubmit:
-		handleModalSubmit(s, i)
+		h.handleModalSubmit(ctx, s, i)
 	}
 }
 
-// Define the two remaining button components (Apply removed)
+// The /config menu: buttons for each settings page, then select menus for
+// the online status and activity type
 var configButtons = []discordgo.MessageComponent{
-	// Row 1: The two action buttons
+	// Row 1: The action buttons
 	discordgo.ActionsRow{
 		Components: []discordgo.MessageComponent{
 			discordgo.Button{
-				Label:    "Edit General Status",
+				Label:    "Edit Activity",
 				Style:    discordgo.PrimaryButton,
 				CustomID: buttonIDGeneral,
 			},
@@ -50,336 +97,357 @@ var configButtons = []discordgo.MessageComponent{
 				Style:    discordgo.SecondaryButton,
 				CustomID: buttonIDAssets,
 			},
+			discordgo.Button{
+				Label:    "Status Rotation",
+				Style:    discordgo.SecondaryButton,
+				CustomID: buttonIDRotation,
+			},
+			discordgo.Button{
+				Label:    "AI Status",
+				Style:    discordgo.SecondaryButton,
+				CustomID: buttonIDAIStatus,
+			},
+			discordgo.Button{
+				Label:    "Presets",
+				Style:    discordgo.SecondaryButton,
+				CustomID: buttonIDPresets,
+			},
+		},
+	},
+	// Row 2: Online status
+	discordgo.ActionsRow{
+		Components: []discordgo.MessageComponent{
+			discordgo.SelectMenu{CustomID: selectIDOnlineStatus, Placeholder: "Set the online status", Options: onlineStatuses},
+		},
+	},
+	// Row 3: Activity type
+	discordgo.ActionsRow{
+		Components: []discordgo.MessageComponent{
+			discordgo.SelectMenu{CustomID: selectIDActivityType, Placeholder: "Set the activity type", Options: activityTypeOptions},
+		},
+	},
+	// Row 4: Custom status, shown alongside the activity
+	discordgo.ActionsRow{
+		Components: []discordgo.MessageComponent{
+			discordgo.Button{
+				Label:    "Edit Custom Status",
+				Style:    discordgo.SecondaryButton,
+				CustomID: buttonIDCustomStatus,
+			},
 		},
 	},
 }
 
 
-// 1. Handle Slash Commands -> Opens Menus/Modals
-func handleCommand(s *discordgo.Session, i *discordgo.InteractionCreate) {
+// 1. Handle Slash Commands -> dispatched through commandRegistry
+func (h *Handler) handleCommand(ctx context.Context, s *discordgo.Session, i *discordgo.InteractionCreate) {
 	name := i.ApplicationCommandData().Name
 
-	switch name {
-	case "config":
-		// 1. IMMEDIATELY DEFER to acknowledge the command within 3 seconds.
-		err := s.InteractionRespond(i.Interaction, &discordgo.InteractionResponse{
-			Type: discordgo.InteractionResponseDeferredChannelMessageWithSource,
-			Data: &discordgo.InteractionResponseData{
-				Flags: discordgo.MessageFlagsEphemeral,
-			},
-		})
+	c, ok := commandHandlers[name]
+	if !ok {
+		// A command removed from the registry but still cached by a client
+		logging.FromContext(ctx).Warn("unknown slash command")
+		respondEphemeral(ctx, s, i, "That command no longer exists.")
+		return
+	}
+	c.handle(h, ctx, s, i)
+}
 
-		if err != nil {
-			log.Printf("CRITICAL: Error deferring /config command: %v", err)
-			return
-		}
+// /config -> Opens the status configuration menu
+func (h *Handler) handleConfigCommand(ctx context.Context, s *discordgo.Session, i *discordgo.InteractionCreate) {
+	// 1. IMMEDIATELY DEFER to acknowledge the command within 3 seconds.
+	err := s.InteractionRespond(i.Interaction, &discordgo.InteractionResponse{
+		Type: discordgo.InteractionResponseDeferredChannelMessageWithSource,
+		Data: &discordgo.InteractionResponseData{
+			Flags: discordgo.MessageFlagsEphemeral,
+		},
+	})
 
-		// 2. Send the actual config menu as a FOLLOWUP message, using the Buttons.
-		_, err = s.FollowupMessageCreate(i.Interaction, true, &discordgo.WebhookParams{
-			Content:    "**RPC Configuration Menu**\n\nSubmit a modal to automatically update your RPC status.",
-			Components: configButtons, // Use the defined buttons
-			Flags:      discordgo.MessageFlagsEphemeral,
-		})
+	if err != nil {
+		logging.FromContext(ctx).Error("deferring /config command failed", "error", err)
+		return
+	}
 
-		if err != nil {
-			log.Printf("ERROR: Failed to send /config followup message (menu): %v", err)
-		}
+	// 2. Send the actual config menu as a FOLLOWUP message, using the Buttons.
+	_, err = s.FollowupMessageCreate(i.Interaction, true, &discordgo.WebhookParams{
+		Content:    configMenuContent,
+		Components: configButtons, // Use the defined buttons
+		Flags:      discordgo.MessageFlagsEphemeral,
+	})
 
-	case "personality":
-		// Opens the Personality Modal
-		err := s.InteractionRespond(i.Interaction, &discordgo.InteractionResponse{
-			Type: discordgo.InteractionResponseModal,
-			Data: &discordgo.InteractionResponseData{
-				CustomID: "personality_modal",
-				Title:    "Edit AI Personality",
-				Components: []discordgo.MessageComponent{
-					discordgo.ActionsRow{
-						Components: []discordgo.MessageComponent{
-							discordgo.TextInput{
-								CustomID:    "persona_input",
-								Label:       "System Prompt",
-								Style:       discordgo.TextInputParagraph,
-								Placeholder: "You are a helpful assistant...",
-								Required:    true,
-								MaxLength:   2000,
-							},
+	if err != nil {
+		logging.FromContext(ctx).Error("sending /config menu followup failed", "error", err)
+	}
+}
+
+// /personality -> Opens the Personality Modal
+func (h *Handler) handlePersonalityCommand(ctx context.Context, s *discordgo.Session, i *discordgo.InteractionCreate) {
+	err := s.InteractionRespond(i.Interaction, &discordgo.InteractionResponse{
+		Type: discordgo.InteractionResponseModal,
+		Data: &discordgo.InteractionResponseData{
+			CustomID: modalIDPersonality,
+			Title:    "Edit AI Personality",
+			Components: []discordgo.MessageComponent{
+				discordgo.ActionsRow{
+					Components: []discordgo.MessageComponent{
+						discordgo.TextInput{
+							CustomID:    "persona_input",
+							Label:       "System Prompt",
+							Style:       discordgo.TextInputParagraph,
+							Placeholder: "You are a helpful assistant...",
+							Required:    true,
+							MaxLength:   2000,
 						},
 					},
 				},
 			},
-		})
+		},
+	})
 
-		if err != nil {
-			log.Printf("Error responding to /personality command: %v", err)
-			return
-		}
+	if err != nil {
+		logging.FromContext(ctx).Error("responding to /personality command failed", "error", err)
 	}
 }
 
-// 2. Handle Component Interactions (Buttons only)
-func handleComponent(s *discordgo.Session, i *discordgo.InteractionCreate) {
-	data := i.MessageComponentData()
-	
-	selectedValue := data.CustomID 
-
-	// Load status data for pre-filling modals
-	currentStatusData := db.LoadStatus()
-	var currentActivity *discordgo.Activity
-	if currentStatusData != nil {
-		for _, act := range currentStatusData.Activities {
-			if act.Type != discordgo.ActivityTypeCustom {
-				currentActivity = act
-				break
-			}
-		}
-	}
-
-	switch selectedValue {
-	case buttonIDGeneral:
-		// Open Modal for Status, Type, Name, Details
-		statusText := ""
-		activityTypeStr := "playing"
-		activityName := ""
-		detailsText := ""
-
-		if currentStatusData != nil {
-			statusText = currentStatusData.Status
-		}
-		if currentActivity != nil {
-			activityTypeStr = activityTypeToString(currentActivity.Type)
-			activityName = currentActivity.Name
-			detailsText = currentActivity.Details
-		}
+// interactionHandler handles one button, select menu or modal submission.
+type interactionHandler func(h *Handler, ctx context.Context, s *discordgo.Session, i *discordgo.InteractionCreate)
+
+// componentHandlers maps the custom IDs of buttons and select menus to their
+// handlers. Adding a component means adding one entry here.
+var componentHandlers = map[string]interactionHandler{
+	// /config menu
+	buttonIDGeneral:      (*Handler).handleGeneralButton,
+	buttonIDAssets:       (*Handler).handleAssetsButton,
+	selectIDOnlineStatus: (*Handler).handleOnlineStatusSelect,
+	selectIDActivityType: (*Handler).handleActivityTypeSelect,
+	buttonIDConfigBack:   (*Handler).handleConfigBack,
+
+	// Custom status and its preview
+	buttonIDCustomStatus:  (*Handler).handleCustomStatus,
+	buttonIDPresenceApply: (*Handler).handlePresenceApply,
+	buttonIDPresenceDrop:  (*Handler).handlePresenceDiscard,
+
+	// Status rotation menu
+	buttonIDRotation:           (*Handler).handleRotationMenu,
+	buttonIDRotationAddCurrent: (*Handler).handleRotationAddCurrent,
+	buttonIDRotationAdd:        (*Handler).handleRotationAdd,
+	selectIDRotationStatus:     (*Handler).handleRotationStatusSelect,
+	selectIDRotationType:       (*Handler).handleRotationTypeSelect,
+	buttonIDRotationEntryNext:  (*Handler).handleRotationEntryNext,
+	buttonIDRotationSchedule:   (*Handler).handleRotationSchedule,
+	buttonIDRotationToggle:     (*Handler).handleRotationToggle,
+	selectIDRotationRemove:     (*Handler).handleRotationRemove,
+
+	// AI status menu
+	buttonIDAIStatus:         (*Handler).handleAIStatusMenu,
+	buttonIDAIStatusToggle:   (*Handler).handleAIStatusToggle,
+	buttonIDAIStatusInterval: (*Handler).handleAIStatusInterval,
+	buttonIDAIStatusGenerate: (*Handler).handleAIStatusGenerate,
+
+	// Presets menu
+	buttonIDPresets:      (*Handler).handlePresetsMenu,
+	buttonIDPresetSave:   (*Handler).handlePresetSave,
+	selectIDPresetLoad:   (*Handler).handlePresetLoad,
+	selectIDPresetDelete: (*Handler).handlePresetDelete,
+
+	// Buttons under AI replies
+	buttonIDRegenerate: (*Handler).handleRegenerate,
+	buttonIDContinue:   (*Handler).handleContinue,
+	buttonIDFeedbackUp: func(h *Handler, ctx context.Context, s *discordgo.Session, i *discordgo.InteractionCreate) {
+		h.handleFeedback(ctx, s, i, db.RatingUp)
+	},
+	buttonIDFeedbackDown: func(h *Handler, ctx context.Context, s *discordgo.Session, i *discordgo.InteractionCreate) {
+		h.handleFeedback(ctx, s, i, db.RatingDown)
+	},
 
-		modal := discordgo.InteractionResponseData{
-			CustomID: modalIDGeneral,
-			Title:    "General Status & Activity",
-			Components: []discordgo.MessageComponent{
-				// Row 1: Status
-				discordgo.ActionsRow{Components: []discordgo.MessageComponent{
-					discordgo.TextInput{CustomID: "status_input", Label: "Discord Status (online, idle, dnd)", Style: discordgo.TextInputShort, Placeholder: "online", Required: true, MaxLength: 10, Value: statusText},
-				}},
-				// Row 2: Type
-				discordgo.ActionsRow{Components: []discordgo.MessageComponent{
-					discordgo.TextInput{CustomID: "type_input", Label: "Activity Type (playing, watching, listening)", Style: discordgo.TextInputShort, Placeholder: "playing", Required: true, MaxLength: 10, Value: activityTypeStr},
-				}},
-				// Row 3: Name
-				discordgo.ActionsRow{Components: []discordgo.MessageComponent{
-					discordgo.TextInput{CustomID: "activity_input", Label: "Activity Name (RPC Top Line)", Style: discordgo.TextInputShort, Placeholder: "Visual Studio Code", Required: true, MaxLength: 100, Value: activityName},
-				}},
-				// Row 4: Details
-				discordgo.ActionsRow{Components: []discordgo.MessageComponent{
-					discordgo.TextInput{CustomID: "details_input", Label: "RPC Details (Level 1-1)", Style: discordgo.TextInputShort, Placeholder: "Optional", Required: false, MaxLength: 100, Value: detailsText},
-				}},
-			},
-		}
-		err := s.InteractionRespond(i.Interaction, &discordgo.InteractionResponse{Type: discordgo.InteractionResponseModal, Data: &modal})
-		if err != nil {
-			log.Printf("Error responding to component modal (General): %v", err)
-		}
+	// Privacy
+	selectIDMemoryDelete: (*Handler).handleMemoryDelete,
+	buttonIDForgetMe:     (*Handler).handleForgetMeConfirm,
+}
 
-	case buttonIDAssets:
-		// Open Modal for Images and Streaming Link
-		largeKey := ""
-		largeText := ""
-		smallKey := ""
-		smallText := ""
-		streamingURL := ""
-
-		// Check for existing assets
-		if currentActivity != nil && currentActivity.Assets.LargeImageID != "" {
-			largeKey = currentActivity.Assets.LargeImageID
-			largeText = currentActivity.Assets.LargeText
-			smallKey = currentActivity.Assets.SmallImageID
-			smallText = currentActivity.Assets.SmallText
-		}
-		if currentActivity != nil {
-			streamingURL = currentActivity.URL
-		}
+// modalHandlers maps modal custom IDs to the handlers of their submissions.
+var modalHandlers = map[string]interactionHandler{
+	modalIDGeneral:          (*Handler).handleGeneralSubmit,
+	modalIDAssets:           (*Handler).handleAssetsSubmit,
+	modalIDPersonality:      (*Handler).handlePersonalitySubmit,
+	modalIDCustomStatus:     (*Handler).handleCustomStatusSubmit,
+	modalIDRotationEntry:    (*Handler).handleRotationEntrySubmit,
+	modalIDRotationSchedule: (*Handler).handleRotationScheduleSubmit,
+	modalIDAIStatusInterval: (*Handler).handleAIStatusIntervalSubmit,
+	modalIDPresetSave:       (*Handler).handlePresetSaveSubmit,
+}
 
-		modal := discordgo.InteractionResponseData{
-			CustomID: modalIDAssets,
-			Title:    "Images & Streaming Link",
-			Components: []discordgo.MessageComponent{
-				// Row 1: Large Image Key
-				discordgo.ActionsRow{Components: []discordgo.MessageComponent{
-					discordgo.TextInput{CustomID: "large_key_input", Label: "Large Image Asset Key", Style: discordgo.TextInputShort, Placeholder: "Asset must be uploaded to Developer Portal.", Required: false, MaxLength: 50, Value: largeKey},
-				}},
-				// Row 2: Large Image Text
-				discordgo.ActionsRow{Components: []discordgo.MessageComponent{
-					discordgo.TextInput{CustomID: "large_text_input", Label: "Large Image Tooltip Text", Style: discordgo.TextInputShort, Placeholder: "What the large image says on hover.", Required: false, MaxLength: 100, Value: largeText},
-				}},
-				// Row 3: Small Image Key
-				discordgo.ActionsRow{Components: []discordgo.MessageComponent{
-					discordgo.TextInput{CustomID: "small_key_input", Label: "Small Image Asset Key", Style: discordgo.TextInputShort, Placeholder: "Optional", Required: false, MaxLength: 50, Value: smallKey},
-				}},
-				// Row 4: Small Image Text
-				discordgo.ActionsRow{Components: []discordgo.MessageComponent{
-					discordgo.TextInput{CustomID: "small_text_input", Label: "Small Image Tooltip Text", Style: discordgo.TextInputShort, Placeholder: "Optional", Required: false, MaxLength: 100, Value: smallText},
-				}},
-				// Row 5: Streaming URL (Used only if type is 'streaming')
-				discordgo.ActionsRow{Components: []discordgo.MessageComponent{
-					discordgo.TextInput{CustomID: "url_input", Label: "Streaming URL (Twitch/YouTube Link)", Style: discordgo.TextInputShort, Placeholder: "Only used if Activity Type is streaming.", Required: false, MaxLength: 100, Value: streamingURL},
-				}},
-			},
-		}
-		err := s.InteractionRespond(i.Interaction, &discordgo.InteractionResponse{Type: discordgo.InteractionResponseModal, Data: &modal})
-		if err != nil {
-			log.Printf("Error responding to component modal (Assets): %v", err)
-		}
+// 2. Handle Component Interactions -> dispatched through componentHandlers
+func (h *Handler) handleComponent(ctx context.Context, s *discordgo.Session, i *discordgo.InteractionCreate) {
+	handle, ok := componentHandlers[i.MessageComponentData().CustomID]
+	if !ok {
+		// A component from a message sent by an older version of the bot
+		logging.FromContext(ctx).Warn("unknown component")
+		respondEphemeral(ctx, s, i, "That button no longer works. Run the command again.")
+		return
 	}
+	handle(h, ctx, s, i)
 }
 
-// 3. Handle Modal Submissions -> Saves Data and Automatically Applies Status
-func handleModalSubmit(s *discordgo.Session, i *discordgo.InteractionCreate) {
-	data := i.ModalSubmitData()
+// 3. Handle Modal Submissions -> dispatched through modalHandlers
+func (h *Handler) handleModalSubmit(ctx context.Context, s *discordgo.Session, i *discordgo.InteractionCreate) {
+	handle, ok := modalHandlers[i.ModalSubmitData().CustomID]
+	if !ok {
+		logging.FromContext(ctx).Warn("unknown modal")
+		respondEphemeral(ctx, s, i, "That form no longer works. Run the command again.")
+		return
+	}
+	handle(h, ctx, s, i)
+}
 
-	// 1. IMMEDIATELY DEFER the response to prevent the "Unknown Interaction" error.
-	err := s.InteractionRespond(i.Interaction, &discordgo.InteractionResponse{
-		Type: discordgo.InteractionResponseDeferredMessageUpdate,
-	})
+// Edit Activity button -> opens a modal for the name and details; status and
+// type have select menus
+func (h *Handler) handleGeneralButton(ctx context.Context, s *discordgo.Session, i *discordgo.InteractionCreate) {
+	status, err := h.store.LoadStatus()
 	if err != nil {
-		log.Printf("CRITICAL: Error deferring modal response: %v", err)
+		logging.FromContext(ctx).Error("loading status failed", "error", err)
+		respondEphemeral(ctx, s, i, "Couldn't load the current status, try again later.")
 		return
 	}
-
-	// Load current status data to preserve everything not in this modal
-	currentStatus := db.LoadStatus()
-	if currentStatus == nil {
-		currentStatus = &discordgo.UpdateStatusData{Status: "online", Activities: []*discordgo.Activity{{Type: discordgo.ActivityTypeGame}}}
+	activityName, detailsText := "", ""
+	if act := mainActivity(status); act != nil {
+		activityName, detailsText = act.Name, act.Details
 	}
 
-	var activity *discordgo.Activity
-	for _, act := range currentStatus.Activities {
-		if act.Type != discordgo.ActivityTypeCustom {
-			activity = act
-			break
-		}
+	modal := discordgo.InteractionResponseData{
+		CustomID: modalIDGeneral,
+		Title:    "Activity Name & Details",
+		Components: []discordgo.MessageComponent{
+			// Row 1: Name
+			discordgo.ActionsRow{Components: []discordgo.MessageComponent{
+				discordgo.TextInput{CustomID: "activity_input", Label: "Activity Name (RPC Top Line)", Style: discordgo.TextInputShort, Placeholder: "Visual Studio Code", Required: true, MaxLength: presence.MaxActivityText, Value: activityName},
+			}},
+			// Row 2: Details
+			discordgo.ActionsRow{Components: []discordgo.MessageComponent{
+				discordgo.TextInput{CustomID: "details_input", Label: "RPC Details (Level 1-1)", Style: discordgo.TextInputShort, Placeholder: "Optional", Required: false, MaxLength: presence.MaxActivityText, Value: detailsText},
+			}},
+		},
 	}
-	if activity == nil {
-		// If no activity exists, create a default one and append it
-		activity = &discordgo.Activity{Type: discordgo.ActivityTypeGame, Name: "Updating..."}
-		currentStatus.Activities = append(currentStatus.Activities, activity)
+	err = s.InteractionRespond(i.Interaction, &discordgo.InteractionResponse{Type: discordgo.InteractionResponseModal, Data: &modal})
+	if err != nil {
+		logging.FromContext(ctx).Error("opening general status modal failed", "error", err)
 	}
+}
 
-	// Base response data for updating the original interaction message
-	responseUpdate := &discordgo.WebhookEdit{
-		// FIX: Use ptr helper for Content
-		Content:    ptr("Configuration Saved, but update result is pending."),
-		// FIX: Use address operator (&) for Components
-		Components: &configButtons,
+// Edit Assets/URL button -> opens a modal for the images and streaming link
+func (h *Handler) handleAssetsButton(ctx context.Context, s *discordgo.Session, i *discordgo.InteractionCreate) {
+	status, err := h.store.LoadStatus()
+	if err != nil {
+		logging.FromContext(ctx).Error("loading status failed", "error", err)
+		respondEphemeral(ctx, s, i, "Couldn't load the current status, try again later.")
+		return
+	}
+	var assets discordgo.Assets
+	streamingURL := ""
+	if act := mainActivity(status); act != nil {
+		assets, streamingURL = act.Assets, act.URL
 	}
-	
-	// A placeholder for the final message content
-	var finalMessageContent string
-
-	switch data.CustomID {
-	case modalIDGeneral:
-		// Save General Status Data (Components 0-3)
-		currentStatus.Status = strings.ToLower(data.Components[0].(*discordgo.ActionsRow).Components[0].(*discordgo.TextInput).Value)
-		activity.Type = stringToActivityType(strings.ToLower(data.Components[1].(*discordgo.ActionsRow).Components[0].(*discordgo.TextInput).Value))
-		activity.Name = data.Components[2].(*discordgo.ActionsRow).Components[0].(*discordgo.TextInput).Value
-		activity.Details = data.Components[3].(*discordgo.ActionsRow).Components[0].(*discordgo.TextInput).Value
-
-		db.SaveStatus(*currentStatus)
-		
-		// --- AUTOMATIC STATUS UPDATE ---
-		if err := s.UpdateStatusComplex(*currentStatus); err != nil {
-			log.Printf("Error updating status (General): %v", err)
-			finalMessageContent = "General settings saved, but **Status Update FAILED!** Check bot logs for details."
-		} else {
-			finalMessageContent = "General settings saved and **Status Updated Successfully!**"
-		}
-		// -------------------------------
-
-	case modalIDAssets:
-		// Save Assets and URL (Components 0-4)
-		largeKey := data.Components[0].(*discordgo.ActionsRow).Components[0].(*discordgo.TextInput).Value
-		largeText := data.Components[1].(*discordgo.ActionsRow).Components[0].(*discordgo.TextInput).Value
-		smallKey := data.Components[2].(*discordgo.ActionsRow).Components[0].(*discordgo.TextInput).Value
-		smallText := data.Components[3].(*discordgo.ActionsRow).Components[0].(*discordgo.TextInput).Value
-		url := data.Components[4].(*discordgo.ActionsRow).Components[0].(*discordgo.TextInput).Value
-
-		// Update Assets (v0.27.1 compatible structure)
-		activity.Assets = discordgo.Assets{
-			LargeImageID: largeKey,
-			LargeText:    largeText,
-			SmallImageID: smallKey,
-			SmallText:    smallText,
-		}
-		activity.URL = url
-
-		db.SaveStatus(*currentStatus)
-		
-		// --- AUTOMATIC STATUS UPDATE ---
-		if err := s.UpdateStatusComplex(*currentStatus); err != nil {
-			log.Printf("Error updating status (Assets): %v", err)
-			finalMessageContent = "Images/URL saved, but **Status Update FAILED!** Check bot logs for details. (Ensure Assets are valid keys)"
-		} else {
-			finalMessageContent = "Images/URL saved and **Status Updated Successfully!**"
-		}
-		// -------------------------------
-		
-	case "personality_modal":
-		// Personality modal submission
-		newPersonality := data.Components[0].(*discordgo.ActionsRow).Components[0].(*discordgo.TextInput).Value
-		db.SavePersonality(newPersonality)
-		
-		// Use FollowupMessageCreate since this is a separate command interaction (/personality)
-		s.FollowupMessageCreate(i.Interaction, true, &discordgo.WebhookParams{
-			Content: "Personality Updated!",
-			Flags:   discordgo.MessageFlagsEphemeral,
-		})
-		return // Exit early as the personality modal is handled differently
 
-	default:
-		// Should not happen, but update the message anyway
-		finalMessageContent = "Unknown submission type."
+	modal := discordgo.InteractionResponseData{
+		CustomID: modalIDAssets,
+		Title:    "Images & Streaming Link",
+		Components: []discordgo.MessageComponent{
+			// Row 1: Large Image Key
+			discordgo.ActionsRow{Components: []discordgo.MessageComponent{
+				discordgo.TextInput{CustomID: "large_key_input", Label: "Large Image Asset Key", Style: discordgo.TextInputShort, Placeholder: "Asset must be uploaded to Developer Portal.", Required: false, MaxLength: 50, Value: assets.LargeImageID},
+			}},
+			// Row 2: Large Image Text
+			discordgo.ActionsRow{Components: []discordgo.MessageComponent{
+				discordgo.TextInput{CustomID: "large_text_input", Label: "Large Image Tooltip Text", Style: discordgo.TextInputShort, Placeholder: "What the large image says on hover.", Required: false, MaxLength: 100, Value: assets.LargeText},
+			}},
+			// Row 3: Small Image Key
+			discordgo.ActionsRow{Components: []discordgo.MessageComponent{
+				discordgo.TextInput{CustomID: "small_key_input", Label: "Small Image Asset Key", Style: discordgo.TextInputShort, Placeholder: "Optional", Required: false, MaxLength: 50, Value: assets.SmallImageID},
+			}},
+			// Row 4: Small Image Text
+			discordgo.ActionsRow{Components: []discordgo.MessageComponent{
+				discordgo.TextInput{CustomID: "small_text_input", Label: "Small Image Tooltip Text", Style: discordgo.TextInputShort, Placeholder: "Optional", Required: false, MaxLength: 100, Value: assets.SmallText},
+			}},
+			// Row 5: Streaming URL (Used only if type is 'streaming')
+			discordgo.ActionsRow{Components: []discordgo.MessageComponent{
+				discordgo.TextInput{CustomID: "url_input", Label: "Streaming URL (Twitch/YouTube Link)", Style: discordgo.TextInputShort, Placeholder: "Required if Activity Type is streaming.", Required: false, MaxLength: 200, Value: streamingURL},
+			}},
+		},
 	}
-	
-	// 2. Edit the original message (from the deferral) with the final status and buttons.
-	// FIX: Use ptr helper for the final message content
-	responseUpdate.Content = ptr(finalMessageContent)
-	_, err = s.InteractionResponseEdit(i.Interaction, responseUpdate)
+	err = s.InteractionRespond(i.Interaction, &discordgo.InteractionResponse{Type: discordgo.InteractionResponseModal, Data: &modal})
 	if err != nil {
-		log.Printf("Error editing deferred interaction response: %v", err)
+		logging.FromContext(ctx).Error("opening assets modal failed", "error", err)
 	}
 }
 
-// --- HELPER FUNCTIONS ---
+// Activity modal -> saves the name and details and applies the status
+func (h *Handler) handleGeneralSubmit(ctx context.Context, s *discordgo.Session, i *discordgo.InteractionCreate) {
+	data := i.ModalSubmitData()
+	name := strings.TrimSpace(data.Components[0].(*discordgo.ActionsRow).Components[0].(*discordgo.TextInput).Value)
+	details := strings.TrimSpace(data.Components[1].(*discordgo.ActionsRow).Components[0].(*discordgo.TextInput).Value)
+	h.updateStatus(ctx, s, i, "General settings", func(status *discordgo.UpdateStatusData) {
+		act := editableActivity(status)
+		act.Name, act.Details = name, details
+	})
+}
 
-// Converts activity type constant to string for pre-filling modals
-func activityTypeToString(t discordgo.ActivityType) string {
-	switch t {
-	case discordgo.ActivityTypeGame:
-		return "playing"
-	case discordgo.ActivityTypeStreaming:
-		return "streaming"
-	case discordgo.ActivityTypeListening:
-		return "listening"
-	case discordgo.ActivityTypeWatching:
-		return "watching"
-	case discordgo.ActivityTypeCompeting:
-		return "competing"
-	default:
-		return "playing"
+// Assets modal -> saves the images and streaming link and applies the status
+func (h *Handler) handleAssetsSubmit(ctx context.Context, s *discordgo.Session, i *discordgo.InteractionCreate) {
+	data := i.ModalSubmitData()
+	value := func(row int) string {
+		return data.Components[row].(*discordgo.ActionsRow).Components[0].(*discordgo.TextInput).Value
+	}
+	assets := discordgo.Assets{
+		LargeImageID: strings.TrimSpace(value(0)),
+		LargeText:    value(1),
+		SmallImageID: strings.TrimSpace(value(2)),
+		SmallText:    value(3),
+	}
+	url := strings.TrimSpace(value(4))
+	h.updateStatus(ctx, s, i, "Images/URL", func(status *discordgo.UpdateStatusData) {
+		act := editableActivity(status)
+		act.Assets, act.URL = assets, url
+	})
+}
+
+// Personality modal -> saves the new system prompt
+func (h *Handler) handlePersonalitySubmit(ctx context.Context, s *discordgo.Session, i *discordgo.InteractionCreate) {
+	newPersonality := i.ModalSubmitData().Components[0].(*discordgo.ActionsRow).Components[0].(*discordgo.TextInput).Value
+	if err := h.store.SavePersonality(newPersonality); err != nil {
+		logging.FromContext(ctx).Error("saving personality failed", "error", err)
+		respondEphemeral(ctx, s, i, "Couldn't save the personality, try again later.")
+		return
 	}
+	respondEphemeral(ctx, s, i, "Personality Updated!")
 }
 
-// Converts string back to activity type constant
-func stringToActivityType(s string) discordgo.ActivityType {
+// editableActivity returns the activity the config menu edits, adding a
+// playing activity if the status has none yet.
+func editableActivity(status *discordgo.UpdateStatusData) *discordgo.Activity {
+	if act := mainActivity(status); act != nil {
+		return act
+	}
+	act := &discordgo.Activity{Type: discordgo.ActivityTypeGame}
+	status.Activities = append(status.Activities, act)
+	return act
+}
+
+// --- HELPER FUNCTIONS ---
+
+// Converts a string to an activity type constant, reporting whether it named one
+func stringToActivityType(s string) (discordgo.ActivityType, bool) {
 	switch s {
 	case "playing":
-		return discordgo.ActivityTypeGame
+		return discordgo.ActivityTypeGame, true
 	case "streaming":
-		return discordgo.ActivityTypeStreaming
+		return discordgo.ActivityTypeStreaming, true
 	case "listening":
-		return discordgo.ActivityTypeListening
+		return discordgo.ActivityTypeListening, true
 	case "watching":
-		return discordgo.ActivityTypeWatching
+		return discordgo.ActivityTypeWatching, true
 	case "competing":
-		return discordgo.ActivityTypeCompeting
+		return discordgo.ActivityTypeCompeting, true
 	default:
-		return discordgo.ActivityTypeGame
+		return discordgo.ActivityTypeGame, false
 	}
 }
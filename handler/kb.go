@@ -0,0 +1,348 @@
+package handler
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"math"
+	"net/http"
+	"path"
+	"sort"
+	"strings"
+	"time"
+	"unicode"
+	"unicode/utf8"
+
+	"discord-ai-bot/db"
+	"discord-ai-bot/logging"
+
+	"github.com/bwmarrin/discordgo"
+)
+
+// Knowledge base limits. Override the retrieval count with KB_TOP_K.
+const (
+	kbMaxDocumentBytes = 512 * 1024
+	kbChunkSize        = 800
+	defaultKBTopK      = 4
+)
+
+// File extensions accepted by /kb add when Discord doesn't report a text content type.
+var kbExtensions = map[string]bool{".txt": true, ".md": true, ".markdown": true, ".csv": true, ".json": true}
+
+// /kb add|list|remove -> manages the guild's knowledge base documents
+func (h *Handler) handleKBCommand(ctx context.Context, s *discordgo.Session, i *discordgo.InteractionCreate) {
+	if i.GuildID == "" {
+		respondEphemeral(ctx, s, i, "The knowledge base is only available in servers.")
+		return
+	}
+
+	sub := i.ApplicationCommandData().Options[0]
+	switch sub.Name {
+	case "add":
+		h.handleKBAdd(ctx, s, i, sub)
+	case "list":
+		h.handleKBList(ctx, s, i)
+	case "remove":
+		name := sub.Options[0].StringValue()
+		removed, err := h.store.DeleteDocument(i.GuildID, name)
+		if err != nil {
+			logging.FromContext(ctx).Error("deleting document failed", "document", name, "error", err)
+			respondEphemeral(ctx, s, i, fmt.Sprintf("Couldn't remove **%s**, try again later.", name))
+		} else if removed {
+			respondEphemeral(ctx, s, i, fmt.Sprintf("Removed **%s** from the knowledge base.", name))
+		} else {
+			respondEphemeral(ctx, s, i, fmt.Sprintf("No document named **%s** found.", name))
+		}
+	}
+}
+
+func (h *Handler) handleKBAdd(ctx context.Context, s *discordgo.Session, i *discordgo.InteractionCreate, sub *discordgo.ApplicationCommandInteractionDataOption) {
+	var attachment *discordgo.MessageAttachment
+	name := ""
+	for _, opt := range sub.Options {
+		switch opt.Name {
+		case "file":
+			if id, ok := opt.Value.(string); ok && i.ApplicationCommandData().Resolved != nil {
+				attachment = i.ApplicationCommandData().Resolved.Attachments[id]
+			}
+		case "name":
+			name = strings.TrimSpace(opt.StringValue())
+		}
+	}
+	if attachment == nil {
+		respondEphemeral(ctx, s, i, "Attach a text file to add.")
+		return
+	}
+	if name == "" {
+		name = attachment.Filename
+	}
+
+	// Downloading can take longer than the 3 second interaction deadline.
+	err := s.InteractionRespond(i.Interaction, &discordgo.InteractionResponse{
+		Type: discordgo.InteractionResponseDeferredChannelMessageWithSource,
+		Data: &discordgo.InteractionResponseData{Flags: discordgo.MessageFlagsEphemeral},
+	})
+	if err != nil {
+		logging.FromContext(ctx).Error("deferring /kb add command failed", "error", err)
+		return
+	}
+
+	content, err := downloadKBAttachment(attachment)
+	if err != nil {
+		followupEphemeral(ctx, s, i, fmt.Sprintf("Couldn't add **%s**: %v", name, err))
+		return
+	}
+
+	chunks := chunkDocument(content, kbChunkSize)
+	if len(chunks) == 0 {
+		followupEphemeral(ctx, s, i, fmt.Sprintf("**%s** is empty.", name))
+		return
+	}
+
+	err = h.store.SaveDocument(i.GuildID, db.KBDocument{
+		Name:    name,
+		AddedBy: interactionUser(i).ID,
+		AddedAt: time.Now().UTC(),
+		Size:    len(content),
+		Chunks:  chunks,
+	})
+	if err != nil {
+		logging.FromContext(ctx).Error("saving document failed", "document", name, "error", err)
+		followupEphemeral(ctx, s, i, fmt.Sprintf("Couldn't save **%s**, try again later.", name))
+		return
+	}
+	followupEphemeral(ctx, s, i, fmt.Sprintf("Added **%s** to the knowledge base (%d chunks).", name, len(chunks)))
+}
+
+func (h *Handler) handleKBList(ctx context.Context, s *discordgo.Session, i *discordgo.InteractionCreate) {
+	docs, err := h.store.LoadDocuments(i.GuildID)
+	if err != nil {
+		logging.FromContext(ctx).Error("loading documents failed", "error", err)
+		respondEphemeral(ctx, s, i, "Couldn't load the knowledge base, try again later.")
+		return
+	}
+	if len(docs) == 0 {
+		respondEphemeral(ctx, s, i, "The knowledge base is empty. Add documents with `/kb add`.")
+		return
+	}
+
+	var sb strings.Builder
+	sb.WriteString("**Knowledge base documents:**\n")
+	for _, doc := range docs {
+		fmt.Fprintf(&sb, "- **%s** (%d bytes, %d chunks, added by <@%s> <t:%d:R>)\n",
+			doc.Name, doc.Size, len(doc.Chunks), doc.AddedBy, doc.AddedAt.Unix())
+	}
+	respondEphemeral(ctx, s, i, truncate(sb.String(), 2000))
+}
+
+// downloadKBAttachment fetches a text attachment, enforcing type and size limits.
+func downloadKBAttachment(att *discordgo.MessageAttachment) (string, error) {
+	if !strings.HasPrefix(att.ContentType, "text/") && att.ContentType != "application/json" &&
+		!kbExtensions[strings.ToLower(path.Ext(att.Filename))] {
+		return "", fmt.Errorf("only text files (.txt, .md, .csv, .json) are supported")
+	}
+	if att.Size > kbMaxDocumentBytes {
+		return "", fmt.Errorf("file is larger than %d KB", kbMaxDocumentBytes/1024)
+	}
+
+	client := &http.Client{Timeout: 30 * time.Second}
+	resp, err := client.Get(att.URL)
+	if err != nil {
+		return "", fmt.Errorf("download failed")
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return "", fmt.Errorf("download failed with status %d", resp.StatusCode)
+	}
+
+	data, err := io.ReadAll(io.LimitReader(resp.Body, kbMaxDocumentBytes+1))
+	if err != nil {
+		return "", fmt.Errorf("download failed")
+	}
+	if len(data) > kbMaxDocumentBytes {
+		return "", fmt.Errorf("file is larger than %d KB", kbMaxDocumentBytes/1024)
+	}
+	return string(data), nil
+}
+
+// chunkDocument splits text on paragraph boundaries into chunks of roughly size
+// characters. Paragraphs longer than size are split on line and word boundaries.
+func chunkDocument(text string, size int) []string {
+	var chunks []string
+	var current strings.Builder
+
+	flush := func() {
+		if chunk := strings.TrimSpace(current.String()); chunk != "" {
+			chunks = append(chunks, chunk)
+		}
+		current.Reset()
+	}
+
+	for _, para := range strings.Split(strings.ReplaceAll(text, "\r\n", "\n"), "\n\n") {
+		para = strings.TrimSpace(para)
+		if para == "" {
+			continue
+		}
+		if current.Len() > 0 && current.Len()+len(para)+2 > size {
+			flush()
+		}
+		for len(para) > size {
+			cut := strings.LastIndexAny(para[:size], "\n ")
+			if cut <= 0 {
+				cut = size
+				for cut > 1 && !utf8.RuneStart(para[cut]) {
+					cut--
+				}
+			}
+			current.WriteString(para[:cut])
+			flush()
+			para = strings.TrimSpace(para[cut:])
+		}
+		if current.Len() > 0 {
+			current.WriteString("\n\n")
+		}
+		current.WriteString(para)
+	}
+	flush()
+	return chunks
+}
+
+// --- RETRIEVAL ---
+
+// kbMatch is a chunk selected for a question, with the document it came from.
+type kbMatch struct {
+	Document string
+	Chunk    string
+	Score    float64
+}
+
+// kbPrompt retrieves the guild's knowledge base chunks most relevant to the
+// question and returns them as system prompt context plus the cited document names.
+func (h *Handler) kbPrompt(ctx context.Context, guildID, question string) (string, []string) {
+	if guildID == "" {
+		return "", nil
+	}
+	docs, err := h.store.LoadDocuments(guildID)
+	if err != nil {
+		logging.FromContext(ctx).Warn("loading knowledge base failed, answering without it", "error", err)
+		return "", nil
+	}
+	if len(docs) == 0 {
+		return "", nil
+	}
+
+	matches := searchBM25(docs, question, envInt("KB_TOP_K", defaultKBTopK))
+	if len(matches) == 0 {
+		return "", nil
+	}
+
+	var sb strings.Builder
+	var sources []string
+	seen := make(map[string]bool)
+	sb.WriteString("\n\nServer knowledge base excerpts. These are authoritative for this server's rules and FAQs; prefer them over your own assumptions:")
+	for _, m := range matches {
+		fmt.Fprintf(&sb, "\n[%s]\n%s", m.Document, m.Chunk)
+		if !seen[m.Document] {
+			seen[m.Document] = true
+			sources = append(sources, m.Document)
+		}
+	}
+	return sb.String(), sources
+}
+
+// citeSources appends the knowledge base documents used for an answer.
+func citeSources(reply string, sources []string) string {
+	if len(sources) == 0 {
+		return reply
+	}
+	return reply + "\n-# Sources: " + strings.Join(sources, ", ")
+}
+
+// BM25 parameters (standard Okapi values).
+const (
+	bm25K1 = 1.2
+	bm25B  = 0.75
+)
+
+// searchBM25 ranks every chunk of docs against the query with Okapi BM25 and
+// returns the top k chunks that share at least one term with it.
+func searchBM25(docs []db.KBDocument, query string, k int) []kbMatch {
+	queryTerms := tokenize(query)
+	if len(queryTerms) == 0 || k <= 0 {
+		return nil
+	}
+
+	type chunkStats struct {
+		doc    string
+		text   string
+		freq   map[string]int
+		length int
+	}
+	var chunks []chunkStats
+	docFreq := make(map[string]int)
+	totalLength := 0
+	for _, doc := range docs {
+		for _, text := range doc.Chunks {
+			terms := tokenize(text)
+			freq := make(map[string]int)
+			for _, t := range terms {
+				freq[t]++
+			}
+			for t := range freq {
+				docFreq[t]++
+			}
+			chunks = append(chunks, chunkStats{doc: doc.Name, text: text, freq: freq, length: len(terms)})
+			totalLength += len(terms)
+		}
+	}
+	if len(chunks) == 0 {
+		return nil
+	}
+
+	n := float64(len(chunks))
+	avgLength := float64(totalLength) / n
+	var matches []kbMatch
+	for _, c := range chunks {
+		score := 0.0
+		for _, t := range queryTerms {
+			tf := float64(c.freq[t])
+			if tf == 0 {
+				continue
+			}
+			df := float64(docFreq[t])
+			idf := math.Log(1 + (n-df+0.5)/(df+0.5))
+			score += idf * tf * (bm25K1 + 1) / (tf + bm25K1*(1-bm25B+bm25B*float64(c.length)/avgLength))
+		}
+		if score > 0 {
+			matches = append(matches, kbMatch{Document: c.doc, Chunk: c.text, Score: score})
+		}
+	}
+
+	sort.SliceStable(matches, func(a, b int) bool { return matches[a].Score > matches[b].Score })
+	if len(matches) > k {
+		matches = matches[:k]
+	}
+	return matches
+}
+
+// Common English words ignored when ranking chunks.
+var stopWords = map[string]bool{
+	"the": true, "and": true, "for": true, "are": true, "but": true, "not": true, "you": true,
+	"all": true, "can": true, "was": true, "what": true, "how": true, "who": true, "why": true,
+	"with": true, "this": true, "that": true, "have": true, "from": true, "your": true, "does": true,
+	"is": true, "it": true, "to": true, "of": true, "in": true, "on": true, "a": true, "an": true,
+	"do": true, "i": true, "we": true, "be": true, "or": true, "if": true, "my": true, "me": true,
+}
+
+// tokenize lowercases text and splits it into terms, dropping stop words.
+func tokenize(text string) []string {
+	var terms []string
+	for _, w := range strings.FieldsFunc(strings.ToLower(text), func(r rune) bool {
+		return !unicode.IsLetter(r) && !unicode.IsDigit(r)
+	}) {
+		if !stopWords[w] {
+			terms = append(terms, w)
+		}
+	}
+	return terms
+}
@@ -0,0 +1,100 @@
+package handler
+
+import (
+	"strings"
+	"testing"
+	"unicode/utf8"
+
+	"discord-ai-bot/db"
+)
+
+func TestChunkDocument(t *testing.T) {
+	long := strings.Repeat("word ", 50) // 250 bytes
+	tests := []struct {
+		name string
+		text string
+		size int
+		want []string
+	}{
+		{"empty", "", 100, nil},
+		{"only blank lines", "\n\n \n\n", 100, nil},
+		{"one paragraph", "Rules apply.", 100, []string{"Rules apply."}},
+		{"paragraphs merged", "one\n\ntwo\n\nthree", 100, []string{"one\n\ntwo\n\nthree"}},
+		{"split at paragraph", "aaaa\n\nbbbb\n\ncccc", 10, []string{"aaaa\n\nbbbb", "cccc"}},
+		{"windows newlines", "one\r\n\r\ntwo", 5, []string{"one", "two"}},
+		{"long paragraph split on words", "alpha beta gamma delta", 11, []string{"alpha beta", "gamma delta"}},
+		{"no spaces", "abcdefghij", 4, []string{"abcd", "efgh", "ij"}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := chunkDocument(tt.text, tt.size)
+			if strings.Join(got, "|") != strings.Join(tt.want, "|") || len(got) != len(tt.want) {
+				t.Errorf("chunkDocument(%q, %d) = %q, want %q", tt.text, tt.size, got, tt.want)
+			}
+		})
+	}
+
+	t.Run("chunks stay within size", func(t *testing.T) {
+		for _, chunk := range chunkDocument(long+"\n\n"+long+"\n\n"+long, 120) {
+			if len(chunk) > 120 {
+				t.Errorf("chunk is %d bytes, want at most 120", len(chunk))
+			}
+		}
+	})
+	t.Run("never splits a rune", func(t *testing.T) {
+		for _, chunk := range chunkDocument(strings.Repeat("é", 30), 7) {
+			if !utf8.ValidString(chunk) {
+				t.Errorf("chunk %q is not valid UTF-8", chunk)
+			}
+		}
+	})
+}
+
+func TestSearchBM25(t *testing.T) {
+	docs := []db.KBDocument{
+		{Name: "rules.md", Chunks: []string{
+			"No spamming in any channel. Spamming gets you muted.",
+			"Be kind to other members.",
+		}},
+		{Name: "faq.md", Chunks: []string{
+			"Roles are assigned by the moderators after a week.",
+			"The server was founded in 2019. Spamming links is not allowed either.",
+		}},
+	}
+	tests := []struct {
+		name  string
+		query string
+		k     int
+		want  []string
+	}{
+		{"best match first", "what happens if I keep spamming?", 4, []string{
+			"No spamming in any channel. Spamming gets you muted.",
+			"The server was founded in 2019. Spamming links is not allowed either.",
+		}},
+		{"limited to k", "spamming", 1, []string{"No spamming in any channel. Spamming gets you muted."}},
+		{"rare term wins", "how are roles assigned?", 4, []string{"Roles are assigned by the moderators after a week."}},
+		{"no shared terms", "pizza toppings", 4, nil},
+		{"only stop words", "what is the", 4, nil},
+		{"zero k", "spamming", 0, nil},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var got []string
+			for _, m := range searchBM25(docs, tt.query, tt.k) {
+				got = append(got, m.Chunk)
+			}
+			if strings.Join(got, "|") != strings.Join(tt.want, "|") {
+				t.Errorf("searchBM25(%q, %d) = %q, want %q", tt.query, tt.k, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestCiteSources(t *testing.T) {
+	if got := citeSources("Hi", nil); got != "Hi" {
+		t.Errorf("citeSources without sources = %q, want %q", got, "Hi")
+	}
+	if got, want := citeSources("Hi", []string{"a.md", "b.md"}), "Hi\n-# Sources: a.md, b.md"; got != want {
+		t.Errorf("citeSources = %q, want %q", got, want)
+	}
+}
@@ -0,0 +1,82 @@
+package handler
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"time"
+)
+
+// cancelGrace is how long Drain waits for handlers to return once it has
+// cancelled their contexts.
+const cancelGrace = 5 * time.Second
+
+// Tracks in-flight triggers so shutdown can stop new ones and wait for the rest.
+// Every handler context derives from root, so shutdown can cancel them all.
+var lifecycle = func() *lifecycleState {
+	root, cancel := context.WithCancel(context.Background())
+	return &lifecycleState{root: root, cancel: cancel}
+}()
+
+type lifecycleState struct {
+	mu       sync.Mutex
+	stopping bool
+	inflight sync.WaitGroup
+	root     context.Context
+	cancel   context.CancelFunc
+}
+
+// rootContext is the parent of every handler context.
+func rootContext() context.Context {
+	return lifecycle.root
+}
+
+// beginWork registers an in-flight trigger. It returns false once shutdown has
+// started, in which case the caller must not do any work or call endWork.
+func beginWork() bool {
+	lifecycle.mu.Lock()
+	defer lifecycle.mu.Unlock()
+	if lifecycle.stopping {
+		return false
+	}
+	lifecycle.inflight.Add(1)
+	return true
+}
+
+// endWork marks an in-flight trigger registered by beginWork as finished.
+func endWork() {
+	lifecycle.inflight.Done()
+}
+
+// StopAccepting makes the handlers ignore new messages and interactions.
+func StopAccepting() {
+	lifecycle.mu.Lock()
+	lifecycle.stopping = true
+	lifecycle.mu.Unlock()
+}
+
+// Drain waits for in-flight LLM calls and replies to finish. If ctx is done
+// first, it cancels the handlers' contexts and waits up to cancelGrace for
+// them to return, so the store isn't closed while they still write to it.
+// Call StopAccepting first so no new work starts while draining.
+func Drain(ctx context.Context) error {
+	done := make(chan struct{})
+	go func() {
+		lifecycle.inflight.Wait()
+		close(done)
+	}()
+
+	select {
+	case <-done:
+		return nil
+	case <-ctx.Done():
+	}
+
+	lifecycle.cancel()
+	select {
+	case <-done:
+		return ctx.Err()
+	case <-time.After(cancelGrace):
+		return errors.New("handlers still running after being cancelled")
+	}
+}
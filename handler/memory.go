@@ -0,0 +1,235 @@
+package handler
+
+import (
+	"context"
+	"fmt"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+
+	"discord-ai-bot/db"
+	"discord-ai-bot/logging"
+
+	"github.com/bwmarrin/discordgo"
+)
+
+// Custom ID for the select menu shown by /memories
+const selectIDMemoryDelete = "select_memory_delete"
+
+// maxPromptFacts is how many facts are injected into the system prompt per message.
+const maxPromptFacts = 8
+
+// Matches "remember that ...", "remember I ...", "remember: ..." at the start of a ping.
+var rememberPattern = regexp.MustCompile(`(?is)^remember(?:\s+that)?[\s,:]+(.+)$`)
+
+// parseRememberRequest extracts the fact from a "remember ..." message.
+// Questions ("remember that time we ...?") are left for the AI to answer.
+func parseRememberRequest(message string) (string, bool) {
+	message = strings.TrimSpace(message)
+	match := rememberPattern.FindStringSubmatch(message)
+	if match == nil || strings.HasSuffix(message, "?") {
+		return "", false
+	}
+	fact := strings.TrimSpace(match[1])
+	return fact, fact != ""
+}
+
+// interactionUser returns the invoking user for both guild and DM interactions.
+func interactionUser(i *discordgo.InteractionCreate) *discordgo.User {
+	if i.Member != nil && i.Member.User != nil {
+		return i.Member.User
+	}
+	return i.User
+}
+
+// factsPrompt builds the system prompt section listing the facts relevant to this message.
+func (h *Handler) factsPrompt(ctx context.Context, user *discordgo.User, message string) string {
+	facts, err := h.store.LoadUserFacts(user.ID)
+	if err != nil {
+		logging.FromContext(ctx).Warn("loading facts failed, answering without them", "error", err)
+	}
+	facts = relevantFacts(facts, message, maxPromptFacts)
+	if len(facts) == 0 {
+		return ""
+	}
+
+	var sb strings.Builder
+	fmt.Fprintf(&sb, "\n\nThings %s asked you to remember about them:", user.Username)
+	for _, f := range facts {
+		sb.WriteString("\n- ")
+		sb.WriteString(f.Content)
+	}
+	return sb.String()
+}
+
+// relevantFacts picks up to limit facts for a message. Users with only a few
+// facts get all of them; otherwise facts sharing words with the message win,
+// with the most recent facts filling any remaining slots.
+func relevantFacts(facts []db.UserFact, message string, limit int) []db.UserFact {
+	if len(facts) <= limit {
+		return facts
+	}
+
+	words := wordSet(message)
+	type scored struct {
+		fact  db.UserFact
+		score int
+		index int
+	}
+	ranked := make([]scored, len(facts))
+	for idx, f := range facts {
+		score := 0
+		for w := range wordSet(f.Content) {
+			if words[w] {
+				score++
+			}
+		}
+		ranked[idx] = scored{fact: f, score: score, index: idx}
+	}
+	sort.SliceStable(ranked, func(a, b int) bool {
+		if ranked[a].score != ranked[b].score {
+			return ranked[a].score > ranked[b].score
+		}
+		return ranked[a].index > ranked[b].index
+	})
+
+	picked := make([]db.UserFact, 0, limit)
+	for _, r := range ranked[:limit] {
+		picked = append(picked, r.fact)
+	}
+	return picked
+}
+
+// wordSet lowercases text and returns its words, ignoring very short ones.
+func wordSet(text string) map[string]bool {
+	set := make(map[string]bool)
+	for _, w := range strings.FieldsFunc(strings.ToLower(text), func(r rune) bool {
+		return !(r >= 'a' && r <= 'z' || r >= '0' && r <= '9' || r > 127)
+	}) {
+		if len(w) > 2 {
+			set[w] = true
+		}
+	}
+	return set
+}
+
+// /remember -> stores a fact for the invoking user
+func (h *Handler) handleRememberCommand(ctx context.Context, s *discordgo.Session, i *discordgo.InteractionCreate) {
+	user := interactionUser(i)
+	fact := strings.TrimSpace(i.ApplicationCommandData().Options[0].StringValue())
+
+	if fact == "" {
+		respondEphemeral(ctx, s, i, "There's nothing to remember.")
+		return
+	}
+	// Facts end up in the system prompt, so they get the same check as prompts
+	req := chatRequest{GuildID: i.GuildID, ChannelID: i.ChannelID, Author: user}
+	if !h.moderateInput(ctx, s, req, fact) {
+		respondEphemeral(ctx, s, i, "I can't remember that.")
+		return
+	}
+	if err := h.store.AddUserFact(user.ID, fact); err != nil {
+		logging.FromContext(ctx).Error("saving fact failed", "error", err)
+		respondEphemeral(ctx, s, i, "Couldn't save that, try again later.")
+		return
+	}
+	respondEphemeral(ctx, s, i, "Got it, I'll remember that.")
+}
+
+// /memories -> lists the invoking user's facts with a select menu to delete them
+func (h *Handler) handleMemoriesCommand(ctx context.Context, s *discordgo.Session, i *discordgo.InteractionCreate) {
+	user := interactionUser(i)
+	err := s.InteractionRespond(i.Interaction, &discordgo.InteractionResponse{
+		Type: discordgo.InteractionResponseChannelMessageWithSource,
+		Data: h.memoriesResponse(ctx, user.ID, ""),
+	})
+	if err != nil {
+		logging.FromContext(ctx).Error("responding to /memories command failed", "error", err)
+	}
+}
+
+// Handles the /memories select menu: deletes the chosen facts and refreshes the list.
+func (h *Handler) handleMemoryDelete(ctx context.Context, s *discordgo.Session, i *discordgo.InteractionCreate) {
+	user := interactionUser(i)
+	removed := 0
+	for _, value := range i.MessageComponentData().Values {
+		id, err := strconv.ParseUint(value, 10, 64)
+		if err != nil {
+			continue
+		}
+		ok, err := h.store.DeleteUserFact(user.ID, id)
+		if err != nil {
+			logging.FromContext(ctx).Error("deleting fact failed", "fact_id", id, "error", err)
+			continue
+		}
+		if ok {
+			removed++
+		}
+	}
+
+	err := s.InteractionRespond(i.Interaction, &discordgo.InteractionResponse{
+		Type: discordgo.InteractionResponseUpdateMessage,
+		Data: h.memoriesResponse(ctx, user.ID, fmt.Sprintf("Forgot %d fact(s).\n\n", removed)),
+	})
+	if err != nil {
+		logging.FromContext(ctx).Error("updating /memories message failed", "error", err)
+	}
+}
+
+// memoriesResponse renders a user's facts as an ephemeral message with a delete menu.
+func (h *Handler) memoriesResponse(ctx context.Context, userID, prefix string) *discordgo.InteractionResponseData {
+	data := &discordgo.InteractionResponseData{
+		Flags:      discordgo.MessageFlagsEphemeral,
+		Components: []discordgo.MessageComponent{},
+	}
+	facts, err := h.store.LoadUserFacts(userID)
+	if err != nil {
+		logging.FromContext(ctx).Error("loading facts failed", "error", err)
+		data.Content = prefix + "Couldn't load your memories, try again later."
+		return data
+	}
+	if len(facts) == 0 {
+		data.Content = prefix + "I don't remember anything about you yet. Use `/remember` or ping me with \"remember that ...\"."
+		return data
+	}
+
+	var sb strings.Builder
+	sb.WriteString(prefix)
+	sb.WriteString("**Things I remember about you:**\n")
+	options := make([]discordgo.SelectMenuOption, 0, len(facts))
+	for n, f := range facts {
+		fmt.Fprintf(&sb, "%d. %s\n", n+1, f.Content)
+		// Select menus are limited to 25 options; show the newest facts.
+		if len(facts)-n <= 25 {
+			options = append(options, discordgo.SelectMenuOption{
+				Label: truncate(fmt.Sprintf("%d. %s", n+1, f.Content), 100),
+				Value: strconv.FormatUint(f.ID, 10),
+			})
+		}
+	}
+	data.Content = truncate(sb.String(), 2000)
+
+	minValues := 1
+	data.Components = []discordgo.MessageComponent{
+		discordgo.ActionsRow{Components: []discordgo.MessageComponent{
+			discordgo.SelectMenu{
+				CustomID:    selectIDMemoryDelete,
+				Placeholder: "Select facts to forget",
+				MinValues:   &minValues,
+				MaxValues:   len(options),
+				Options:     options,
+			},
+		}},
+	}
+	return data
+}
+
+// truncate shortens s to at most max runes, marking the cut with an ellipsis.
+func truncate(s string, max int) string {
+	runes := []rune(s)
+	if len(runes) <= max {
+		return s
+	}
+	return string(runes[:max-1]) + "…"
+}
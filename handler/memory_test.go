@@ -0,0 +1,74 @@
+package handler
+
+import (
+	"reflect"
+	"testing"
+
+	"discord-ai-bot/db"
+)
+
+func TestParseRememberRequest(t *testing.T) {
+	tests := []struct {
+		message string
+		want    string
+		ok      bool
+	}{
+		{"remember I use Arch", "I use Arch", true},
+		{"Remember that my birthday is in May", "my birthday is in May", true},
+		{"remember: tabs over spaces", "tabs over spaces", true},
+		{"remember, I'm vegetarian", "I'm vegetarian", true},
+		{"  remember I use Arch  ", "I use Arch", true},
+		{"remember that time we broke prod?", "", false},
+		{"remember when we met?", "", false},
+		{"remembered nothing", "", false},
+		{"do you remember me", "", false},
+		{"remember", "", false},
+		{"remember:   ", "", false},
+		{"what's the weather", "", false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.message, func(t *testing.T) {
+			got, ok := parseRememberRequest(tt.message)
+			if got != tt.want || ok != tt.ok {
+				t.Errorf("parseRememberRequest(%q) = %q, %v; want %q, %v", tt.message, got, ok, tt.want, tt.ok)
+			}
+		})
+	}
+}
+
+func TestRelevantFacts(t *testing.T) {
+	facts := func(contents ...string) []db.UserFact {
+		var out []db.UserFact
+		for n, c := range contents {
+			out = append(out, db.UserFact{ID: uint64(n + 1), Content: c})
+		}
+		return out
+	}
+	many := facts("I use Arch Linux", "my cat is called Miso", "I work nights", "I prefer Go over Rust", "my editor is Neovim")
+
+	tests := []struct {
+		name    string
+		facts   []db.UserFact
+		message string
+		limit   int
+		want    []string
+	}{
+		{"few facts are all kept", facts("a", "b"), "unrelated", 3, []string{"a", "b"}},
+		{"no facts", nil, "anything", 3, nil},
+		{"matching facts first", many, "how do I update arch linux?", 2, []string{"I use Arch Linux", "my editor is Neovim"}},
+		{"most recent fill the rest", many, "hello there", 2, []string{"my editor is Neovim", "I prefer Go over Rust"}},
+		{"more shared words rank higher", many, "my cat Miso likes Linux", 2, []string{"my cat is called Miso", "I use Arch Linux"}},
+		{"short words are ignored", many, "is my", 1, []string{"my editor is Neovim"}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var got []string
+			for _, f := range relevantFacts(tt.facts, tt.message, tt.limit) {
+				got = append(got, f.Content)
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("relevantFacts(%q, %d) = %q, want %q", tt.message, tt.limit, got, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,55 @@
+package handler
+
+import (
+	"regexp"
+	"strings"
+
+	"github.com/bwmarrin/discordgo"
+)
+
+// allowedMentions builds the mention policy for bot replies from the environment.
+// By default only the reply itself pings the author; MENTIONS_ALLOW_USERS,
+// MENTIONS_ALLOW_ROLES and MENTIONS_ALLOW_EVERYONE opt in to more, and
+// MENTIONS_REPLY_AUTHOR=false silences the reply ping too.
+func allowedMentions() *discordgo.MessageAllowedMentions {
+	allowed := &discordgo.MessageAllowedMentions{
+		Parse:       []discordgo.AllowedMentionType{},
+		RepliedUser: envBool("MENTIONS_REPLY_AUTHOR", true),
+	}
+	if envBool("MENTIONS_ALLOW_USERS", false) {
+		allowed.Parse = append(allowed.Parse, discordgo.AllowedMentionTypeUsers)
+	}
+	if envBool("MENTIONS_ALLOW_ROLES", false) {
+		allowed.Parse = append(allowed.Parse, discordgo.AllowedMentionTypeRoles)
+	}
+	if envBool("MENTIONS_ALLOW_EVERYONE", false) {
+		allowed.Parse = append(allowed.Parse, discordgo.AllowedMentionTypeEveryone)
+	}
+	return allowed
+}
+
+var (
+	massMentionPattern = regexp.MustCompile(`@(everyone|here)`)
+	roleMentionPattern = regexp.MustCompile(`<@&(\d+)>`)
+)
+
+// sanitizeMentions defuses @everyone, @here and role mentions in text when
+// MENTIONS_SANITIZE is enabled, so they render as plain text even if the
+// allowed mentions policy is later loosened. Roles become "@Role Name".
+func sanitizeMentions(s *discordgo.Session, guildID, text string) string {
+	if !envBool("MENTIONS_SANITIZE", false) {
+		return text
+	}
+
+	// A zero-width space after the @ stops Discord from parsing the mention.
+	text = massMentionPattern.ReplaceAllString(text, "@\u200b$1")
+	return roleMentionPattern.ReplaceAllStringFunc(text, func(mention string) string {
+		roleID := roleMentionPattern.FindStringSubmatch(mention)[1]
+		if guildID != "" {
+			if role, err := s.State.Role(guildID, roleID); err == nil {
+				return "@\u200b" + strings.TrimPrefix(role.Name, "@")
+			}
+		}
+		return "@\u200brole"
+	})
+}
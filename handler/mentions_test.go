@@ -0,0 +1,75 @@
+package handler
+
+import (
+	"reflect"
+	"testing"
+
+	"github.com/bwmarrin/discordgo"
+)
+
+func TestSanitizeMentions(t *testing.T) {
+	state := discordgo.NewState()
+	if err := state.GuildAdd(&discordgo.Guild{ID: "g", Roles: []*discordgo.Role{{ID: "42", Name: "Moderators"}, {ID: "43", Name: "@admins"}}}); err != nil {
+		t.Fatalf("GuildAdd: %v", err)
+	}
+	s := &discordgo.Session{State: state}
+
+	tests := []struct {
+		name    string
+		guildID string
+		text    string
+		want    string
+	}{
+		{"plain text", "g", "hello there", "hello there"},
+		{"everyone", "g", "hey @everyone!", "hey @\u200beveryone!"},
+		{"here", "g", "@here look", "@\u200bhere look"},
+		{"known role", "g", "ping <@&42> now", "ping @\u200bModerators now"},
+		{"role name with an @", "g", "<@&43>", "@\u200badmins"},
+		{"unknown role", "g", "<@&99>", "@\u200brole"},
+		{"role in a DM", "", "<@&42>", "@\u200brole"},
+		{"user mention is kept", "g", "thanks <@123>", "thanks <@123>"},
+		{"several", "g", "@everyone @here <@&42>", "@\u200beveryone @\u200bhere @\u200bModerators"},
+	}
+	t.Setenv("MENTIONS_SANITIZE", "true")
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := sanitizeMentions(s, tt.guildID, tt.text); got != tt.want {
+				t.Errorf("sanitizeMentions(%q) = %q, want %q", tt.text, got, tt.want)
+			}
+		})
+	}
+
+	t.Setenv("MENTIONS_SANITIZE", "")
+	if got := sanitizeMentions(s, "g", "@everyone <@&42>"); got != "@everyone <@&42>" {
+		t.Errorf("sanitizeMentions without MENTIONS_SANITIZE = %q, want the text unchanged", got)
+	}
+}
+
+func TestAllowedMentions(t *testing.T) {
+	tests := []struct {
+		name      string
+		env       map[string]string
+		wantParse []discordgo.AllowedMentionType
+		wantReply bool
+	}{
+		{"default", nil, []discordgo.AllowedMentionType{}, true},
+		{"no reply ping", map[string]string{"MENTIONS_REPLY_AUTHOR": "false"}, []discordgo.AllowedMentionType{}, false},
+		{"users", map[string]string{"MENTIONS_ALLOW_USERS": "true"},
+			[]discordgo.AllowedMentionType{discordgo.AllowedMentionTypeUsers}, true},
+		{"everything", map[string]string{"MENTIONS_ALLOW_USERS": "1", "MENTIONS_ALLOW_ROLES": "1", "MENTIONS_ALLOW_EVERYONE": "1"},
+			[]discordgo.AllowedMentionType{discordgo.AllowedMentionTypeUsers, discordgo.AllowedMentionTypeRoles, discordgo.AllowedMentionTypeEveryone}, true},
+		{"invalid values use the defaults", map[string]string{"MENTIONS_ALLOW_EVERYONE": "sure", "MENTIONS_REPLY_AUTHOR": "nope"},
+			[]discordgo.AllowedMentionType{}, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			for _, key := range []string{"MENTIONS_REPLY_AUTHOR", "MENTIONS_ALLOW_USERS", "MENTIONS_ALLOW_ROLES", "MENTIONS_ALLOW_EVERYONE"} {
+				t.Setenv(key, tt.env[key])
+			}
+			got := allowedMentions()
+			if !reflect.DeepEqual(got.Parse, tt.wantParse) || got.RepliedUser != tt.wantReply {
+				t.Errorf("allowedMentions() = %+v, want parse %v and replied user %v", got, tt.wantParse, tt.wantReply)
+			}
+		})
+	}
+}
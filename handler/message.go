@@ -1,12 +1,14 @@
 package handler
 
 import (
-    "log"
+    "context"
+    "log/slog"
     "strings"
     "time"
 
-    "discord-ai-bot/ai"
     "discord-ai-bot/db"
+    "discord-ai-bot/logging"
+    "discord-ai-bot/metrics"
 
     "github.com/bwmarrin/discordgo"
 )
@@ -20,57 +22,103 @@ func createReply(m *discordgo.MessageCreate) *discordgo.MessageReference {
     }
 }
 
-func MessageCreate(s *discordgo.Session) func(*discordgo.Session, *discordgo.MessageCreate) {
-    return func(s *discordgo.Session, m *discordgo.MessageCreate) {
-        if m.Author.ID == s.State.User.ID { return }
+// messageContext returns a context carrying a logger tagged with a fresh
+// correlation ID and the message's guild, channel and author. Deleted
+// messages have no author.
+func messageContext(m *discordgo.Message) (context.Context, *slog.Logger) {
+    logger := slog.Default().With(
+        "correlation_id", logging.NewID(),
+        "guild_id", m.GuildID,
+        "channel_id", m.ChannelID,
+        "message_id", m.ID,
+    )
+    if m.Author != nil {
+        logger = logger.With("user_id", m.Author.ID)
+    }
+    return logging.WithLogger(rootContext(), logger), logger
+}
 
-        // --- PING/AI HANDLING ONLY ---
-        isPinged := false
-        mentionID := s.State.User.ID
-        for _, mention := range m.Mentions {
-            if mention.ID == mentionID {
-                isPinged = true
-                break
-            }
-        }
+// promptText strips the bot's mention from a message.
+func promptText(s *discordgo.Session, content string) string {
+    return strings.TrimSpace(strings.Replace(content, "<@"+s.State.User.ID+">", "", 1))
+}
 
-        if isPinged {
-            cleanMessage := strings.TrimSpace(strings.Replace(m.Content, "<@"+mentionID+">", "", 1))
-            
-            if cleanMessage == "" {
-                s.ChannelMessageSendReply(m.ChannelID, "Hello! Ping me with a question.", createReply(m))
-                return
-            }
+// MessageCreate answers messages that ping the bot.
+func (h *Handler) MessageCreate(s *discordgo.Session, m *discordgo.MessageCreate) {
+    if m.Author.ID == s.State.User.ID { return }
 
-            s.ChannelTyping(m.ChannelID)
-            startTime := time.Now()
+    // --- PING/AI HANDLING ONLY ---
+    isPinged := false
+    mentionID := s.State.User.ID
+    for _, mention := range m.Mentions {
+        if mention.ID == mentionID {
+            isPinged = true
+            break
+        }
+    }
 
-            personality := db.LoadPersonality()
-            history := db.LoadGlobalHistory() 
+    // Conversation threads answer every message, no ping needed. Other bots
+    // are ignored there so two bots can't keep a thread going forever.
+    inThread := conversationThread(s, m.ChannelID)
+    if inThread && m.Author.Bot && !isPinged { return }
 
-            userMessage := ai.Message{Role: "user", Content: cleanMessage} 
-            
-            fullHistory := []ai.Message{{Role: "system", Content: personality}}
-            fullHistory = append(fullHistory, history...) 
-            fullHistory = append(fullHistory, userMessage)
+    if isPinged || inThread {
+        if !beginWork() { return }
+        defer endWork()
 
-            aiResponseContent, err := ai.GetCerebrasResponse(fullHistory) 
-            
-            if elapsed := time.Since(startTime); elapsed < time.Second {
-                time.Sleep(time.Second - elapsed)
-            }
+        ctx, logger := messageContext(m.Message)
+        handledAt := time.Now()
+        outcome := "replied"
+        defer func() {
+            metrics.Messages.Inc(outcome)
+            logger.Info("message handled", "outcome", outcome, "latency_ms", time.Since(handledAt).Milliseconds())
+        }()
 
-            if err != nil {
-                log.Printf("Cerebras API Error: %v", err)
-                s.ChannelMessageSendReply(m.ChannelID, "AI Error. Check logs.", createReply(m))
-                return
-            }
+        cleanMessage := promptText(s, m.Content)
+        if cleanMessage == "" && !isPinged {
+            outcome = "empty"
+            return
+        }
 
-            s.ChannelMessageSendReply(m.ChannelID, aiResponseContent, createReply(m))
+        req := chatRequest{
+            GuildID:   m.GuildID,
+            ChannelID: m.ChannelID,
+            Author:    m.Author,
+            Prompt:    cleanMessage,
+            Scope:     db.GlobalScope,
+            Typing:    true,
+        }
+        newThread := false
+        if inThread {
+            req.Scope = db.ThreadScope(m.ChannelID)
+        } else if cleanMessage != "" && h.threadModeEnabled(ctx, m.GuildID, m.ChannelID) {
+            // Move the conversation into a thread started from the ping
+            if thread, err := startThread(s, m.ChannelID, m.ID, cleanMessage); err != nil {
+                logger.Warn("starting conversation thread failed, answering in the channel", "error", err)
+            } else {
+                logger.Info("conversation thread started", "thread_id", thread.ID)
+                req.ChannelID = thread.ID
+                req.Scope = db.ThreadScope(thread.ID)
+                newThread = true
+            }
+        }
 
-            assistantMessage := ai.Message{Role: "assistant", Content: aiResponseContent}
-            finalHistory := append(history, userMessage, assistantMessage) 
-            db.SaveGlobalHistory(finalHistory)
+        res := h.chat(ctx, s, req)
+        outcome = res.Outcome
+        var msg *discordgo.Message
+        var err error
+        if newThread {
+            msg, err = sendMessage(ctx, s, req.ChannelID, m.GuildID, res.Content, res.components())
+        } else {
+            msg, err = sendReply(ctx, s, m, res.Content, res.components())
+        }
+        if err == nil {
+            h.saveExchange(ctx, req.Scope, res.Exchange, m.ID, msg.ID)
         }
     }
 }
+
+// RateLimit counts Discord REST rate limit hits for the metrics endpoint.
+func (h *Handler) RateLimit(s *discordgo.Session, r *discordgo.RateLimit) {
+    metrics.RateLimitHits.Inc("discord")
+}
@@ -0,0 +1,147 @@
+package handler
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"sync"
+
+	"discord-ai-bot/logging"
+	"discord-ai-bot/moderation"
+
+	"github.com/bwmarrin/discordgo"
+)
+
+var (
+	modFilter     *moderation.Filter
+	modFilterOnce sync.Once
+)
+
+// getModFilter loads the blocklist lazily so it sees variables loaded from .env.
+func getModFilter() *moderation.Filter {
+	modFilterOnce.Do(func() {
+		modFilter = moderation.LoadFilterFromEnv()
+	})
+	return modFilter
+}
+
+// guildModerationLevel returns the guild's configured level, or the default
+// level for DMs and guilds that never changed it.
+func (h *Handler) guildModerationLevel(ctx context.Context, guildID string) moderation.Level {
+	if guildID != "" {
+		settings, err := h.store.LoadGuildSettings(guildID)
+		if err != nil {
+			logging.FromContext(ctx).Warn("loading guild settings failed, using default moderation level", "error", err)
+		}
+		if level, ok := moderation.ParseLevel(settings.ModerationLevel); ok {
+			return level
+		}
+	}
+	return moderation.DefaultLevel()
+}
+
+// moderateInput checks a prompt before it reaches the AI. It reports whether
+// the prompt may be used; refused prompts are logged to the mod channel.
+func (h *Handler) moderateInput(ctx context.Context, s *discordgo.Session, req chatRequest, text string) bool {
+	v := getModFilter().CheckInput(ctx, text, h.guildModerationLevel(ctx, req.GuildID))
+	if v.Action != moderation.ActionRefuse {
+		return true
+	}
+	h.logModeration(ctx, s, req, "refused prompt", text, v.Reasons)
+	return false
+}
+
+// moderateOutput checks an AI reply before it is posted. It returns the text to
+// send (possibly redacted) and whether the reply may be sent at all.
+func (h *Handler) moderateOutput(ctx context.Context, s *discordgo.Session, req chatRequest, text string) (string, bool) {
+	v := getModFilter().CheckOutput(ctx, text, h.guildModerationLevel(ctx, req.GuildID))
+	switch v.Action {
+	case moderation.ActionRefuse:
+		h.logModeration(ctx, s, req, "blocked AI reply", text, v.Reasons)
+		return "", false
+	case moderation.ActionRedact:
+		h.logModeration(ctx, s, req, "redacted AI reply", text, v.Reasons)
+	}
+	return v.Text, true
+}
+
+// logModeration reports a moderation action to the log and the guild's mod channel, if set.
+func (h *Handler) logModeration(ctx context.Context, s *discordgo.Session, req chatRequest, action, text string, reasons []string) {
+	logging.FromContext(ctx).Warn("moderation action", "action", action, "reasons", reasons, "content", text)
+
+	if req.GuildID == "" {
+		return
+	}
+	settings, err := h.store.LoadGuildSettings(req.GuildID)
+	if err != nil {
+		logging.FromContext(ctx).Error("loading mod log channel failed", "error", err)
+		return
+	}
+	channelID := settings.ModLogChannelID
+	if channelID == "" {
+		return
+	}
+
+	content := fmt.Sprintf("🚩 **Moderation:** %s for <@%s> in <#%s>\n**Reasons:** %s\n>>> %s",
+		action, req.Author.ID, req.ChannelID, strings.Join(reasons, "; "), truncate(text, 1500))
+	_, err = s.ChannelMessageSendComplex(channelID, &discordgo.MessageSend{
+		Content:         content,
+		AllowedMentions: &discordgo.MessageAllowedMentions{},
+	})
+	if err != nil {
+		logging.FromContext(ctx).Error("sending moderation log failed", "log_channel_id", channelID, "error", err)
+	}
+}
+
+// /moderation -> shows or changes the guild's moderation level and log channel
+func (h *Handler) handleModerationCommand(ctx context.Context, s *discordgo.Session, i *discordgo.InteractionCreate) {
+	if i.GuildID == "" {
+		respondEphemeral(ctx, s, i, "Moderation settings are only available in servers.")
+		return
+	}
+
+	settings, err := h.store.LoadGuildSettings(i.GuildID)
+	if err != nil {
+		logging.FromContext(ctx).Error("loading guild settings failed", "error", err)
+		respondEphemeral(ctx, s, i, "Couldn't load the moderation settings, try again later.")
+		return
+	}
+	changed := false
+	for _, opt := range i.ApplicationCommandData().Options {
+		switch opt.Name {
+		case "level":
+			settings.ModerationLevel = opt.StringValue()
+			changed = true
+		case "log_channel":
+			if id, ok := opt.Value.(string); ok {
+				settings.ModLogChannelID = id
+				changed = true
+			}
+		case "disable_log":
+			if opt.BoolValue() {
+				settings.ModLogChannelID = ""
+				changed = true
+			}
+		}
+	}
+	if changed {
+		if err := h.store.SaveGuildSettings(i.GuildID, settings); err != nil {
+			logging.FromContext(ctx).Error("saving guild settings failed", "error", err)
+			respondEphemeral(ctx, s, i, "Couldn't save the moderation settings, try again later.")
+			return
+		}
+	}
+
+	logChannel := "none"
+	if settings.ModLogChannelID != "" {
+		logChannel = "<#" + settings.ModLogChannelID + ">"
+	}
+	prefix := "**Moderation settings**"
+	if changed {
+		prefix = "**Moderation settings updated**"
+	}
+	respondEphemeral(ctx, s, i, fmt.Sprintf("%s\nLevel: `%s`\nLog channel: %s\n\n"+
+		"`off` disables moderation, `low` redacts blocklisted words from replies, "+
+		"`medium` also refuses blocklisted prompts, `high` also runs the moderation model.",
+		prefix, h.guildModerationLevel(ctx, i.GuildID), logChannel))
+}
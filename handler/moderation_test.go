@@ -0,0 +1,47 @@
+package handler
+
+import (
+	"context"
+	"testing"
+
+	"discord-ai-bot/db"
+	"discord-ai-bot/moderation"
+
+	"github.com/bwmarrin/discordgo"
+)
+
+// useModFilter replaces the blocklist loaded from the environment.
+func useModFilter(t *testing.T, words ...string) {
+	t.Helper()
+	f, err := moderation.NewFilter(words, nil)
+	if err != nil {
+		t.Fatalf("NewFilter: %v", err)
+	}
+	modFilterOnce.Do(func() {})
+	saved := modFilter
+	modFilter = f
+	t.Cleanup(func() { modFilter = saved })
+}
+
+func TestChatModeratesPersona(t *testing.T) {
+	useModFilter(t, "darn")
+	t.Setenv("MODERATION_DEFAULT_LEVEL", "medium")
+	h := &Handler{store: db.NewMemoryStore()}
+	author := &discordgo.User{ID: "1"}
+
+	tests := []struct {
+		name string
+		req  chatRequest
+	}{
+		{"blocked persona", chatRequest{Author: author, Prompt: "hi", Persona: "a darn pirate"}},
+		{"blocked persona with a premoderated prompt", chatRequest{Author: author, Prompt: "hi", Persona: "a darn pirate", Premoderated: true}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			res := h.chat(context.Background(), nil, tt.req)
+			if res.Outcome != "refused_input" || res.Exchange != nil {
+				t.Errorf("chat = %+v, want the persona refused", res)
+			}
+		})
+	}
+}
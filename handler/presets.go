@@ -0,0 +1,208 @@
+package handler
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"time"
+
+	"discord-ai-bot/db"
+	"discord-ai-bot/logging"
+	"discord-ai-bot/presence"
+
+	"github.com/bwmarrin/discordgo"
+)
+
+// Custom IDs for the presets menu opened from /config
+const (
+	buttonIDPresets      = "button_presets_config"
+	buttonIDPresetSave   = "preset_save"
+	selectIDPresetLoad   = "preset_load"
+	selectIDPresetDelete = "preset_delete"
+	modalIDPresetSave    = "modal_preset_save"
+)
+
+// maxPresetName keeps preset names readable in select menus.
+const maxPresetName = 50
+
+// Presets button -> shows the presets menu in place of /config's
+func (h *Handler) handlePresetsMenu(ctx context.Context, s *discordgo.Session, i *discordgo.InteractionCreate) {
+	h.respondPresets(ctx, s, i, "")
+}
+
+// Save current button -> asks for the new preset's name
+func (h *Handler) handlePresetSave(ctx context.Context, s *discordgo.Session, i *discordgo.InteractionCreate) {
+	modal := discordgo.InteractionResponseData{
+		CustomID: modalIDPresetSave,
+		Title:    "Save Current Status as Preset",
+		Components: []discordgo.MessageComponent{
+			discordgo.ActionsRow{Components: []discordgo.MessageComponent{
+				discordgo.TextInput{CustomID: "preset_name_input", Label: "Preset Name (an existing one is replaced)", Style: discordgo.TextInputShort, Placeholder: "maintenance", Required: true, MaxLength: maxPresetName},
+			}},
+		},
+	}
+	err := s.InteractionRespond(i.Interaction, &discordgo.InteractionResponse{Type: discordgo.InteractionResponseModal, Data: &modal})
+	if err != nil {
+		logging.FromContext(ctx).Error("opening preset name modal failed", "error", err)
+	}
+}
+
+// Preset name modal -> saves the current status under that name
+func (h *Handler) handlePresetSaveSubmit(ctx context.Context, s *discordgo.Session, i *discordgo.InteractionCreate) {
+	name := strings.Join(strings.Fields(i.ModalSubmitData().Components[0].(*discordgo.ActionsRow).Components[0].(*discordgo.TextInput).Value), " ")
+	if name == "" {
+		respondEphemeral(ctx, s, i, "The preset needs a name.")
+		return
+	}
+	status, err := h.store.LoadStatus()
+	if err != nil {
+		logging.FromContext(ctx).Error("loading status failed", "error", err)
+		respondEphemeral(ctx, s, i, "Couldn't load the current status, try again later.")
+		return
+	}
+	if status == nil {
+		respondEphemeral(ctx, s, i, "There's no saved status yet. Set one with Edit Activity first.")
+		return
+	}
+	presets, err := h.store.LoadStatusPresets()
+	if err != nil {
+		logging.FromContext(ctx).Error("loading status presets failed", "error", err)
+		respondEphemeral(ctx, s, i, "Couldn't load the presets, try again later.")
+		return
+	}
+	replaced := false
+	for _, p := range presets {
+		replaced = replaced || strings.EqualFold(p.Name, name)
+	}
+	if !replaced && len(presets) >= db.MaxPresets {
+		respondEphemeral(ctx, s, i, fmt.Sprintf("There are already %d presets. Delete one first.", db.MaxPresets))
+		return
+	}
+
+	preset := db.StatusPreset{Name: name, Status: *status, SavedBy: interactionUser(i).ID, SavedAt: time.Now().UTC()}
+	if err := h.store.SaveStatusPreset(preset); err != nil {
+		logging.FromContext(ctx).Error("saving status preset failed", "error", err)
+		respondEphemeral(ctx, s, i, "Couldn't save the preset, try again later.")
+		return
+	}
+	logging.FromContext(ctx).Info("status preset saved", "preset", name, "replaced", replaced)
+	h.respondPresets(ctx, s, i, fmt.Sprintf("Saved the current status as **%s**.\n\n", name))
+}
+
+// Load select menu -> makes the chosen preset the saved status and applies it
+func (h *Handler) handlePresetLoad(ctx context.Context, s *discordgo.Session, i *discordgo.InteractionCreate) {
+	name := firstValue(i)
+	presets, err := h.store.LoadStatusPresets()
+	if err != nil {
+		logging.FromContext(ctx).Error("loading status presets failed", "error", err)
+		respondEphemeral(ctx, s, i, "Couldn't load the presets, try again later.")
+		return
+	}
+	var preset *db.StatusPreset
+	for idx := range presets {
+		if strings.EqualFold(presets[idx].Name, name) {
+			preset = &presets[idx]
+		}
+	}
+	if preset == nil {
+		h.respondPresets(ctx, s, i, "That preset is gone.\n\n")
+		return
+	}
+
+	if err := h.store.SaveStatus(preset.Status); err != nil {
+		logging.FromContext(ctx).Error("saving status failed", "preset", preset.Name, "error", err)
+		respondEphemeral(ctx, s, i, "Couldn't load the preset, try again later.")
+		return
+	}
+	notice := fmt.Sprintf("Loaded **%s** and **Status Updated Successfully!**\n\n", preset.Name)
+	if source, err := h.presence.Apply(); err != nil {
+		logging.FromContext(ctx).Error("updating status failed", "preset", preset.Name, "error", err)
+		notice = fmt.Sprintf("Loaded **%s**, but **Status Update FAILED!** Check bot logs for details.\n\n", preset.Name)
+	} else if source != presence.SourceStatus {
+		notice = fmt.Sprintf("Loaded **%s**. Status rotation or AI status is on, so it shows once that's turned off.\n\n", preset.Name)
+	}
+	logging.FromContext(ctx).Info("status preset loaded", "preset", preset.Name)
+	h.respondPresets(ctx, s, i, notice)
+}
+
+// Delete select menu -> removes the chosen preset
+func (h *Handler) handlePresetDelete(ctx context.Context, s *discordgo.Session, i *discordgo.InteractionCreate) {
+	name := firstValue(i)
+	removed, err := h.store.DeleteStatusPreset(name)
+	if err != nil {
+		logging.FromContext(ctx).Error("deleting status preset failed", "preset", name, "error", err)
+		respondEphemeral(ctx, s, i, "Couldn't delete the preset, try again later.")
+		return
+	}
+	notice := "That preset is already gone.\n\n"
+	if removed {
+		logging.FromContext(ctx).Info("status preset deleted", "preset", name)
+		notice = fmt.Sprintf("Deleted **%s**.\n\n", name)
+	}
+	h.respondPresets(ctx, s, i, notice)
+}
+
+// firstValue returns the option picked in a single-choice select menu.
+func firstValue(i *discordgo.InteractionCreate) string {
+	if values := i.MessageComponentData().Values; len(values) > 0 {
+		return values[0]
+	}
+	return ""
+}
+
+// respondPresets redraws the presets menu in place.
+func (h *Handler) respondPresets(ctx context.Context, s *discordgo.Session, i *discordgo.InteractionCreate, prefix string) {
+	err := s.InteractionRespond(i.Interaction, &discordgo.InteractionResponse{
+		Type: discordgo.InteractionResponseUpdateMessage,
+		Data: h.presetsResponse(ctx, prefix),
+	})
+	if err != nil {
+		logging.FromContext(ctx).Error("updating presets menu failed", "error", err)
+	}
+}
+
+// presetsResponse renders the saved presets with menus to load and delete them.
+func (h *Handler) presetsResponse(ctx context.Context, prefix string) *discordgo.InteractionResponseData {
+	buttons := discordgo.ActionsRow{Components: []discordgo.MessageComponent{
+		discordgo.Button{Label: "Save Current as Preset", Style: discordgo.PrimaryButton, CustomID: buttonIDPresetSave},
+		discordgo.Button{Label: "Back", Style: discordgo.SecondaryButton, CustomID: buttonIDConfigBack},
+	}}
+	data := &discordgo.InteractionResponseData{Components: []discordgo.MessageComponent{buttons}}
+
+	presets, err := h.store.LoadStatusPresets()
+	if err != nil {
+		logging.FromContext(ctx).Error("loading status presets failed", "error", err)
+		data.Content = prefix + "Couldn't load the presets, try again later."
+		return data
+	}
+	if len(presets) == 0 {
+		data.Content = prefix + "**Presets**\n\nNo presets yet. Set up a status, then save it here to switch back to it in one click."
+		return data
+	}
+
+	var sb strings.Builder
+	sb.WriteString(prefix)
+	sb.WriteString("**Presets**\n\n")
+	load := make([]discordgo.SelectMenuOption, 0, len(presets))
+	remove := make([]discordgo.SelectMenuOption, 0, len(presets))
+	for _, p := range presets {
+		fmt.Fprintf(&sb, "**%s**: %s\n", p.Name, describePresence(p.Status))
+		option := discordgo.SelectMenuOption{
+			Label:       p.Name,
+			Value:       p.Name,
+			Description: truncate(plainText(describePresence(p.Status)), 100),
+		}
+		load = append(load, option)
+		remove = append(remove, option)
+	}
+	data.Content = truncate(sb.String(), 2000)
+	data.Components = append(data.Components,
+		discordgo.ActionsRow{Components: []discordgo.MessageComponent{
+			discordgo.SelectMenu{CustomID: selectIDPresetLoad, Placeholder: "Load a preset", Options: load},
+		}},
+		discordgo.ActionsRow{Components: []discordgo.MessageComponent{
+			discordgo.SelectMenu{CustomID: selectIDPresetDelete, Placeholder: "Delete a preset", Options: remove},
+		}},
+	)
+	return data
+}
@@ -0,0 +1,220 @@
+package handler
+
+import (
+	"context"
+	"fmt"
+	"reflect"
+	"strings"
+	"time"
+
+	"discord-ai-bot/logging"
+	"discord-ai-bot/presence"
+
+	"github.com/bwmarrin/discordgo"
+)
+
+// Custom IDs for the preview shown before a /config change is applied
+const (
+	buttonIDPresenceApply = "presence_apply"
+	buttonIDPresenceDrop  = "presence_discard"
+)
+
+// draftTTL is how long a previewed change can still be applied.
+const draftTTL = 15 * time.Minute
+
+// statusDraft is a /config change waiting in a preview for Apply.
+type statusDraft struct {
+	// what names the settings that changed, e.g. "Custom status".
+	what string
+	// change is re-run on the status saved when Apply is pressed, so only
+	// the settings it touches are replaced.
+	change func(*discordgo.UpdateStatusData)
+	// preview is the status the user was shown.
+	preview discordgo.UpdateStatusData
+	expires time.Time
+}
+
+// draftKey identifies a draft by the user and the menu message it was
+// previewed in, so two open /config menus don't share one.
+func draftKey(i *discordgo.InteractionCreate) string {
+	messageID := ""
+	if i.Message != nil {
+		messageID = i.Message.ID
+	}
+	return interactionUser(i).ID + "/" + messageID
+}
+
+// storeDraft saves draft under key, dropping any drafts that have expired.
+func (h *Handler) storeDraft(key string, draft statusDraft) {
+	now := time.Now()
+	h.drafts.Range(func(k, v any) bool {
+		if now.After(v.(statusDraft).expires) {
+			h.drafts.Delete(k)
+		}
+		return true
+	})
+	h.drafts.Store(key, draft)
+}
+
+// loadDraft returns the unexpired draft saved under key.
+func (h *Handler) loadDraft(key string) (statusDraft, bool) {
+	value, ok := h.drafts.Load(key)
+	if !ok {
+		return statusDraft{}, false
+	}
+	draft := value.(statusDraft)
+	if time.Now().After(draft.expires) {
+		h.drafts.Delete(key)
+		return statusDraft{}, false
+	}
+	return draft, true
+}
+
+// updateStatus applies change to the saved status, validates the result and
+// redraws the /config menu as a preview of it. Nothing is saved until the
+// preview's Apply button is pressed. Invalid results are listed in an
+// ephemeral reply.
+func (h *Handler) updateStatus(ctx context.Context, s *discordgo.Session, i *discordgo.InteractionCreate, what string, change func(*discordgo.UpdateStatusData)) {
+	status, ok := h.changedStatus(ctx, s, i, change)
+	if !ok {
+		return
+	}
+	h.storeDraft(draftKey(i), statusDraft{what: what, change: change, preview: status, expires: time.Now().Add(draftTTL)})
+	h.respondPreview(ctx, s, i, "", what, status)
+}
+
+// changedStatus loads the saved status and applies change to it, replying
+// with the problems and reporting false if the result isn't valid.
+func (h *Handler) changedStatus(ctx context.Context, s *discordgo.Session, i *discordgo.InteractionCreate, change func(*discordgo.UpdateStatusData)) (discordgo.UpdateStatusData, bool) {
+	status, err := h.store.LoadStatus()
+	if err != nil {
+		logging.FromContext(ctx).Error("loading status failed", "error", err)
+		respondEphemeral(ctx, s, i, "Couldn't load the current status, nothing was saved.")
+		return discordgo.UpdateStatusData{}, false
+	}
+	if status == nil {
+		status = &discordgo.UpdateStatusData{Status: "online"}
+	}
+	change(status)
+	if problems := presence.Validate(*status); len(problems) > 0 {
+		respondEphemeral(ctx, s, i, problemsMessage(problems))
+		return discordgo.UpdateStatusData{}, false
+	}
+	return *status, true
+}
+
+// respondPreview redraws the menu in place as a preview of status with
+// Apply and Discard buttons.
+func (h *Handler) respondPreview(ctx context.Context, s *discordgo.Session, i *discordgo.InteractionCreate, notice, what string, status discordgo.UpdateStatusData) {
+	err := s.InteractionRespond(i.Interaction, &discordgo.InteractionResponse{
+		Type: discordgo.InteractionResponseUpdateMessage,
+		Data: &discordgo.InteractionResponseData{
+			Content: truncate(notice+"**Preview** of the "+strings.ToLower(what)+" change\n"+h.previewPresence(status)+"\n\nApply this presence?", 2000),
+			Components: []discordgo.MessageComponent{
+				discordgo.ActionsRow{Components: []discordgo.MessageComponent{
+					discordgo.Button{Label: "Apply", Style: discordgo.SuccessButton, CustomID: buttonIDPresenceApply},
+					discordgo.Button{Label: "Discard", Style: discordgo.SecondaryButton, CustomID: buttonIDPresenceDrop},
+				}},
+			},
+		},
+	})
+	if err != nil {
+		logging.FromContext(ctx).Error("showing presence preview failed", "error", err)
+	}
+}
+
+// Apply button -> saves and applies the previewed change. The change is made
+// again on the saved status, so edits saved since the preview are kept; if
+// that changes the result, the new result is previewed instead.
+func (h *Handler) handlePresenceApply(ctx context.Context, s *discordgo.Session, i *discordgo.InteractionCreate) {
+	key := draftKey(i)
+	draft, ok := h.loadDraft(key)
+	if !ok {
+		respondEphemeral(ctx, s, i, "That preview has expired. Make the change again from /config.")
+		return
+	}
+	status, ok := h.changedStatus(ctx, s, i, draft.change)
+	if !ok {
+		return
+	}
+	if !reflect.DeepEqual(status, draft.preview) {
+		draft.preview, draft.expires = status, time.Now().Add(draftTTL)
+		h.storeDraft(key, draft)
+		h.respondPreview(ctx, s, i, "The status was changed elsewhere since this preview, so here it is again.\n\n", draft.what, status)
+		return
+	}
+	h.drafts.Delete(key)
+
+	// Applying can take a moment, so acknowledge first
+	err := s.InteractionRespond(i.Interaction, &discordgo.InteractionResponse{
+		Type: discordgo.InteractionResponseDeferredMessageUpdate,
+	})
+	if err != nil {
+		logging.FromContext(ctx).Error("deferring status update failed", "error", err)
+		return
+	}
+	_, err = s.InteractionResponseEdit(i.Interaction, &discordgo.WebhookEdit{
+		Content:    ptr(h.saveAndApplyStatus(ctx, status, draft.what)),
+		Components: &configButtons,
+	})
+	if err != nil {
+		logging.FromContext(ctx).Error("editing deferred interaction response failed", "error", err)
+	}
+}
+
+// Discard button -> drops the previewed change and returns to /config
+func (h *Handler) handlePresenceDiscard(ctx context.Context, s *discordgo.Session, i *discordgo.InteractionCreate) {
+	h.drafts.Delete(draftKey(i))
+	err := s.InteractionRespond(i.Interaction, &discordgo.InteractionResponse{
+		Type: discordgo.InteractionResponseUpdateMessage,
+		Data: &discordgo.InteractionResponseData{Content: "Change discarded, nothing was saved.", Components: configButtons},
+	})
+	if err != nil {
+		logging.FromContext(ctx).Error("updating /config menu failed", "error", err)
+	}
+}
+
+// statusEmojis name the online statuses in previews.
+var statusEmojis = map[string]string{
+	"online":    "🟢 Online",
+	"idle":      "🌙 Idle",
+	"dnd":       "⛔ Do Not Disturb",
+	"invisible": "⚫ Invisible",
+}
+
+// previewPresence renders a presence roughly as a profile shows it, with
+// placeholders filled in, as a quote block.
+func (h *Handler) previewPresence(status discordgo.UpdateStatusData) string {
+	status = h.presence.Expand(status)
+	online, ok := statusEmojis[status.Status]
+	if !ok {
+		online = status.Status
+	}
+	lines := []string{online}
+	if custom := customActivity(&status); custom != nil {
+		lines = append(lines, strings.TrimSpace(emojiText(custom.Emoji)+" "+custom.State))
+	}
+	if act := mainActivity(&status); act != nil {
+		lines = append(lines, activityVerbs[act.Type]+" **"+act.Name+"**")
+		if act.Details != "" {
+			lines = append(lines, act.Details)
+		}
+		if act.Assets.LargeImageID != "" {
+			lines = append(lines, "🖼️ "+act.Assets.LargeImageID+tooltip(act.Assets.LargeText))
+		}
+		if act.Assets.SmallImageID != "" {
+			lines = append(lines, "🔹 "+act.Assets.SmallImageID+tooltip(act.Assets.SmallText))
+		}
+		if act.Type == discordgo.ActivityTypeStreaming {
+			lines = append(lines, "🔗 <"+act.URL+">")
+		}
+	}
+	return "> " + strings.Join(lines, "\n> ")
+}
+
+func tooltip(text string) string {
+	if text == "" {
+		return ""
+	}
+	return fmt.Sprintf(" (%q on hover)", text)
+}
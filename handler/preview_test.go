@@ -0,0 +1,188 @@
+package handler
+
+import (
+	"context"
+	"encoding/json"
+	"io"
+	"net/http"
+	"strings"
+	"testing"
+	"time"
+
+	"discord-ai-bot/db"
+	"discord-ai-bot/presence"
+
+	"github.com/bwmarrin/discordgo"
+)
+
+func menuInteraction(userID, messageID string) *discordgo.InteractionCreate {
+	return &discordgo.InteractionCreate{Interaction: &discordgo.Interaction{
+		User:    &discordgo.User{ID: userID},
+		Message: &discordgo.Message{ID: messageID},
+	}}
+}
+
+func TestStatusDrafts(t *testing.T) {
+	h := &Handler{}
+	first, second := draftKey(menuInteraction("1", "a")), draftKey(menuInteraction("1", "b"))
+	if first == second {
+		t.Fatalf("two menus of one user share draft key %q", first)
+	}
+
+	h.storeDraft(first, statusDraft{what: "Status", expires: time.Now().Add(draftTTL)})
+	h.storeDraft(second, statusDraft{what: "Custom status", expires: time.Now().Add(-time.Second)})
+	if draft, ok := h.loadDraft(first); !ok || draft.what != "Status" {
+		t.Errorf("loadDraft(%q) = %+v, %v; want the Status draft", first, draft, ok)
+	}
+	if _, ok := h.loadDraft(second); ok {
+		t.Errorf("loadDraft(%q) returned an expired draft", second)
+	}
+	if _, ok := h.loadDraft(draftKey(menuInteraction("2", "a"))); ok {
+		t.Error("loadDraft returned another user's draft")
+	}
+
+	// Storing a draft drops the expired ones
+	h.drafts.Store(second, statusDraft{expires: time.Now().Add(-time.Second)})
+	h.storeDraft(first, statusDraft{what: "Status", expires: time.Now().Add(draftTTL)})
+	if _, ok := h.drafts.Load(second); ok {
+		t.Error("storeDraft kept an expired draft")
+	}
+}
+
+// recordedResponse is an interaction response sent to Discord.
+type recordedResponse struct {
+	method string
+	body   map[string]any
+}
+
+// recordingTransport answers every Discord API request with an empty object
+// and records the interaction responses.
+type recordingTransport struct {
+	responses []recordedResponse
+}
+
+func (rt *recordingTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	body := map[string]any{}
+	if req.Body != nil {
+		json.NewDecoder(req.Body).Decode(&body)
+	}
+	rt.responses = append(rt.responses, recordedResponse{method: req.Method, body: body})
+	return &http.Response{
+		StatusCode: http.StatusOK,
+		Header:     http.Header{"Content-Type": {"application/json"}},
+		Body:       io.NopCloser(strings.NewReader("{}")),
+		Request:    req,
+	}, nil
+}
+
+// last returns the most recent interaction response.
+func (rt *recordingTransport) last(t *testing.T) recordedResponse {
+	t.Helper()
+	if len(rt.responses) == 0 {
+		t.Fatal("no interaction response was sent")
+	}
+	return rt.responses[len(rt.responses)-1]
+}
+
+func newPreviewHandler(t *testing.T) (*Handler, *discordgo.Session, *recordingTransport) {
+	t.Helper()
+	s, err := discordgo.New("Bot test")
+	if err != nil {
+		t.Fatalf("discordgo.New: %v", err)
+	}
+	rt := &recordingTransport{}
+	s.Client = &http.Client{Transport: rt}
+	store := db.NewMemoryStore()
+	return New(store, presence.New(s, store)), s, rt
+}
+
+func playingStatus(game string) discordgo.UpdateStatusData {
+	return discordgo.UpdateStatusData{Status: "online", Activities: []*discordgo.Activity{{Type: discordgo.ActivityTypeGame, Name: game}}}
+}
+
+func setIdle(status *discordgo.UpdateStatusData) { status.Status = "idle" }
+
+func TestPresenceApplySavesPreview(t *testing.T) {
+	h, s, rt := newPreviewHandler(t)
+	ctx := context.Background()
+	mustSave(t, h.store, playingStatus("Minecraft"))
+	i := menuInteraction("1", "menu")
+
+	h.updateStatus(ctx, s, i, "Status", setIdle)
+	if saved, _ := h.store.LoadStatus(); saved.Status != "online" {
+		t.Fatalf("previewing saved the status: %+v", saved)
+	}
+
+	h.handlePresenceApply(ctx, s, i)
+	if saved, _ := h.store.LoadStatus(); describe(saved) != "idle Minecraft" {
+		t.Errorf("status after Apply = %s, want idle Minecraft", describe(saved))
+	}
+	if _, ok := h.loadDraft(draftKey(i)); ok {
+		t.Error("Apply kept the draft")
+	}
+	if got := rt.last(t); got.method != http.MethodPatch {
+		t.Errorf("last response = %s %v, want the deferred reply to be edited", got.method, got.body)
+	}
+}
+
+func TestPresenceApplyRepreviewsStaleDraft(t *testing.T) {
+	h, s, rt := newPreviewHandler(t)
+	ctx := context.Background()
+	mustSave(t, h.store, playingStatus("Minecraft"))
+	i := menuInteraction("1", "menu")
+
+	h.updateStatus(ctx, s, i, "Status", setIdle)
+	// Someone else saves a different activity before Apply is pressed
+	mustSave(t, h.store, playingStatus("Terraria"))
+
+	h.handlePresenceApply(ctx, s, i)
+	if saved, _ := h.store.LoadStatus(); describe(saved) != "online Terraria" {
+		t.Fatalf("Apply of a stale preview saved %s", describe(saved))
+	}
+	got := rt.last(t)
+	content, _ := got.body["data"].(map[string]any)["content"].(string)
+	if got.body["type"] != float64(discordgo.InteractionResponseUpdateMessage) || !strings.Contains(content, "changed elsewhere") || !strings.Contains(content, "Terraria") {
+		t.Errorf("response to a stale Apply = %v, want a new preview of the current status", got.body)
+	}
+
+	// The new preview holds the change made on top of the current status
+	draft, ok := h.loadDraft(draftKey(i))
+	if !ok || describe(&draft.preview) != "idle Terraria" {
+		t.Fatalf("draft after a stale Apply = %s, %v; want a preview of idle Terraria", describe(&draft.preview), ok)
+	}
+	h.handlePresenceApply(ctx, s, i)
+	if saved, _ := h.store.LoadStatus(); describe(saved) != "idle Terraria" {
+		t.Errorf("status after applying the new preview = %s, want idle Terraria", describe(saved))
+	}
+}
+
+func TestPresenceApplyExpiredDraft(t *testing.T) {
+	h, s, rt := newPreviewHandler(t)
+	h.handlePresenceApply(context.Background(), s, menuInteraction("1", "menu"))
+	content, _ := rt.last(t).body["data"].(map[string]any)["content"].(string)
+	if !strings.Contains(content, "expired") {
+		t.Errorf("Apply without a draft replied %q, want it to say the preview expired", content)
+	}
+	if saved, _ := h.store.LoadStatus(); saved != nil {
+		t.Errorf("Apply without a draft saved %+v", saved)
+	}
+}
+
+// describe summarises a status as its online status and activity names.
+func describe(status *discordgo.UpdateStatusData) string {
+	if status == nil {
+		return "nothing"
+	}
+	parts := []string{status.Status}
+	for _, act := range status.Activities {
+		parts = append(parts, act.Name)
+	}
+	return strings.Join(parts, " ")
+}
+
+func mustSave(t *testing.T, store db.Store, status discordgo.UpdateStatusData) {
+	t.Helper()
+	if err := store.SaveStatus(status); err != nil {
+		t.Fatalf("SaveStatus: %v", err)
+	}
+}
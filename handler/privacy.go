@@ -0,0 +1,62 @@
+package handler
+
+import (
+	"context"
+	"fmt"
+
+	"discord-ai-bot/logging"
+
+	"github.com/bwmarrin/discordgo"
+)
+
+// Custom ID for the confirmation button shown by /forgetme
+const buttonIDForgetMe = "button_forgetme_confirm"
+
+// /forgetme -> asks the user to confirm deleting everything stored about them
+func (h *Handler) handleForgetMeCommand(ctx context.Context, s *discordgo.Session, i *discordgo.InteractionCreate) {
+	err := s.InteractionRespond(i.Interaction, &discordgo.InteractionResponse{
+		Type: discordgo.InteractionResponseChannelMessageWithSource,
+		Data: &discordgo.InteractionResponseData{
+			Content: "This permanently deletes your messages from my conversation history in every " +
+				"server and DM, everything I remember about you, your token usage records and your reply feedback. It can't be undone.\n\n" +
+				"Messages saved before I started recording who wrote what can't be matched to you and are kept.",
+			Flags: discordgo.MessageFlagsEphemeral,
+			Components: []discordgo.MessageComponent{
+				discordgo.ActionsRow{Components: []discordgo.MessageComponent{
+					discordgo.Button{Label: "Delete my data", Style: discordgo.DangerButton, CustomID: buttonIDForgetMe},
+				}},
+			},
+		},
+	})
+	if err != nil {
+		logging.FromContext(ctx).Error("responding to /forgetme command failed", "error", err)
+	}
+}
+
+// Handles the /forgetme confirmation button.
+func (h *Handler) handleForgetMeConfirm(ctx context.Context, s *discordgo.Session, i *discordgo.InteractionCreate) {
+	user := interactionUser(i)
+	content := "Couldn't delete your data, try again later."
+	res, err := h.store.ForgetUser(user.ID)
+	if err != nil {
+		logging.FromContext(ctx).Error("forgetting user failed", "error", err)
+	} else {
+		logging.FromContext(ctx).Info("user data deleted on request",
+			"messages", res.Messages, "archived", res.Archived, "facts", res.Facts, "usage_records", res.UsageRecords,
+			"feedback", res.Feedback, "unattributed", res.Unattributed)
+		content = fmt.Sprintf("Done. Deleted %d message(s), %d archived exchange(s), %d fact(s), %d usage record(s) and %d feedback vote(s).",
+			res.Messages, res.Archived, res.Facts, res.UsageRecords, res.Feedback)
+		if res.Unattributed > 0 {
+			content += fmt.Sprintf("\n\n%d older message(s) were saved before I recorded who wrote what, so I can't tell "+
+				"whether they're yours and couldn't delete them. Ask the bot's owner if you need them removed.", res.Unattributed)
+		}
+	}
+
+	err = s.InteractionRespond(i.Interaction, &discordgo.InteractionResponse{
+		Type: discordgo.InteractionResponseUpdateMessage,
+		Data: &discordgo.InteractionResponseData{Content: content, Components: []discordgo.MessageComponent{}},
+	})
+	if err != nil {
+		logging.FromContext(ctx).Error("updating /forgetme message failed", "error", err)
+	}
+}
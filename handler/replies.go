@@ -0,0 +1,216 @@
+package handler
+
+import (
+	"context"
+	"time"
+
+	"discord-ai-bot/db"
+	"discord-ai-bot/logging"
+
+	"github.com/bwmarrin/discordgo"
+)
+
+// Custom IDs for the buttons under AI replies
+const (
+	buttonIDRegenerate   = "reply_regenerate"
+	buttonIDContinue     = "reply_continue"
+	buttonIDFeedbackUp   = "reply_feedback_up"
+	buttonIDFeedbackDown = "reply_feedback_down"
+)
+
+// continuePrompt is the user turn recorded when someone presses Continue.
+const continuePrompt = "Continue exactly where you left off."
+
+// replyButtons are attached to every AI reply.
+var replyButtons = []discordgo.MessageComponent{
+	discordgo.ActionsRow{
+		Components: []discordgo.MessageComponent{
+			discordgo.Button{Label: "Regenerate", Style: discordgo.SecondaryButton, CustomID: buttonIDRegenerate, Emoji: &discordgo.ComponentEmoji{Name: "🔄"}},
+			discordgo.Button{Label: "Continue", Style: discordgo.SecondaryButton, CustomID: buttonIDContinue, Emoji: &discordgo.ComponentEmoji{Name: "⏩"}},
+			discordgo.Button{Style: discordgo.SecondaryButton, CustomID: buttonIDFeedbackUp, Emoji: &discordgo.ComponentEmoji{Name: "👍"}},
+			discordgo.Button{Style: discordgo.SecondaryButton, CustomID: buttonIDFeedbackDown, Emoji: &discordgo.ComponentEmoji{Name: "👎"}},
+		},
+	},
+}
+
+// components returns the buttons to post with a result. Only actual AI
+// replies get them; refusals and notices stay plain.
+func (r chatResult) components() []discordgo.MessageComponent {
+	if r.Exchange == nil {
+		return nil
+	}
+	return replyButtons
+}
+
+// latestReply finds the assistant turn posted as messageID, provided it is
+// the newest reply in the history and follows the prompt it answered. It
+// returns the turn's index, or -1.
+func latestReply(history []db.Turn, messageID string) int {
+	for idx := len(history) - 1; idx >= 0; idx-- {
+		if history[idx].Role != "assistant" {
+			continue
+		}
+		if history[idx].MessageID != messageID || idx == 0 || history[idx-1].Role != "user" {
+			return -1
+		}
+		return idx
+	}
+	return -1
+}
+
+// replyTarget loads the history for a Regenerate or Continue press and checks
+// that the pressed reply is the latest one and belongs to the presser. On
+// failure it tells the presser why and returns ok=false.
+func (h *Handler) replyTarget(ctx context.Context, s *discordgo.Session, i *discordgo.InteractionCreate, scope string) (history []db.Turn, idx int, ok bool) {
+	history, err := h.store.LoadHistory(scope)
+	if err != nil {
+		logging.FromContext(ctx).Error("loading history failed", "error", err)
+		followupEphemeral(ctx, s, i, "Couldn't load the conversation, try again later.")
+		return nil, -1, false
+	}
+	idx = latestReply(history, i.Message.ID)
+	if idx < 0 {
+		followupEphemeral(ctx, s, i, "Only the latest reply in the conversation can be regenerated or continued.")
+		return nil, -1, false
+	}
+	if history[idx].UserID != interactionUser(i).ID {
+		followupEphemeral(ctx, s, i, "Only the person who asked can regenerate or continue this reply.")
+		return nil, -1, false
+	}
+	if notice, exceeded := h.quotaExceeded(ctx, i.GuildID); exceeded {
+		followupEphemeral(ctx, s, i, notice)
+		return nil, -1, false
+	}
+	return history, idx, true
+}
+
+// Regenerate button -> asks the same prompt again and replaces the reply
+func (h *Handler) handleRegenerate(ctx context.Context, s *discordgo.Session, i *discordgo.InteractionCreate) {
+	err := s.InteractionRespond(i.Interaction, &discordgo.InteractionResponse{
+		Type: discordgo.InteractionResponseDeferredMessageUpdate,
+	})
+	if err != nil {
+		logging.FromContext(ctx).Error("deferring regenerate failed", "error", err)
+		return
+	}
+
+	scope := historyScope(s, i.ChannelID)
+	history, idx, ok := h.replyTarget(ctx, s, i, scope)
+	if !ok {
+		return
+	}
+	req := chatRequest{GuildID: i.GuildID, ChannelID: i.ChannelID, Author: interactionUser(i), Prompt: history[idx-1].Content, Scope: scope}
+	res := h.answer(ctx, s, req, history[:idx-1])
+	if res.Exchange == nil {
+		followupEphemeral(ctx, s, i, res.Content)
+		return
+	}
+
+	_, err = s.InteractionResponseEdit(i.Interaction, &discordgo.WebhookEdit{
+		Content:         ptr(sanitizeMentions(s, i.GuildID, res.Content)),
+		Components:      &replyButtons,
+		AllowedMentions: allowedMentions(),
+	})
+	if err != nil {
+		logging.FromContext(ctx).Error("editing regenerated reply failed", "error", err)
+		return
+	}
+	h.replaceTurn(ctx, scope, i.Message.ID, res.Exchange[1].Content)
+}
+
+// Continue button -> asks the model to keep going in a new reply
+func (h *Handler) handleContinue(ctx context.Context, s *discordgo.Session, i *discordgo.InteractionCreate) {
+	err := s.InteractionRespond(i.Interaction, &discordgo.InteractionResponse{
+		Type: discordgo.InteractionResponseDeferredMessageUpdate,
+	})
+	if err != nil {
+		logging.FromContext(ctx).Error("deferring continue failed", "error", err)
+		return
+	}
+
+	scope := historyScope(s, i.ChannelID)
+	history, _, ok := h.replyTarget(ctx, s, i, scope)
+	if !ok {
+		return
+	}
+	req := chatRequest{GuildID: i.GuildID, ChannelID: i.ChannelID, Author: interactionUser(i), Prompt: continuePrompt, Scope: scope}
+	res := h.answer(ctx, s, req, history)
+	if res.Exchange == nil {
+		followupEphemeral(ctx, s, i, res.Content)
+		return
+	}
+
+	msg, err := s.ChannelMessageSendComplex(i.ChannelID, &discordgo.MessageSend{
+		Content:         sanitizeMentions(s, i.GuildID, res.Content),
+		Reference:       &discordgo.MessageReference{MessageID: i.Message.ID, ChannelID: i.ChannelID, GuildID: i.GuildID},
+		AllowedMentions: allowedMentions(),
+		Components:      replyButtons,
+	})
+	if err != nil {
+		logging.FromContext(ctx).Error("sending continuation failed", "error", err)
+		followupEphemeral(ctx, s, i, "Couldn't post the continuation, try again later.")
+		return
+	}
+	h.saveExchange(ctx, scope, res.Exchange, "", msg.ID)
+}
+
+// replaceTurn swaps the content of the turn in scope posted as messageID.
+func (h *Handler) replaceTurn(ctx context.Context, scope, messageID, content string) {
+	logger := logging.FromContext(ctx)
+	history, err := h.store.LoadHistory(scope)
+	if err != nil {
+		logger.Error("loading history failed", "error", err)
+		return
+	}
+	for idx := range history {
+		if history[idx].MessageID == messageID {
+			history[idx].Content = content
+			history[idx].CreatedAt = time.Now().UTC()
+			if err := h.store.SaveHistory(scope, history); err != nil {
+				logger.Error("saving history failed", "error", err)
+			}
+			return
+		}
+	}
+}
+
+// 👍/👎 buttons -> stores the presser's rating of the reply with its prompt
+func (h *Handler) handleFeedback(ctx context.Context, s *discordgo.Session, i *discordgo.InteractionCreate, rating string) {
+	f := db.Feedback{
+		MessageID: i.Message.ID,
+		GuildID:   i.GuildID,
+		ChannelID: i.ChannelID,
+		UserID:    interactionUser(i).ID,
+		Rating:    rating,
+		Reply:     i.Message.Content,
+		CreatedAt: time.Now().UTC(),
+	}
+
+	history, err := h.store.LoadHistory(historyScope(s, i.ChannelID))
+	if err != nil {
+		logging.FromContext(ctx).Warn("loading history for feedback failed", "error", err)
+	}
+	for idx := len(history) - 1; idx > 0; idx-- {
+		if history[idx].MessageID == i.Message.ID && history[idx-1].Role == "user" {
+			f.Prompt = history[idx-1].Content
+			f.AuthorID = history[idx].UserID
+			f.Reply = history[idx].Content
+			break
+		}
+	}
+	// Replies that left the rolling history still point at the question
+	if f.Prompt == "" && i.Message.ReferencedMessage != nil {
+		f.Prompt = i.Message.ReferencedMessage.Content
+		if i.Message.ReferencedMessage.Author != nil {
+			f.AuthorID = i.Message.ReferencedMessage.Author.ID
+		}
+	}
+
+	if err := h.store.SaveFeedback(f); err != nil {
+		logging.FromContext(ctx).Error("saving feedback failed", "error", err)
+		respondEphemeral(ctx, s, i, "Couldn't save your feedback, try again later.")
+		return
+	}
+	logging.FromContext(ctx).Info("feedback recorded", "rating", rating, "message_id", f.MessageID)
+	respondEphemeral(ctx, s, i, "Thanks for the feedback!")
+}
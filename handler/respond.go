@@ -0,0 +1,67 @@
+package handler
+
+import (
+	"context"
+
+	"discord-ai-bot/logging"
+	"discord-ai-bot/metrics"
+
+	"github.com/bwmarrin/discordgo"
+)
+
+// sendReply replies to a message through ChannelMessageSendComplex so the
+// configured allowed mentions policy applies to everything the bot posts.
+// components may be nil.
+func sendReply(ctx context.Context, s *discordgo.Session, m *discordgo.MessageCreate, content string, components []discordgo.MessageComponent) (*discordgo.Message, error) {
+	msg, err := s.ChannelMessageSendComplex(m.ChannelID, &discordgo.MessageSend{
+		Content:         sanitizeMentions(s, m.GuildID, content),
+		Reference:       createReply(m),
+		AllowedMentions: allowedMentions(),
+		Components:      components,
+	})
+	if err != nil {
+		metrics.Errors.Inc("discord_send")
+		logging.FromContext(ctx).Error("sending reply failed", "error", err)
+	}
+	return msg, err
+}
+
+// sendMessage posts to a channel without replying to anything, with the same
+// mention policy as sendReply. components may be nil.
+func sendMessage(ctx context.Context, s *discordgo.Session, channelID, guildID, content string, components []discordgo.MessageComponent) (*discordgo.Message, error) {
+	msg, err := s.ChannelMessageSendComplex(channelID, &discordgo.MessageSend{
+		Content:         sanitizeMentions(s, guildID, content),
+		AllowedMentions: allowedMentions(),
+		Components:      components,
+	})
+	if err != nil {
+		metrics.Errors.Inc("discord_send")
+		logging.FromContext(ctx).Error("sending message failed", "error", err)
+	}
+	return msg, err
+}
+
+// respondEphemeral answers an interaction with a message only the invoker can see.
+func respondEphemeral(ctx context.Context, s *discordgo.Session, i *discordgo.InteractionCreate, content string) {
+	err := s.InteractionRespond(i.Interaction, &discordgo.InteractionResponse{
+		Type: discordgo.InteractionResponseChannelMessageWithSource,
+		Data: &discordgo.InteractionResponseData{
+			Content: content,
+			Flags:   discordgo.MessageFlagsEphemeral,
+		},
+	})
+	if err != nil {
+		logging.FromContext(ctx).Error("responding to interaction failed", "error", err)
+	}
+}
+
+// followupEphemeral sends an ephemeral followup to an already deferred interaction.
+func followupEphemeral(ctx context.Context, s *discordgo.Session, i *discordgo.InteractionCreate, content string) {
+	_, err := s.FollowupMessageCreate(i.Interaction, true, &discordgo.WebhookParams{
+		Content: content,
+		Flags:   discordgo.MessageFlagsEphemeral,
+	})
+	if err != nil {
+		logging.FromContext(ctx).Error("sending followup message failed", "error", err)
+	}
+}
@@ -0,0 +1,139 @@
+package handler
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"sync"
+	"time"
+
+	"discord-ai-bot/ai"
+	"discord-ai-bot/db"
+	"discord-ai-bot/logging"
+	"discord-ai-bot/metrics"
+)
+
+// Defaults for the rolling history window and semantic retrieval.
+// Override with HISTORY_WINDOW, RETRIEVAL_TOP_K and RETRIEVAL_MIN_SCORE.
+const (
+	defaultHistoryWindow = 20
+	defaultRetrievalTopK = 3
+	defaultMinScore      = 0.35
+)
+
+var (
+	embedder     ai.Embedder
+	embedderOnce sync.Once
+)
+
+// getEmbedder builds the embedder lazily so it sees variables loaded from .env.
+func getEmbedder() ai.Embedder {
+	embedderOnce.Do(func() {
+		embedder = ai.NewEmbedderFromEnv()
+	})
+	return embedder
+}
+
+// retrievalPrompt finds exchanges archived from scope that are similar to the
+// question and formats them as extra system prompt context.
+func (h *Handler) retrievalPrompt(ctx context.Context, scope, question string) string {
+	k := envInt("RETRIEVAL_TOP_K", defaultRetrievalTopK)
+	if k <= 0 {
+		return ""
+	}
+
+	vectors, err := getEmbedder().Embed(ctx, []string{question})
+	if err != nil {
+		metrics.Errors.Inc("embedding")
+		logging.FromContext(ctx).Warn("embedding question for retrieval failed", "error", err)
+		return ""
+	}
+
+	matches, err := h.store.SearchArchive(scope, vectors[0], k, float32(envFloat("RETRIEVAL_MIN_SCORE", defaultMinScore)))
+	if err != nil {
+		logging.FromContext(ctx).Warn("searching archive failed", "error", err)
+		return ""
+	}
+	if len(matches) == 0 {
+		return ""
+	}
+
+	var sb strings.Builder
+	sb.WriteString("\n\nRelevant earlier exchanges (older than the recent chat, use only if helpful):")
+	for _, m := range matches {
+		fmt.Fprintf(&sb, "\n- User: %s\n  You: %s", truncate(m.Question, 500), truncate(m.Answer, 500))
+	}
+	return sb.String()
+}
+
+// turnMessages strips stored turns down to what the model sees.
+func turnMessages(history []db.Turn) []ai.Message {
+	messages := make([]ai.Message, len(history))
+	for idx, t := range history {
+		messages[idx] = ai.Message{Role: t.Role, Content: t.Content}
+	}
+	return messages
+}
+
+// archive embeds exchanges, one text per exchange, and stores them in the archive.
+func (h *Handler) archive(ctx context.Context, exchanges []db.ArchivedExchange, texts []string) error {
+	vectors, err := getEmbedder().Embed(ctx, texts)
+	if err != nil {
+		metrics.Errors.Inc("embedding")
+		return fmt.Errorf("embedding: %w", err)
+	}
+	for idx := range exchanges {
+		exchanges[idx].Vector = vectors[idx]
+	}
+	return h.store.ArchiveExchanges(exchanges)
+}
+
+// trimHistory keeps roughly the newest HISTORY_WINDOW messages and moves older
+// user/assistant exchanges into the embedded archive. The cut never splits an
+// exchange, so the kept history always starts with a user message. Exchanges
+// that can't be archived are dropped.
+func (h *Handler) trimHistory(ctx context.Context, scope string, history []db.Turn) []db.Turn {
+	window := envInt("HISTORY_WINDOW", defaultHistoryWindow)
+	if window <= 0 || len(history) <= window {
+		return history
+	}
+
+	cut := len(history) - window
+	for cut < len(history) && history[cut].Role != "user" {
+		cut++
+	}
+
+	var exchanges []db.ArchivedExchange
+	var texts []string
+	overflow := history[:cut]
+	for idx := 0; idx < len(overflow); idx++ {
+		if overflow[idx].Role != "user" {
+			continue
+		}
+		ex := db.ArchivedExchange{
+			Question:  overflow[idx].Content,
+			CreatedAt: overflow[idx].CreatedAt,
+			Scope:     scope,
+			UserID:    overflow[idx].UserID,
+		}
+		if ex.CreatedAt.IsZero() {
+			ex.CreatedAt = time.Now().UTC()
+		}
+		if idx+1 < len(overflow) && overflow[idx+1].Role == "assistant" {
+			ex.Answer = overflow[idx+1].Content
+			idx++
+		}
+		exchanges = append(exchanges, ex)
+		texts = append(texts, ex.Question+"\n"+ex.Answer)
+	}
+
+	// The window is a hard cap: when archiving fails the overflow is dropped
+	// rather than kept, or history would grow for as long as the embedder is down
+	if len(exchanges) > 0 {
+		if err := h.archive(ctx, exchanges, texts); err != nil {
+			logging.FromContext(ctx).Warn("archiving exchanges failed, dropping them", "count", len(exchanges), "error", err)
+		}
+	}
+
+	return append([]db.Turn(nil), history[cut:]...)
+}
@@ -0,0 +1,119 @@
+package handler
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"testing"
+
+	"discord-ai-bot/ai"
+	"discord-ai-bot/db"
+)
+
+// failingEmbedder is an embeddings API that is down.
+type failingEmbedder struct{}
+
+func (failingEmbedder) Embed(context.Context, []string) ([][]float32, error) {
+	return nil, errors.New("embeddings API unavailable")
+}
+
+// useEmbedder replaces the embedder for the rest of the test.
+func useEmbedder(t *testing.T, e ai.Embedder) {
+	t.Helper()
+	getEmbedder() // run the lazy init now so it can't overwrite e later
+	previous := embedder
+	embedder = e
+	t.Cleanup(func() { embedder = previous })
+}
+
+func exchange(question, answer string) []db.Turn {
+	return []db.Turn{
+		{Role: "user", Content: question, UserID: "1"},
+		{Role: "assistant", Content: answer, UserID: "1"},
+	}
+}
+
+// TestArchiveRetrieval trims history into the archive and retrieves it again
+// with the local HashEmbedder, as the bot does without EMBEDDINGS_URL.
+func TestArchiveRetrieval(t *testing.T) {
+	t.Setenv("EMBEDDINGS_URL", "")
+	t.Setenv("HISTORY_WINDOW", "2")
+	t.Setenv("RETRIEVAL_TOP_K", "1")
+	t.Setenv("RETRIEVAL_MIN_SCORE", "0.2")
+	ctx := context.Background()
+	h := &Handler{store: db.NewMemoryStore()}
+	scope := db.ThreadScope("42")
+
+	var history []db.Turn
+	history = append(history, exchange("How do I reset my router password?", "Hold the reset button for ten seconds.")...)
+	history = append(history, exchange("What is a good pasta recipe?", "Try carbonara with eggs and pecorino.")...)
+	history = append(history, exchange("Thanks!", "You're welcome.")...)
+
+	kept := h.trimHistory(ctx, scope, history)
+	if len(kept) != 2 || kept[0].Content != "Thanks!" {
+		t.Fatalf("trimHistory kept %+v, want only the last exchange", kept)
+	}
+
+	tests := []struct {
+		name     string
+		scope    string
+		question string
+		want     string
+	}{
+		{"router question", scope, "I forgot the router password, how do I reset it?", "Hold the reset button"},
+		{"pasta question", scope, "Any pasta recipe with eggs?", "carbonara"},
+		{"unrelated question", scope, "Who won the football match?", ""},
+		{"other scope", db.GlobalScope, "How do I reset my router password?", ""},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := h.retrievalPrompt(ctx, tt.scope, tt.question)
+			if tt.want == "" {
+				if got != "" {
+					t.Errorf("retrievalPrompt(%q) = %q, want nothing", tt.question, got)
+				}
+				return
+			}
+			if !strings.Contains(got, tt.want) {
+				t.Errorf("retrievalPrompt(%q) = %q, want it to contain %q", tt.question, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestTrimHistoryKeepsExchangesWhole(t *testing.T) {
+	t.Setenv("EMBEDDINGS_URL", "")
+	t.Setenv("HISTORY_WINDOW", "3")
+	h := &Handler{store: db.NewMemoryStore()}
+
+	var history []db.Turn
+	for _, q := range []string{"one", "two", "three"} {
+		history = append(history, exchange(q, "answer "+q)...)
+	}
+	kept := h.trimHistory(context.Background(), db.GlobalScope, history)
+	if len(kept) != 2 || kept[0].Role != "user" || kept[0].Content != "three" {
+		t.Errorf("trimHistory kept %+v, want the last exchange starting with a user turn", kept)
+	}
+}
+
+func TestTrimHistoryCapsWhileEmbedderIsDown(t *testing.T) {
+	t.Setenv("HISTORY_WINDOW", "4")
+	useEmbedder(t, failingEmbedder{})
+	ctx := context.Background()
+	h := &Handler{store: db.NewMemoryStore()}
+
+	var history []db.Turn
+	for n := 0; n < 10; n++ {
+		history = append(history, exchange(strings.Repeat("q", n+1), "a")...)
+		history = h.trimHistory(ctx, db.GlobalScope, history)
+		if len(history) > 4 {
+			t.Fatalf("history has %d turns after %d exchanges, want at most 4", len(history), n+1)
+		}
+	}
+	if history[0].Role != "user" || history[len(history)-1].Content != "a" {
+		t.Errorf("trimHistory kept %+v, want whole exchanges", history)
+	}
+	if got, _ := h.store.SearchArchive(db.GlobalScope, make([]float32, 8), 100, -1); len(got) != 0 {
+		t.Errorf("archive has %d exchanges without embeddings, want none", len(got))
+	}
+}
@@ -0,0 +1,389 @@
+package handler
+
+import (
+	"context"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+
+	"discord-ai-bot/db"
+	"discord-ai-bot/logging"
+	"discord-ai-bot/presence"
+
+	"github.com/bwmarrin/discordgo"
+)
+
+// Custom IDs for the status rotation menu opened from /config
+const (
+	buttonIDRotation           = "button_rotation_config"
+	buttonIDRotationAddCurrent = "rotation_add_current"
+	buttonIDRotationAdd        = "rotation_add"
+	buttonIDRotationSchedule   = "rotation_schedule"
+	buttonIDRotationToggle     = "rotation_toggle"
+	buttonIDConfigBack         = "config_back"
+	selectIDRotationRemove     = "rotation_remove"
+	selectIDRotationStatus     = "rotation_entry_status"
+	selectIDRotationType       = "rotation_entry_type"
+	buttonIDRotationEntryNext  = "rotation_entry_next"
+	modalIDRotationEntry       = "modal_rotation_entry"
+	modalIDRotationSchedule    = "modal_rotation_schedule"
+)
+
+// Status rotation button -> shows the rotation menu in place of /config's
+func (h *Handler) handleRotationMenu(ctx context.Context, s *discordgo.Session, i *discordgo.InteractionCreate) {
+	h.updateRotation(ctx, s, i, func(*db.StatusRotation) (string, bool) { return "", false })
+}
+
+// Back button -> returns to the /config menu
+func (h *Handler) handleConfigBack(ctx context.Context, s *discordgo.Session, i *discordgo.InteractionCreate) {
+	err := s.InteractionRespond(i.Interaction, &discordgo.InteractionResponse{
+		Type: discordgo.InteractionResponseUpdateMessage,
+		Data: &discordgo.InteractionResponseData{Content: configMenuContent, Components: configButtons},
+	})
+	if err != nil {
+		logging.FromContext(ctx).Error("updating /config menu failed", "error", err)
+	}
+}
+
+// Add current status button -> appends the saved status to the rotation
+func (h *Handler) handleRotationAddCurrent(ctx context.Context, s *discordgo.Session, i *discordgo.InteractionCreate) {
+	status, err := h.store.LoadStatus()
+	if err != nil {
+		logging.FromContext(ctx).Error("loading status failed", "error", err)
+		respondEphemeral(ctx, s, i, "Couldn't load the current status, try again later.")
+		return
+	}
+	if status == nil {
+		respondEphemeral(ctx, s, i, "There's no saved status yet. Set one with Edit Activity first.")
+		return
+	}
+	h.updateRotation(ctx, s, i, func(rotation *db.StatusRotation) (string, bool) {
+		if len(rotation.Entries) >= db.MaxRotationEntries {
+			return fmt.Sprintf("The rotation is full (%d entries). Remove one first.\n\n", db.MaxRotationEntries), false
+		}
+		rotation.Entries = append(rotation.Entries, *status)
+		return "Added the current status.\n\n", true
+	})
+}
+
+// Add entry button -> shows select menus for the new entry's status and
+// activity type; the text fields follow in a modal
+func (h *Handler) handleRotationAdd(ctx context.Context, s *discordgo.Session, i *discordgo.InteractionCreate) {
+	h.respondRotationEntry(ctx, s, i, "online", "playing")
+}
+
+// Entry status select menu -> redraws the entry menu with the chosen status
+func (h *Handler) handleRotationStatusSelect(ctx context.Context, s *discordgo.Session, i *discordgo.InteractionCreate) {
+	h.respondRotationEntry(ctx, s, i, firstValue(i), selectedOption(i.Message, selectIDRotationType))
+}
+
+// Entry activity type select menu -> redraws the entry menu with the chosen type
+func (h *Handler) handleRotationTypeSelect(ctx context.Context, s *discordgo.Session, i *discordgo.InteractionCreate) {
+	h.respondRotationEntry(ctx, s, i, selectedOption(i.Message, selectIDRotationStatus), firstValue(i))
+}
+
+// respondRotationEntry redraws the menu in place as the new entry menu.
+func (h *Handler) respondRotationEntry(ctx context.Context, s *discordgo.Session, i *discordgo.InteractionCreate, status, activityType string) {
+	err := s.InteractionRespond(i.Interaction, &discordgo.InteractionResponse{
+		Type: discordgo.InteractionResponseUpdateMessage,
+		Data: rotationEntryResponse(status, activityType),
+	})
+	if err != nil {
+		logging.FromContext(ctx).Error("updating rotation entry menu failed", "error", err)
+	}
+}
+
+// rotationEntryResponse renders the new entry menu with status and
+// activityType selected.
+func rotationEntryResponse(status, activityType string) *discordgo.InteractionResponseData {
+	return &discordgo.InteractionResponseData{
+		Content: "**New Rotation Entry**\n\nPick the status and activity type, then continue to enter the text.",
+		Components: []discordgo.MessageComponent{
+			discordgo.ActionsRow{Components: []discordgo.MessageComponent{
+				discordgo.SelectMenu{CustomID: selectIDRotationStatus, Placeholder: "Status", Options: withDefault(onlineStatuses, status)},
+			}},
+			discordgo.ActionsRow{Components: []discordgo.MessageComponent{
+				discordgo.SelectMenu{CustomID: selectIDRotationType, Placeholder: "Activity type", Options: withDefault(activityTypeOptions, activityType)},
+			}},
+			discordgo.ActionsRow{Components: []discordgo.MessageComponent{
+				discordgo.Button{Label: "Continue", Style: discordgo.PrimaryButton, CustomID: buttonIDRotationEntryNext},
+				discordgo.Button{Label: "Cancel", Style: discordgo.SecondaryButton, CustomID: buttonIDRotation},
+			}},
+		},
+	}
+}
+
+// withDefault copies options, marking the one with value as selected.
+func withDefault(options []discordgo.SelectMenuOption, value string) []discordgo.SelectMenuOption {
+	marked := make([]discordgo.SelectMenuOption, len(options))
+	for n, option := range options {
+		option.Default = option.Value == value
+		marked[n] = option
+	}
+	return marked
+}
+
+// selectedOption returns the value shown as selected in msg's select menu
+// customID, i.e. the option rendered with Default set.
+func selectedOption(msg *discordgo.Message, customID string) string {
+	if msg == nil {
+		return ""
+	}
+	for _, row := range msg.Components {
+		row, ok := row.(*discordgo.ActionsRow)
+		if !ok {
+			continue
+		}
+		for _, component := range row.Components {
+			menu, ok := component.(*discordgo.SelectMenu)
+			if !ok || menu.CustomID != customID {
+				continue
+			}
+			for _, option := range menu.Options {
+				if option.Default {
+					return option.Value
+				}
+			}
+		}
+	}
+	return ""
+}
+
+// Continue button -> opens a modal for the new entry's text
+func (h *Handler) handleRotationEntryNext(ctx context.Context, s *discordgo.Session, i *discordgo.InteractionCreate) {
+	modal := discordgo.InteractionResponseData{
+		CustomID: modalIDRotationEntry,
+		Title:    "Add Rotation Entry",
+		Components: []discordgo.MessageComponent{
+			discordgo.ActionsRow{Components: []discordgo.MessageComponent{
+				discordgo.TextInput{CustomID: "activity_input", Label: "Activity Name ({guild_count}, {uptime} work)", Style: discordgo.TextInputShort, Placeholder: "with {guild_count} servers", Required: true, MaxLength: presence.MaxActivityText},
+			}},
+			discordgo.ActionsRow{Components: []discordgo.MessageComponent{
+				discordgo.TextInput{CustomID: "details_input", Label: "RPC Details", Style: discordgo.TextInputShort, Placeholder: "Up for {uptime}", Required: false, MaxLength: presence.MaxActivityText},
+			}},
+			discordgo.ActionsRow{Components: []discordgo.MessageComponent{
+				discordgo.TextInput{CustomID: "large_key_input", Label: "Large Image Asset Key", Style: discordgo.TextInputShort, Placeholder: "Optional", Required: false, MaxLength: 50},
+			}},
+			discordgo.ActionsRow{Components: []discordgo.MessageComponent{
+				discordgo.TextInput{CustomID: "url_input", Label: "Streaming URL (Twitch/YouTube Link)", Style: discordgo.TextInputShort, Placeholder: "Required if the type is streaming.", Required: false, MaxLength: 200},
+			}},
+		},
+	}
+	err := s.InteractionRespond(i.Interaction, &discordgo.InteractionResponse{Type: discordgo.InteractionResponseModal, Data: &modal})
+	if err != nil {
+		logging.FromContext(ctx).Error("opening rotation entry modal failed", "error", err)
+	}
+}
+
+// Schedule button -> opens a modal for the rotation interval or cron schedule
+func (h *Handler) handleRotationSchedule(ctx context.Context, s *discordgo.Session, i *discordgo.InteractionCreate) {
+	rotation, err := h.store.LoadStatusRotation()
+	if err != nil {
+		logging.FromContext(ctx).Error("loading status rotation failed", "error", err)
+		respondEphemeral(ctx, s, i, "Couldn't load the status rotation, try again later.")
+		return
+	}
+	interval := ""
+	if rotation.IntervalMinutes > 0 {
+		interval = (time.Duration(rotation.IntervalMinutes) * time.Minute).String()
+	}
+
+	modal := discordgo.InteractionResponseData{
+		CustomID: modalIDRotationSchedule,
+		Title:    "Rotation Schedule",
+		Components: []discordgo.MessageComponent{
+			discordgo.ActionsRow{Components: []discordgo.MessageComponent{
+				discordgo.TextInput{CustomID: "interval_input", Label: "Interval (e.g. 15m, 1h)", Style: discordgo.TextInputShort, Placeholder: presence.DefaultRotationInterval.String(), Required: false, MaxLength: 20, Value: interval},
+			}},
+			discordgo.ActionsRow{Components: []discordgo.MessageComponent{
+				discordgo.TextInput{CustomID: "cron_input", Label: "Cron Schedule (overrides the interval)", Style: discordgo.TextInputShort, Placeholder: "*/30 * * * *", Required: false, MaxLength: 100, Value: rotation.Cron},
+			}},
+		},
+	}
+	err = s.InteractionRespond(i.Interaction, &discordgo.InteractionResponse{Type: discordgo.InteractionResponseModal, Data: &modal})
+	if err != nil {
+		logging.FromContext(ctx).Error("opening rotation schedule modal failed", "error", err)
+	}
+}
+
+// Turn on/off button -> toggles the rotation
+func (h *Handler) handleRotationToggle(ctx context.Context, s *discordgo.Session, i *discordgo.InteractionCreate) {
+	h.updateRotation(ctx, s, i, func(rotation *db.StatusRotation) (string, bool) {
+		if !rotation.Enabled && len(rotation.Entries) == 0 {
+			return "Add at least one entry before turning the rotation on.\n\n", false
+		}
+		rotation.Enabled = !rotation.Enabled
+		if rotation.Enabled {
+			return "Status rotation turned on.\n\n", true
+		}
+		return "Status rotation turned off; the saved status is back.\n\n", true
+	})
+}
+
+// Remove select menu -> drops the chosen entry
+func (h *Handler) handleRotationRemove(ctx context.Context, s *discordgo.Session, i *discordgo.InteractionCreate) {
+	value := firstValue(i)
+	h.updateRotation(ctx, s, i, func(rotation *db.StatusRotation) (string, bool) {
+		idx, err := strconv.Atoi(value)
+		if err != nil || idx < 0 || idx >= len(rotation.Entries) {
+			return "That entry is already gone.\n\n", false
+		}
+		rotation.Entries = append(rotation.Entries[:idx], rotation.Entries[idx+1:]...)
+		if len(rotation.Entries) == 0 {
+			rotation.Enabled = false
+		}
+		return fmt.Sprintf("Removed entry %d.\n\n", idx+1), true
+	})
+}
+
+// Rotation entry modal -> appends the new entry. The status and activity type
+// are the ones selected in the entry menu the modal was opened from.
+func (h *Handler) handleRotationEntrySubmit(ctx context.Context, s *discordgo.Session, i *discordgo.InteractionCreate) {
+	data := i.ModalSubmitData()
+	value := func(row int) string {
+		return strings.TrimSpace(data.Components[row].(*discordgo.ActionsRow).Components[0].(*discordgo.TextInput).Value)
+	}
+	typeName := selectedOption(i.Message, selectIDRotationType)
+	activityType, ok := stringToActivityType(typeName)
+	entry := discordgo.UpdateStatusData{
+		Status: selectedOption(i.Message, selectIDRotationStatus),
+		Activities: []*discordgo.Activity{{
+			Type:    activityType,
+			Name:    value(0),
+			Details: value(1),
+			Assets:  discordgo.Assets{LargeImageID: value(2)},
+			URL:     value(3),
+		}},
+	}
+	problems := presence.Validate(entry)
+	if !ok {
+		problems = append([]string{fmt.Sprintf("Activity type %q isn't one of playing, streaming, listening, watching or competing.", typeName)}, problems...)
+	}
+	if len(problems) > 0 {
+		respondEphemeral(ctx, s, i, problemsMessage(problems))
+		return
+	}
+	h.updateRotation(ctx, s, i, func(rotation *db.StatusRotation) (string, bool) {
+		if len(rotation.Entries) >= db.MaxRotationEntries {
+			return fmt.Sprintf("The rotation is full (%d entries). Remove one first.\n\n", db.MaxRotationEntries), false
+		}
+		rotation.Entries = append(rotation.Entries, entry)
+		return "Entry added.\n\n", true
+	})
+}
+
+// Rotation schedule modal -> saves the interval or cron schedule
+func (h *Handler) handleRotationScheduleSubmit(ctx context.Context, s *discordgo.Session, i *discordgo.InteractionCreate) {
+	data := i.ModalSubmitData()
+	intervalText := strings.TrimSpace(data.Components[0].(*discordgo.ActionsRow).Components[0].(*discordgo.TextInput).Value)
+	cron := strings.Join(strings.Fields(data.Components[1].(*discordgo.ActionsRow).Components[0].(*discordgo.TextInput).Value), " ")
+
+	minutes := 0
+	if cron != "" {
+		schedule, err := presence.ParseSchedule(cron)
+		if err != nil {
+			respondEphemeral(ctx, s, i, "That cron schedule isn't valid: "+err.Error())
+			return
+		}
+		if schedule.Next(time.Now()).IsZero() {
+			respondEphemeral(ctx, s, i, "That cron schedule never fires.")
+			return
+		}
+	} else if intervalText != "" {
+		interval, err := time.ParseDuration(intervalText)
+		if err != nil || interval < time.Minute {
+			respondEphemeral(ctx, s, i, "The interval must be a duration of at least 1m, like 15m or 2h.")
+			return
+		}
+		minutes = int(interval / time.Minute)
+	}
+
+	h.updateRotation(ctx, s, i, func(rotation *db.StatusRotation) (string, bool) {
+		rotation.IntervalMinutes = minutes
+		rotation.Cron = cron
+		return "Schedule saved.\n\n", true
+	})
+}
+
+// updateRotation applies change to the saved rotation and redraws the
+// rotation menu with the notice change returns. The rotation is only saved,
+// and the presence refreshed, when change reports it modified something.
+func (h *Handler) updateRotation(ctx context.Context, s *discordgo.Session, i *discordgo.InteractionCreate, change func(*db.StatusRotation) (string, bool)) {
+	rotation, err := h.store.LoadStatusRotation()
+	if err != nil {
+		logging.FromContext(ctx).Error("loading status rotation failed", "error", err)
+		respondEphemeral(ctx, s, i, "Couldn't load the status rotation, try again later.")
+		return
+	}
+	notice, changed := change(&rotation)
+	if changed {
+		if err := h.store.SaveStatusRotation(rotation); err != nil {
+			logging.FromContext(ctx).Error("saving status rotation failed", "error", err)
+			respondEphemeral(ctx, s, i, "Couldn't save the status rotation, try again later.")
+			return
+		}
+		h.presence.Refresh()
+		logging.FromContext(ctx).Info("status rotation updated", "enabled", rotation.Enabled, "entries", len(rotation.Entries))
+	}
+
+	err = s.InteractionRespond(i.Interaction, &discordgo.InteractionResponse{
+		Type: discordgo.InteractionResponseUpdateMessage,
+		Data: rotationResponse(rotation, notice),
+	})
+	if err != nil {
+		logging.FromContext(ctx).Error("updating rotation menu failed", "error", err)
+	}
+}
+
+// rotationResponse renders the rotation menu: its entries, schedule and controls.
+func rotationResponse(rotation db.StatusRotation, prefix string) *discordgo.InteractionResponseData {
+	state := "off"
+	if presence.Rotating(rotation) {
+		state = "on"
+	}
+	schedule := fmt.Sprintf("every %s", presence.DefaultRotationInterval)
+	if rotation.Cron != "" {
+		schedule = fmt.Sprintf("cron `%s` (bot's local time)", rotation.Cron)
+	} else if rotation.IntervalMinutes > 0 {
+		schedule = fmt.Sprintf("every %s", time.Duration(rotation.IntervalMinutes)*time.Minute)
+	}
+
+	var sb strings.Builder
+	sb.WriteString(prefix)
+	fmt.Fprintf(&sb, "**Status Rotation** is **%s**, changing %s.\n", state, schedule)
+	sb.WriteString("Entry text can use `{guild_count}` and `{uptime}`. AI status, when on, takes priority.\n\n")
+	if len(rotation.Entries) == 0 {
+		sb.WriteString("No entries yet. Add the current status or a new entry.")
+	}
+	options := make([]discordgo.SelectMenuOption, 0, len(rotation.Entries))
+	for n, entry := range rotation.Entries {
+		line := fmt.Sprintf("%d. %s", n+1, describePresence(entry))
+		sb.WriteString(line + "\n")
+		options = append(options, discordgo.SelectMenuOption{
+			Label: truncate(plainText(line), 100),
+			Value: strconv.Itoa(n),
+		})
+	}
+
+	toggle := discordgo.Button{Label: "Turn On", Style: discordgo.SuccessButton, CustomID: buttonIDRotationToggle}
+	if rotation.Enabled {
+		toggle.Label, toggle.Style = "Turn Off", discordgo.DangerButton
+	}
+	full := len(rotation.Entries) >= db.MaxRotationEntries
+	components := []discordgo.MessageComponent{
+		discordgo.ActionsRow{Components: []discordgo.MessageComponent{
+			discordgo.Button{Label: "Add Current Status", Style: discordgo.PrimaryButton, CustomID: buttonIDRotationAddCurrent, Disabled: full},
+			discordgo.Button{Label: "Add Entry", Style: discordgo.PrimaryButton, CustomID: buttonIDRotationAdd, Disabled: full},
+			discordgo.Button{Label: "Schedule", Style: discordgo.SecondaryButton, CustomID: buttonIDRotationSchedule},
+			toggle,
+			discordgo.Button{Label: "Back", Style: discordgo.SecondaryButton, CustomID: buttonIDConfigBack},
+		}},
+	}
+	if len(options) > 0 {
+		components = append(components, discordgo.ActionsRow{Components: []discordgo.MessageComponent{
+			discordgo.SelectMenu{CustomID: selectIDRotationRemove, Placeholder: "Remove an entry", Options: options},
+		}})
+	}
+	return &discordgo.InteractionResponseData{Content: truncate(sb.String(), 2000), Components: components}
+}
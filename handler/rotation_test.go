@@ -0,0 +1,49 @@
+package handler
+
+import (
+	"encoding/json"
+	"testing"
+
+	"github.com/bwmarrin/discordgo"
+)
+
+// TestRotationEntrySelections renders the entry menu and reads the choices
+// back from the message Discord sends with the next interaction.
+func TestRotationEntrySelections(t *testing.T) {
+	tests := []struct {
+		name               string
+		status, activity   string
+		wantStatus, wantTy string
+	}{
+		{"defaults", "online", "playing", "online", "playing"},
+		{"changed", "dnd", "streaming", "dnd", "streaming"},
+		{"unknown values select nothing", "busy", "dancing", "", ""},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			raw, err := json.Marshal(rotationEntryResponse(tt.status, tt.activity))
+			if err != nil {
+				t.Fatalf("marshalling menu: %v", err)
+			}
+			var msg discordgo.Message
+			if err := json.Unmarshal(raw, &msg); err != nil {
+				t.Fatalf("unmarshalling menu: %v", err)
+			}
+			if got := selectedOption(&msg, selectIDRotationStatus); got != tt.wantStatus {
+				t.Errorf("selected status = %q, want %q", got, tt.wantStatus)
+			}
+			if got := selectedOption(&msg, selectIDRotationType); got != tt.wantTy {
+				t.Errorf("selected activity type = %q, want %q", got, tt.wantTy)
+			}
+		})
+	}
+
+	if got := selectedOption(nil, selectIDRotationStatus); got != "" {
+		t.Errorf("selectedOption without a message = %q, want none", got)
+	}
+	for _, option := range onlineStatuses {
+		if option.Default {
+			t.Fatal("withDefault modified the shared onlineStatuses options")
+		}
+	}
+}
@@ -0,0 +1,176 @@
+package handler
+
+import (
+	"context"
+	"fmt"
+	"regexp"
+	"strings"
+
+	"discord-ai-bot/logging"
+	"discord-ai-bot/presence"
+
+	"github.com/bwmarrin/discordgo"
+)
+
+// configMenuContent is the text of the /config menu.
+const configMenuContent = "**RPC Configuration Menu**\n\nPick a status or activity type below, or submit a modal. Every change is previewed before it updates your RPC status."
+
+// activityVerbs are how Discord words each activity type in a profile.
+var activityVerbs = map[discordgo.ActivityType]string{
+	discordgo.ActivityTypeGame:      "Playing",
+	discordgo.ActivityTypeStreaming: "Streaming",
+	discordgo.ActivityTypeListening: "Listening to",
+	discordgo.ActivityTypeWatching:  "Watching",
+	discordgo.ActivityTypeCompeting: "Competing in",
+}
+
+// describePresence renders a presence on one line for the config menus,
+// e.g. "`idle` · 💬 🎉 Event tonight · Playing **Minecraft** — Level 1-1".
+func describePresence(status discordgo.UpdateStatusData) string {
+	parts := []string{"`" + status.Status + "`"}
+	for _, act := range status.Activities {
+		if act.Type == discordgo.ActivityTypeCustom {
+			parts = append(parts, strings.TrimSpace("💬 "+emojiText(act.Emoji)+" "+act.State))
+			continue
+		}
+		verb, ok := activityVerbs[act.Type]
+		if !ok {
+			continue
+		}
+		line := verb + " **" + act.Name + "**"
+		if act.Details != "" {
+			line += " — " + act.Details
+		}
+		parts = append(parts, line)
+	}
+	return strings.Join(parts, " · ")
+}
+
+// plainText strips the Markdown describePresence adds, for select menu labels.
+func plainText(s string) string {
+	return strings.NewReplacer("**", "", "`", "").Replace(s)
+}
+
+// onlineStatuses are the statuses a bot can set, in menu order.
+var onlineStatuses = []discordgo.SelectMenuOption{
+	{Label: "Online", Value: "online", Emoji: &discordgo.ComponentEmoji{Name: "🟢"}},
+	{Label: "Idle", Value: "idle", Emoji: &discordgo.ComponentEmoji{Name: "🌙"}},
+	{Label: "Do Not Disturb", Value: "dnd", Emoji: &discordgo.ComponentEmoji{Name: "⛔"}},
+	{Label: "Invisible", Value: "invisible", Emoji: &discordgo.ComponentEmoji{Name: "⚫"}},
+}
+
+// activityTypeOptions are the activity types the config menu offers.
+var activityTypeOptions = []discordgo.SelectMenuOption{
+	{Label: "Playing", Value: "playing"},
+	{Label: "Streaming", Value: "streaming", Description: "Needs a Twitch or YouTube URL"},
+	{Label: "Listening to", Value: "listening"},
+	{Label: "Watching", Value: "watching"},
+	{Label: "Competing in", Value: "competing"},
+}
+
+// customActivity returns the presence's custom status, if it has one.
+func customActivity(status *discordgo.UpdateStatusData) *discordgo.Activity {
+	if status == nil {
+		return nil
+	}
+	for _, act := range status.Activities {
+		if act.Type == discordgo.ActivityTypeCustom {
+			return act
+		}
+	}
+	return nil
+}
+
+// emojiText renders an activity emoji as it's typed in chat.
+func emojiText(e discordgo.Emoji) string {
+	if e.ID == "" && e.Name == "" {
+		return ""
+	}
+	return e.MessageFormat()
+}
+
+// customEmojiPattern matches a server emoji as typed in chat, e.g. <:party:1234>.
+var customEmojiPattern = regexp.MustCompile(`^<(a?):([A-Za-z0-9_]{2,32}):([0-9]{1,20})>$`)
+
+// parseEmoji reads the emoji field of the custom status modal: a Unicode
+// emoji or a server emoji. Empty text is no emoji.
+func parseEmoji(text string) (discordgo.Emoji, bool) {
+	text = strings.TrimSpace(text)
+	if text == "" {
+		return discordgo.Emoji{}, true
+	}
+	if m := customEmojiPattern.FindStringSubmatch(text); m != nil {
+		return discordgo.Emoji{Animated: m[1] == "a", Name: m[2], ID: m[3]}, true
+	}
+	// Unicode emoji are a few code points, none of them ASCII
+	if len([]rune(text)) > 8 {
+		return discordgo.Emoji{}, false
+	}
+	for _, r := range text {
+		if r < 128 {
+			return discordgo.Emoji{}, false
+		}
+	}
+	return discordgo.Emoji{Name: text}, true
+}
+
+// mainActivity returns the activity the config menu edits: the first one that
+// isn't a custom status.
+func mainActivity(status *discordgo.UpdateStatusData) *discordgo.Activity {
+	if status == nil {
+		return nil
+	}
+	for _, act := range status.Activities {
+		if act.Type != discordgo.ActivityTypeCustom {
+			return act
+		}
+	}
+	return nil
+}
+
+// problemsMessage is the ephemeral reply listing why nothing was saved.
+func problemsMessage(problems []string) string {
+	return truncate("Nothing was saved:\n- "+strings.Join(problems, "\n- "), 2000)
+}
+
+// Online status select menu -> saves and applies the chosen status
+func (h *Handler) handleOnlineStatusSelect(ctx context.Context, s *discordgo.Session, i *discordgo.InteractionCreate) {
+	value := firstValue(i)
+	h.updateStatus(ctx, s, i, "Status", func(status *discordgo.UpdateStatusData) {
+		status.Status = value
+	})
+}
+
+// Activity type select menu -> saves and applies the chosen activity type
+func (h *Handler) handleActivityTypeSelect(ctx context.Context, s *discordgo.Session, i *discordgo.InteractionCreate) {
+	activityType, ok := stringToActivityType(firstValue(i))
+	if !ok {
+		respondEphemeral(ctx, s, i, problemsMessage([]string{fmt.Sprintf("Activity type %q isn't supported.", firstValue(i))}))
+		return
+	}
+	h.updateStatus(ctx, s, i, "Activity type", func(status *discordgo.UpdateStatusData) {
+		editableActivity(status).Type = activityType
+	})
+}
+
+// saveAndApplyStatus saves a validated status, applies the presence and
+// returns the /config menu text describing the result. what names the
+// settings that changed, e.g. "General settings".
+func (h *Handler) saveAndApplyStatus(ctx context.Context, status discordgo.UpdateStatusData, what string) string {
+	if err := h.store.SaveStatus(status); err != nil {
+		logging.FromContext(ctx).Error("saving status failed", "settings", what, "error", err)
+		return "**Saving FAILED!** Check bot logs for details."
+	}
+
+	// --- AUTOMATIC STATUS UPDATE ---
+	source, err := h.presence.Apply()
+	switch {
+	case err != nil:
+		logging.FromContext(ctx).Error("updating status failed", "settings", what, "error", err)
+		return what + " saved, but **Status Update FAILED!** Check bot logs for details."
+	case source != presence.SourceStatus:
+		return what + " saved. Status rotation or AI status is on, so it shows once that's turned off."
+	default:
+		return what + " saved and **Status Updated Successfully!**"
+	}
+}
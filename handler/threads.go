@@ -0,0 +1,192 @@
+package handler
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"strings"
+	"time"
+
+	"discord-ai-bot/db"
+	"discord-ai-bot/logging"
+	"discord-ai-bot/metrics"
+
+	"github.com/bwmarrin/discordgo"
+)
+
+// maxThreadName is Discord's limit on thread names.
+const maxThreadName = 100
+
+// conversationThread reports whether channelID is a thread the bot started
+// for a conversation. The Guilds intent keeps threads in the state cache;
+// the API is only asked about channels the cache doesn't know yet.
+func conversationThread(s *discordgo.Session, channelID string) bool {
+	ch, err := s.State.Channel(channelID)
+	if err != nil {
+		if ch, err = s.Channel(channelID); err != nil {
+			return false
+		}
+	}
+	return ch.IsThread() && ch.OwnerID == s.State.User.ID
+}
+
+// historyScope returns the history a message in channelID belongs to: the
+// thread's own history inside conversation threads, the global one elsewhere.
+func historyScope(s *discordgo.Session, channelID string) string {
+	if conversationThread(s, channelID) {
+		return db.ThreadScope(channelID)
+	}
+	return db.GlobalScope
+}
+
+// threadModeEnabled reports whether pings in the channel start a conversation thread.
+func (h *Handler) threadModeEnabled(ctx context.Context, guildID, channelID string) bool {
+	if guildID == "" {
+		return false
+	}
+	settings, err := h.store.LoadGuildSettings(guildID)
+	if err != nil {
+		logging.FromContext(ctx).Warn("loading guild settings failed, answering in the channel", "error", err)
+		return false
+	}
+	for _, id := range settings.ThreadChannels {
+		if id == channelID {
+			return true
+		}
+	}
+	return false
+}
+
+// startThread opens a public thread on a message, named after the prompt.
+// The auto-archive duration is left to the channel's default.
+func startThread(s *discordgo.Session, channelID, messageID, prompt string) (*discordgo.Channel, error) {
+	name := truncate(strings.Join(strings.Fields(prompt), " "), maxThreadName)
+	if name == "" {
+		name = "Conversation"
+	}
+	thread, err := s.MessageThreadStartComplex(channelID, messageID, &discordgo.ThreadStart{Name: name})
+	if err != nil {
+		return nil, err
+	}
+	// Follow-ups can arrive before the THREAD_CREATE event
+	s.State.ChannelAdd(thread)
+	return thread, nil
+}
+
+// /chat -> starts a conversation thread from the prompt and answers in it
+func (h *Handler) handleChatCommand(ctx context.Context, s *discordgo.Session, i *discordgo.InteractionCreate) {
+	user := interactionUser(i)
+	req := chatRequest{GuildID: i.GuildID, ChannelID: i.ChannelID, Author: user, Typing: true}
+	for _, opt := range i.ApplicationCommandData().Options {
+		if opt.Name == "prompt" {
+			req.Prompt = strings.TrimSpace(opt.StringValue())
+		}
+	}
+	if ch, err := s.State.Channel(i.ChannelID); err == nil && ch.IsThread() {
+		respondEphemeral(ctx, s, i, "You're already in a thread. Ping me or use /ask here instead.")
+		return
+	}
+	// The prompt is posted publicly as the thread's first message, so check it first
+	if !h.moderateInput(ctx, s, req, req.Prompt) {
+		respondEphemeral(ctx, s, i, "I can't help with that message.")
+		return
+	}
+	req.Premoderated = true
+
+	err := s.InteractionRespond(i.Interaction, &discordgo.InteractionResponse{
+		Type: discordgo.InteractionResponseChannelMessageWithSource,
+		Data: &discordgo.InteractionResponseData{
+			Content:         truncate(fmt.Sprintf("<@%s> asked: %s", user.ID, sanitizeMentions(s, i.GuildID, req.Prompt)), 2000),
+			AllowedMentions: &discordgo.MessageAllowedMentions{},
+		},
+	})
+	if err != nil {
+		logging.FromContext(ctx).Error("responding to /chat command failed", "error", err)
+		return
+	}
+	starter, err := s.InteractionResponse(i.Interaction)
+	if err != nil {
+		logging.FromContext(ctx).Error("fetching /chat response failed", "error", err)
+		return
+	}
+	thread, err := startThread(s, i.ChannelID, starter.ID, req.Prompt)
+	if err != nil {
+		logging.FromContext(ctx).Error("starting conversation thread failed", "error", err)
+		followupEphemeral(ctx, s, i, "Couldn't start a thread here. Check that I can create public threads in this channel.")
+		return
+	}
+	logging.FromContext(ctx).Info("conversation thread started", "thread_id", thread.ID)
+	req.ChannelID = thread.ID
+	req.Scope = db.ThreadScope(thread.ID)
+
+	handledAt := time.Now()
+	res := h.chat(ctx, s, req)
+	defer func() {
+		metrics.Messages.Inc(res.Outcome)
+		logging.FromContext(ctx).Info("chat handled", "outcome", res.Outcome, "latency_ms", time.Since(handledAt).Milliseconds())
+	}()
+	if msg, err := sendMessage(ctx, s, thread.ID, i.GuildID, res.Content, res.components()); err == nil {
+		h.saveExchange(ctx, req.Scope, res.Exchange, "", msg.ID)
+	}
+}
+
+// /threads -> turns conversation threads on or off for a channel
+func (h *Handler) handleThreadsCommand(ctx context.Context, s *discordgo.Session, i *discordgo.InteractionCreate) {
+	if i.GuildID == "" {
+		respondEphemeral(ctx, s, i, "Threads are only available in servers.")
+		return
+	}
+	channelID := i.ChannelID
+	enabled := false
+	for _, opt := range i.ApplicationCommandData().Options {
+		switch opt.Name {
+		case "enabled":
+			enabled = opt.BoolValue()
+		case "channel":
+			if id, ok := opt.Value.(string); ok {
+				channelID = id
+			}
+		}
+	}
+
+	settings, err := h.store.LoadGuildSettings(i.GuildID)
+	if err != nil {
+		logging.FromContext(ctx).Error("loading guild settings failed", "error", err)
+		respondEphemeral(ctx, s, i, "Couldn't load the thread settings, try again later.")
+		return
+	}
+	kept := settings.ThreadChannels[:0]
+	for _, id := range settings.ThreadChannels {
+		if id != channelID {
+			kept = append(kept, id)
+		}
+	}
+	if enabled {
+		kept = append(kept, channelID)
+	}
+	settings.ThreadChannels = kept
+	if err := h.store.SaveGuildSettings(i.GuildID, settings); err != nil {
+		logging.FromContext(ctx).Error("saving guild settings failed", "error", err)
+		respondEphemeral(ctx, s, i, "Couldn't save the thread settings, try again later.")
+		return
+	}
+
+	state := "off"
+	if enabled {
+		state = "on"
+	}
+	channels := "none"
+	if len(settings.ThreadChannels) > 0 {
+		channels = "<#" + strings.Join(settings.ThreadChannels, ">, <#") + ">"
+	}
+	respondEphemeral(ctx, s, i, fmt.Sprintf("Conversation threads are now **%s** in <#%s>.\n"+
+		"Pinging me there opens a thread where I answer every message without pings.\nThread channels: %s",
+		state, channelID, channels))
+}
+
+// ThreadDelete drops the history of a deleted conversation thread.
+func (h *Handler) ThreadDelete(s *discordgo.Session, t *discordgo.ThreadDelete) {
+	if err := h.store.DeleteHistory(db.ThreadScope(t.ID)); err != nil {
+		slog.Error("dropping deleted thread's history failed", "thread_id", t.ID, "error", err)
+	}
+}
@@ -0,0 +1,189 @@
+package handler
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"time"
+
+	"discord-ai-bot/db"
+	"discord-ai-bot/logging"
+
+	"github.com/bwmarrin/discordgo"
+)
+
+// timeNow is the clock quotas are checked against; tests move it to another
+// day or month.
+var timeNow = time.Now
+
+// guildQuotas returns the daily and monthly token quotas for a guild, where 0
+// means unlimited. Guild overrides win over QUOTA_DAILY_TOKENS and QUOTA_MONTHLY_TOKENS.
+func (h *Handler) guildQuotas(guildID string) (daily, monthly int64, err error) {
+	daily = int64(envInt("QUOTA_DAILY_TOKENS", 0))
+	monthly = int64(envInt("QUOTA_MONTHLY_TOKENS", 0))
+	if guildID != "" {
+		settings, err := h.store.LoadGuildSettings(guildID)
+		if err != nil {
+			return daily, monthly, err
+		}
+		daily = applyQuotaOverride(daily, settings.DailyTokenQuota)
+		monthly = applyQuotaOverride(monthly, settings.MonthlyTokenQuota)
+	}
+	return daily, monthly, nil
+}
+
+func applyQuotaOverride(def, override int64) int64 {
+	switch {
+	case override < 0:
+		return 0
+	case override > 0:
+		return override
+	default:
+		return def
+	}
+}
+
+// quotaExceeded reports whether the guild is over its token quota, with a
+// polite explanation of when it resets. Quotas fail open when usage can't be read.
+func (h *Handler) quotaExceeded(ctx context.Context, guildID string) (string, bool) {
+	logger := logging.FromContext(ctx)
+	daily, monthly, err := h.guildQuotas(guildID)
+	if err != nil {
+		logger.Warn("loading quota overrides failed, using defaults", "error", err)
+	}
+	now := timeNow().UTC()
+
+	if monthly > 0 {
+		usage, err := h.store.GuildUsage(guildID, now.Format("2006-01"))
+		if err != nil {
+			logger.Warn("reading monthly usage failed, skipping quota check", "error", err)
+		} else if usage.Total() >= monthly {
+			reset := time.Date(now.Year(), now.Month()+1, 1, 0, 0, 0, 0, time.UTC)
+			return fmt.Sprintf("Sorry, this server has used up its AI token quota for the month. It resets <t:%d:R>.", reset.Unix()), true
+		}
+	}
+	if daily > 0 {
+		usage, err := h.store.GuildUsage(guildID, now.Format("2006-01-02"))
+		if err != nil {
+			logger.Warn("reading daily usage failed, skipping quota check", "error", err)
+		} else if usage.Total() >= daily {
+			reset := time.Date(now.Year(), now.Month(), now.Day()+1, 0, 0, 0, 0, time.UTC)
+			return fmt.Sprintf("Sorry, this server has used up its AI token quota for today. It resets <t:%d:R>.", reset.Unix()), true
+		}
+	}
+	return "", false
+}
+
+// /usage -> shows the guild's and the invoking user's token consumption
+func (h *Handler) handleUsageCommand(ctx context.Context, s *discordgo.Session, i *discordgo.InteractionCreate) {
+	user := interactionUser(i)
+	now := timeNow().UTC()
+	today, month := now.Format("2006-01-02"), now.Format("2006-01")
+
+	daily, monthly, err := h.guildQuotas(i.GuildID)
+	if err != nil {
+		logging.FromContext(ctx).Warn("loading quota overrides failed, showing defaults", "error", err)
+	}
+	usage, err := h.usageSummary(i.GuildID, user.ID, today, month)
+	if err != nil {
+		logging.FromContext(ctx).Error("reading token usage failed", "error", err)
+		respondEphemeral(ctx, s, i, "Couldn't read token usage, try again later.")
+		return
+	}
+
+	var sb strings.Builder
+	scope := "this server"
+	if i.GuildID == "" {
+		scope = "direct messages"
+	}
+	fmt.Fprintf(&sb, "**Token usage for %s**\n", scope)
+	fmt.Fprintf(&sb, "Today: %s\n", formatUsage(usage.guildToday, daily))
+	fmt.Fprintf(&sb, "This month: %s\n", formatUsage(usage.guildMonth, monthly))
+
+	fmt.Fprintf(&sb, "\n**Your usage**\n")
+	fmt.Fprintf(&sb, "Today: %s\n", formatUsage(usage.userToday, 0))
+	fmt.Fprintf(&sb, "This month: %s\n", formatUsage(usage.userMonth, 0))
+
+	if len(usage.top) > 0 {
+		sb.WriteString("\n**Top users this month**\n")
+		for n, u := range usage.top {
+			fmt.Fprintf(&sb, "%d. <@%s>: %d tokens\n", n+1, u.UserID, u.Total())
+		}
+	}
+	respondEphemeral(ctx, s, i, sb.String())
+}
+
+// usageSummary is everything /usage shows.
+type usageSummary struct {
+	guildToday, guildMonth, userToday, userMonth db.UsageRecord
+	top                                          []db.UserUsage
+}
+
+func (h *Handler) usageSummary(guildID, userID, today, month string) (usageSummary, error) {
+	var u usageSummary
+	var err error
+	if u.guildToday, err = h.store.GuildUsage(guildID, today); err != nil {
+		return u, err
+	}
+	if u.guildMonth, err = h.store.GuildUsage(guildID, month); err != nil {
+		return u, err
+	}
+	if u.userToday, err = h.store.UserUsageFor(guildID, userID, today); err != nil {
+		return u, err
+	}
+	if u.userMonth, err = h.store.UserUsageFor(guildID, userID, month); err != nil {
+		return u, err
+	}
+	if guildID != "" {
+		u.top, err = h.store.TopUsers(guildID, month, 5)
+	}
+	return u, err
+}
+
+// formatUsage renders a usage record, with the quota when one applies.
+func formatUsage(u db.UsageRecord, quota int64) string {
+	text := fmt.Sprintf("%d tokens (%d prompt, %d completion) over %d requests", u.Total(), u.PromptTokens, u.CompletionTokens, u.Requests)
+	if quota > 0 {
+		text += fmt.Sprintf(" — %d%% of %d quota", u.Total()*100/quota, quota)
+	}
+	return text
+}
+
+// /quota -> sets the guild's daily and monthly token quotas
+func (h *Handler) handleQuotaCommand(ctx context.Context, s *discordgo.Session, i *discordgo.InteractionCreate) {
+	if i.GuildID == "" {
+		respondEphemeral(ctx, s, i, "Quotas are only available in servers.")
+		return
+	}
+
+	settings, err := h.store.LoadGuildSettings(i.GuildID)
+	if err != nil {
+		logging.FromContext(ctx).Error("loading guild settings failed", "error", err)
+		respondEphemeral(ctx, s, i, "Couldn't load the quota settings, try again later.")
+		return
+	}
+	for _, opt := range i.ApplicationCommandData().Options {
+		switch opt.Name {
+		case "daily":
+			settings.DailyTokenQuota = opt.IntValue()
+		case "monthly":
+			settings.MonthlyTokenQuota = opt.IntValue()
+		}
+	}
+	if err := h.store.SaveGuildSettings(i.GuildID, settings); err != nil {
+		logging.FromContext(ctx).Error("saving guild settings failed", "error", err)
+		respondEphemeral(ctx, s, i, "Couldn't save the quotas, try again later.")
+		return
+	}
+
+	daily, monthly, _ := h.guildQuotas(i.GuildID)
+	respondEphemeral(ctx, s, i, fmt.Sprintf("**Token quotas updated**\nDaily: %s\nMonthly: %s",
+		formatQuota(daily), formatQuota(monthly)))
+}
+
+func formatQuota(q int64) string {
+	if q <= 0 {
+		return "unlimited"
+	}
+	return fmt.Sprintf("%d tokens", q)
+}
@@ -0,0 +1,83 @@
+package handler
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"testing"
+	"time"
+
+	"discord-ai-bot/db"
+)
+
+// useClock moves the quota clock to at.
+func useClock(t *testing.T, at time.Time) {
+	t.Helper()
+	t.Cleanup(func() { timeNow = time.Now })
+	timeNow = func() time.Time { return at }
+}
+
+func TestQuotaExceeded(t *testing.T) {
+	// 150 tokens used today in guild g
+	store := db.NewMemoryStore()
+	if err := store.RecordUsage("g", "1", 100, 50); err != nil {
+		t.Fatalf("RecordUsage: %v", err)
+	}
+	h := &Handler{store: store}
+	now := time.Now().UTC()
+	tomorrow := time.Date(now.Year(), now.Month(), now.Day()+1, 0, 0, 0, 0, time.UTC)
+	nextMonth := time.Date(now.Year(), now.Month()+1, 1, 0, 0, 0, 0, time.UTC)
+
+	tests := []struct {
+		name    string
+		daily   string // QUOTA_DAILY_TOKENS
+		monthly string // QUOTA_MONTHLY_TOKENS
+		guild   db.GuildSettings
+		at      time.Time // zero for now
+		want    string    // "" when the quota isn't exceeded
+	}{
+		{"no quotas", "", "", db.GuildSettings{}, time.Time{}, ""},
+		{"under the daily quota", "151", "", db.GuildSettings{}, time.Time{}, ""},
+		{"at the daily quota", "150", "", db.GuildSettings{}, time.Time{}, fmt.Sprintf("for today. It resets <t:%d:R>", tomorrow.Unix())},
+		{"over the monthly quota", "", "100", db.GuildSettings{}, time.Time{}, fmt.Sprintf("for the month. It resets <t:%d:R>", nextMonth.Unix())},
+		{"monthly is checked first", "100", "100", db.GuildSettings{}, time.Time{}, "for the month"},
+		{"-1 in the environment is unlimited", "-1", "-1", db.GuildSettings{}, time.Time{}, ""},
+		{"guild override raises the quota", "100", "", db.GuildSettings{DailyTokenQuota: 1000}, time.Time{}, ""},
+		{"guild override lowers the quota", "1000", "", db.GuildSettings{DailyTokenQuota: 10}, time.Time{}, "for today"},
+		{"guild override of -1 is unlimited", "100", "100", db.GuildSettings{DailyTokenQuota: -1, MonthlyTokenQuota: -1}, time.Time{}, ""},
+		{"daily quota rolls over at midnight UTC", "100", "", db.GuildSettings{}, tomorrow, ""},
+		{"monthly quota rolls over on the 1st", "", "100", db.GuildSettings{}, nextMonth, ""},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Setenv("QUOTA_DAILY_TOKENS", tt.daily)
+			t.Setenv("QUOTA_MONTHLY_TOKENS", tt.monthly)
+			if err := store.SaveGuildSettings("g", tt.guild); err != nil {
+				t.Fatalf("SaveGuildSettings: %v", err)
+			}
+			if !tt.at.IsZero() {
+				useClock(t, tt.at)
+			}
+
+			notice, exceeded := h.quotaExceeded(context.Background(), "g")
+			if exceeded != (tt.want != "") || !strings.Contains(notice, tt.want) {
+				t.Errorf("quotaExceeded = %q, %v; want exceeded %v with %q", notice, exceeded, tt.want != "", tt.want)
+			}
+		})
+	}
+}
+
+func TestQuotaIsPerGuild(t *testing.T) {
+	store := db.NewMemoryStore()
+	if err := store.RecordUsage("g", "1", 100, 50); err != nil {
+		t.Fatalf("RecordUsage: %v", err)
+	}
+	t.Setenv("QUOTA_DAILY_TOKENS", "100")
+	h := &Handler{store: store}
+	if _, exceeded := h.quotaExceeded(context.Background(), "other"); exceeded {
+		t.Error("another guild's usage counted against this guild's quota")
+	}
+	if _, exceeded := h.quotaExceeded(context.Background(), ""); exceeded {
+		t.Error("a guild's usage counted against the DM quota")
+	}
+}
@@ -0,0 +1,144 @@
+// Package logging configures the process-wide slog logger and carries
+// per-request loggers with correlation IDs through contexts.
+package logging
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"log/slog"
+	"os"
+	"regexp"
+	"strconv"
+	"strings"
+)
+
+// Setup installs a slog logger as the default for both slog and the standard
+// log package, configured from the environment:
+//
+//	LOG_LEVEL           debug, info (default), warn or error
+//	LOG_FORMAT          json (default) or text
+//	LOG_REDACT_CONTENT  true to replace message content fields with their length
+//
+// Secrets (the bot token, API keys, bearer tokens) are always redacted.
+func Setup() {
+	slog.SetDefault(slog.New(NewHandler(os.Stderr)))
+}
+
+// NewHandler builds the redacting handler Setup installs, writing to w.
+func NewHandler(w io.Writer) slog.Handler {
+	level := slog.LevelInfo
+	if err := level.UnmarshalText([]byte(os.Getenv("LOG_LEVEL"))); err != nil {
+		level = slog.LevelInfo
+	}
+
+	redactContent, _ := strconv.ParseBool(os.Getenv("LOG_REDACT_CONTENT"))
+	r := newRedactor(
+		[]string{os.Getenv("DISCORD_BOT_TOKEN"), os.Getenv("CEREBRAS_API_KEY"), os.Getenv("EMBEDDINGS_API_KEY")},
+		redactContent,
+	)
+	opts := &slog.HandlerOptions{Level: level, ReplaceAttr: r.replaceAttr}
+
+	if strings.EqualFold(os.Getenv("LOG_FORMAT"), "text") {
+		return slog.NewTextHandler(w, opts)
+	}
+	return slog.NewJSONHandler(w, opts)
+}
+
+// --- REDACTION ---
+
+const redacted = "[REDACTED]"
+
+// Attribute keys whose values are always secret.
+var secretKeys = map[string]bool{
+	"token": true, "api_key": true, "apikey": true, "authorization": true, "password": true, "secret": true,
+}
+
+// Attribute keys holding user or model text, hidden when LOG_REDACT_CONTENT is on.
+var contentKeys = map[string]bool{
+	"content": true, "prompt": true, "reply": true, "text": true,
+}
+
+// Matches credentials embedded in free text, e.g. error bodies or URLs, and
+// anything shaped like a Discord token (three dot-separated base64 parts).
+var secretPattern = regexp.MustCompile(`(?i)(bearer|bot)\s+[a-z0-9._\-]{20,}|(api[_-]?key|token)=[^&\s"]+|[a-z0-9_\-]{23,}\.[a-z0-9_\-]{6}\.[a-z0-9_\-]{27,}`)
+
+type redactor struct {
+	secrets       []string
+	redactContent bool
+}
+
+func newRedactor(secrets []string, redactContent bool) *redactor {
+	r := &redactor{redactContent: redactContent}
+	for _, s := range secrets {
+		// Very short values would redact unrelated text.
+		if len(s) >= 8 {
+			r.secrets = append(r.secrets, s)
+		}
+	}
+	return r
+}
+
+func (r *redactor) replaceAttr(_ []string, a slog.Attr) slog.Attr {
+	key := strings.ToLower(a.Key)
+	switch {
+	case secretKeys[key]:
+		return slog.String(a.Key, redacted)
+	case r.redactContent && contentKeys[key]:
+		return slog.String(a.Key, fmt.Sprintf("[%d chars]", len([]rune(a.Value.String()))))
+	}
+
+	switch a.Value.Kind() {
+	case slog.KindString:
+		return slog.String(a.Key, r.scrub(a.Value.String()))
+	case slog.KindAny:
+		if err, ok := a.Value.Any().(error); ok {
+			return slog.String(a.Key, r.scrub(err.Error()))
+		}
+		// Other values (slices, headers, structs) are only flattened to text
+		// when they contain something to redact.
+		text := fmt.Sprint(a.Value.Any())
+		if scrubbed := r.scrub(text); scrubbed != text {
+			return slog.String(a.Key, scrubbed)
+		}
+	}
+	return a
+}
+
+// scrub removes known secret values and credential-looking substrings from s.
+func (r *redactor) scrub(s string) string {
+	for _, secret := range r.secrets {
+		s = strings.ReplaceAll(s, secret, redacted)
+	}
+	return secretPattern.ReplaceAllString(s, redacted)
+}
+
+// --- CORRELATION ---
+
+type loggerKey struct{}
+
+// NewID returns a short random correlation ID.
+func NewID() string {
+	b := make([]byte, 8)
+	if _, err := rand.Read(b); err != nil {
+		return "unknown"
+	}
+	return hex.EncodeToString(b)
+}
+
+// WithLogger returns a context carrying logger.
+func WithLogger(ctx context.Context, logger *slog.Logger) context.Context {
+	return context.WithValue(ctx, loggerKey{}, logger)
+}
+
+// FromContext returns the logger carried by ctx, or the default logger.
+func FromContext(ctx context.Context) *slog.Logger {
+	if ctx != nil {
+		if logger, ok := ctx.Value(loggerKey{}).(*slog.Logger); ok {
+			return logger
+		}
+	}
+	return slog.Default()
+}
@@ -0,0 +1,106 @@
+package logging
+
+import (
+	"bytes"
+	"errors"
+	"log/slog"
+	"net/http"
+	"strings"
+	"testing"
+)
+
+// Made-up credentials in the shapes the bot handles.
+const (
+	discordToken = "MTA5ODc2NTQzMjEwOTg3NjU0Mw.GhIjKl.abcdefghijklmnopqrstuvwxyz0123"
+	apiKey       = "csk-4f9a8b7c6d5e4f3a2b1c0d9e8f7a6b5c"
+	otherToken   = "OTk4ODc3NjY1NTQ0MzMyMjExMA.XyZaBc.zyxwvutsrqponmlkjihgfedcba98765"
+)
+
+// logged runs log against a fresh handler configured from the environment
+// and returns the output.
+func logged(t *testing.T, format string, log func(*slog.Logger)) string {
+	t.Helper()
+	t.Setenv("LOG_FORMAT", format)
+	t.Setenv("DISCORD_BOT_TOKEN", discordToken)
+	t.Setenv("CEREBRAS_API_KEY", apiKey)
+	var buf bytes.Buffer
+	log(slog.New(NewHandler(&buf)))
+	return buf.String()
+}
+
+func TestRedactsSecrets(t *testing.T) {
+	tests := []struct {
+		name string
+		log  func(*slog.Logger)
+	}{
+		{"known token in the message", func(l *slog.Logger) { l.Info("connecting with " + discordToken) }},
+		{"known token in an error", func(l *slog.Logger) {
+			l.Error("login failed", "error", errors.New("401 for token "+discordToken))
+		}},
+		{"unknown Discord token", func(l *slog.Logger) { l.Info("got " + otherToken) }},
+		{"bot authorization header", func(l *slog.Logger) { l.Info("request", "header", "Bot "+otherToken) }},
+		{"known API key", func(l *slog.Logger) { l.Warn("LLM call failed", "body", "invalid key "+apiKey) }},
+		{"API key in a URL", func(l *slog.Logger) {
+			l.Info("fetching", "url", "https://example.com/v1?api_key=sk-live-123456&q=1")
+		}},
+		{"secret attribute key", func(l *slog.Logger) { l.Info("config", "api_key", "sk-live-123456") }},
+		{"secret key in a nested group", func(l *slog.Logger) {
+			l.Info("request", slog.Group("http", slog.Group("headers", slog.String("Authorization", "sk-live-123456"))))
+		}},
+		{"token in a nested group value", func(l *slog.Logger) {
+			l.Info("request", slog.Group("http", slog.String("auth", "Bearer "+otherToken)))
+		}},
+		{"token inside a non-string value", func(l *slog.Logger) {
+			l.Info("request", "headers", http.Header{"Authorization": {"Bearer " + otherToken}})
+		}},
+		{"logger with attributes", func(l *slog.Logger) {
+			l.With("token", "sk-live-123456").WithGroup("g").Info("hi", "key", apiKey)
+		}},
+	}
+	for _, format := range []string{"json", "text"} {
+		for _, tt := range tests {
+			t.Run(format+"/"+tt.name, func(t *testing.T) {
+				out := logged(t, format, tt.log)
+				for _, secret := range []string{discordToken, otherToken, apiKey, "sk-live-123456"} {
+					if strings.Contains(out, secret) {
+						t.Errorf("output contains %q:\n%s", secret, out)
+					}
+				}
+				if !strings.Contains(out, redacted) {
+					t.Errorf("output has no %s marker:\n%s", redacted, out)
+				}
+			})
+		}
+	}
+}
+
+func TestRedactContent(t *testing.T) {
+	log := func(l *slog.Logger) {
+		l.Info("reply sent", "prompt", "my secret plan", slog.Group("ai", slog.String("reply", "the answer is 42")), "user", "alice")
+	}
+
+	t.Setenv("LOG_REDACT_CONTENT", "true")
+	out := logged(t, "json", log)
+	for _, text := range []string{"my secret plan", "the answer is 42"} {
+		if strings.Contains(out, text) {
+			t.Errorf("LOG_REDACT_CONTENT output contains %q:\n%s", text, out)
+		}
+	}
+	for _, want := range []string{`"prompt":"[14 chars]"`, `"reply":"[16 chars]"`, `"user":"alice"`} {
+		if !strings.Contains(out, want) {
+			t.Errorf("LOG_REDACT_CONTENT output lacks %s:\n%s", want, out)
+		}
+	}
+
+	t.Setenv("LOG_REDACT_CONTENT", "")
+	if out := logged(t, "json", log); !strings.Contains(out, "my secret plan") {
+		t.Errorf("content was redacted without LOG_REDACT_CONTENT:\n%s", out)
+	}
+}
+
+func TestShortSecretsAreIgnored(t *testing.T) {
+	r := newRedactor([]string{"", "abc"}, false)
+	if got := r.scrub("abc def"); got != "abc def" {
+		t.Errorf("scrub with a short secret = %q, want the text unchanged", got)
+	}
+}
@@ -1,78 +1,156 @@
 package main
 
 import (
-    "fmt"
-    "log"
+    "context"
+    "log/slog"
     "net/http"
     "os"
+    "os/signal"
+    "syscall"
+    "time"
 
     "discord-ai-bot/db"
     "discord-ai-bot/handler"
+    "discord-ai-bot/logging"
+    "discord-ai-bot/presence"
+    "discord-ai-bot/server"
 
     "github.com/bwmarrin/discordgo"
     "github.com/joho/godotenv"
 )
 
+// defaultShutdownTimeout bounds how long shutdown waits for in-flight replies.
+// Override with SHUTDOWN_TIMEOUT (a Go duration such as "45s").
+const defaultShutdownTimeout = 25 * time.Second
+
+// httpShutdownTimeout is how long open HTTP requests get to finish once the
+// bot has drained, separate from the drain's own timeout.
+const httpShutdownTimeout = 5 * time.Second
+
 func main() {
-    if err := godotenv.Load(); err != nil {
-        log.Println("Note: No .env file found.")
+    envErr := godotenv.Load()
+    logging.Setup()
+    if envErr != nil {
+        slog.Info("no .env file found, using process environment")
+    }
+
+    // Maintenance subcommands (backup, restore, export, ...) exit here
+    if len(os.Args) > 1 && os.Args[1] != "run" {
+        os.Exit(runCommand(os.Args[1], os.Args[2:]))
     }
 
     token := os.Getenv("DISCORD_BOT_TOKEN")
     port := os.Getenv("PORT") 
-    dbPath := os.Getenv("DB_PATH")
+    dbPath := defaultDBPath()
 
-    if token == "" { log.Fatal("FATAL: DISCORD_BOT_TOKEN not set.") }
-    if dbPath == "" { dbPath = "bot_memory.db" }
+    if token == "" { fatal("DISCORD_BOT_TOKEN not set") }
 
-    db.InitDB(dbPath) 
+    backend := os.Getenv("DB_BACKEND")
+    store, err := db.Open(backend, dbPath)
+    if err != nil { fatal("opening database failed", "backend", backend, "path", dbPath, "error", err) }
 
     dg, err := discordgo.New("Bot " + token)
-    if err != nil { log.Fatalf("FATAL: Error creating Discord session: %v", err) }
+    if err != nil { fatal("creating Discord session failed", "error", err) }
 
-    // 1. Load and Set Saved Status
-    initialStatus := db.LoadStatus()
-    if initialStatus != nil {
-        dg.UpdateStatusComplex(*initialStatus)
-    }
+    // 1. Presence: applied on every Ready/Resumed, since a status sent
+    // before the connection is open is lost and reconnects can reset it
+    pres := presence.New(dg, store)
+    dg.AddHandler(pres.Ready)
+    dg.AddHandler(pres.Resumed)
 
     // 2. Register Handlers
-    dg.AddHandler(handler.MessageCreate(dg)) // AI Chat Handler
-    dg.AddHandler(handler.InteractionCreate) // NEW: UI/Slash Command Handler
+    h := handler.New(store, pres)
+    dg.AddHandler(h.MessageCreate)     // AI Chat Handler
+    dg.AddHandler(h.MessageUpdate)     // Keeps history in step with edits
+    dg.AddHandler(h.MessageDelete)     // ...and deletions
+    dg.AddHandler(h.MessageDeleteBulk)
+    dg.AddHandler(h.InteractionCreate) // NEW: UI/Slash Command Handler
+    dg.AddHandler(h.ThreadDelete)      // Drops deleted conversation threads' history
+    dg.AddHandler(h.RateLimit)         // Metrics: Discord rate limit hits
 
-    dg.Identify.Intents = discordgo.IntentsGuildMessages | discordgo.IntentsMessageContent
+    // IntentsGuilds delivers thread create/update/delete events, which keep
+    // conversation threads in the state cache.
+    dg.Identify.Intents = discordgo.IntentsGuilds | discordgo.IntentsGuildMessages | discordgo.IntentsMessageContent
 
     if err = dg.Open(); err != nil {
-        log.Fatalf("FATAL: Error opening connection: %v", err)
+        fatal("opening Discord connection failed", "error", err)
     }
     
     // 3. REGISTER SLASH COMMANDS
-    // We register them globally (might take up to an hour to appear, 
-    // strictly for development you can pass a Guild ID as the second arg instead of "")
-    log.Println("Registering slash commands...")
-    commands := []*discordgo.ApplicationCommand{
-        {
-            Name:        "config",
-            Description: "Open the UI to edit Bot Status, Activity, and RPC Assets",
-        },
-        {
-            Name:        "personality",
-            Description: "Open the UI to edit the AI Personality",
-        },
+    // Global commands can take up to an hour to show up; set DEV_GUILD_ID to
+    // register them to one guild instead, where updates are instant.
+    if err := handler.SyncCommands(dg, os.Getenv("DEV_GUILD_ID")); err != nil {
+        fatal("registering slash commands failed", "error", err)
     }
 
-    for _, v := range commands {
-        _, err := dg.ApplicationCommandCreate(dg.State.User.ID, "", v)
-        if err != nil {
-            log.Panicf("Cannot create '%v' command: %v", v.Name, err)
+    slog.Info("bot is running with slash commands active", "user", dg.State.User.Username)
+    
+    if port == "" { port = "8080" }
+    // Serves /, /healthz, /readyz, /metrics and the /admin dashboard
+    srv := &http.Server{Addr: ":" + port, Handler: server.New(dg, store, pres)}
+    serveErr := make(chan error, 1)
+    go func() {
+        if err := srv.ListenAndServe(); err != nil && err != http.ErrServerClosed {
+            serveErr <- err
         }
+    }()
+
+    // Background jobs stop when the bot shuts down
+    background, stopBackground := context.WithCancel(context.Background())
+    defer stopBackground()
+    go scheduleBackups(background, store, dbPath)
+    go runJanitor(background, store)
+    go pres.Run(background)
+
+    // 4. GRACEFUL SHUTDOWN
+    // Wait for SIGINT/SIGTERM, stop taking new triggers, let in-flight replies
+    // finish, then close everything so BoltDB is never cut off mid-write.
+    // A failed HTTP listener takes the same path so the store is still closed.
+    stop := make(chan os.Signal, 1)
+    signal.Notify(stop, syscall.SIGINT, syscall.SIGTERM)
+    exitCode := 0
+    select {
+    case sig := <-stop:
+        slog.Info("shutting down", "signal", sig.String())
+    case err := <-serveErr:
+        slog.Error("HTTP server failed, shutting down", "error", err)
+        exitCode = 1
     }
 
-    log.Println("✅ Bot is running with Slash Commands active.")
-    
-    if port == "" { port = "8080" }
-    http.HandleFunc("/", func(w http.ResponseWriter, r *http.Request) {
-        fmt.Fprintf(w, "Discord Bot is running.")
-    })
-    log.Fatal(http.ListenAndServe(":"+port, nil))
+    timeout := defaultShutdownTimeout
+    if d, err := time.ParseDuration(os.Getenv("SHUTDOWN_TIMEOUT")); err == nil {
+        timeout = d
+    }
+    ctx, cancel := context.WithTimeout(context.Background(), timeout)
+    defer cancel()
+
+    stopBackground()
+    handler.StopAccepting()
+    // Past the timeout, in-flight requests are cancelled and given a few
+    // seconds to return before the store is closed under them
+    if err := handler.Drain(ctx); err != nil {
+        slog.Warn("cancelled in-flight requests after the shutdown timeout", "error", err)
+    }
+    if err := dg.Close(); err != nil {
+        slog.Error("closing Discord session failed", "error", err)
+    }
+    // The drain may have used up ctx, so the HTTP server gets its own deadline
+    httpCtx, cancelHTTP := context.WithTimeout(context.Background(), httpShutdownTimeout)
+    defer cancelHTTP()
+    if err := srv.Shutdown(httpCtx); err != nil {
+        slog.Error("shutting down HTTP server failed", "error", err)
+    }
+    if err := store.Close(); err != nil {
+        slog.Error("closing database failed", "error", err)
+    }
+    slog.Info("shutdown complete")
+    if exitCode != 0 {
+        os.Exit(exitCode)
+    }
+}
+
+// fatal logs an error with structured fields and exits.
+func fatal(msg string, args ...any) {
+    slog.Error(msg, args...)
+    os.Exit(1)
 }
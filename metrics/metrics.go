@@ -0,0 +1,224 @@
+// Package metrics keeps in-process counters and histograms and renders them in
+// the Prometheus text exposition format.
+package metrics
+
+import (
+	"fmt"
+	"io"
+	"math"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+)
+
+// The bot's metrics. Label values should come from a small fixed set.
+var (
+	// Messages counts messages that triggered the bot, by outcome.
+	Messages = NewCounterVec("discord_bot_messages_total", "Messages that triggered the bot, by outcome.", "outcome")
+	// LLMLatency observes chat completion latency, by model.
+	LLMLatency = NewHistogramVec("discord_bot_llm_request_duration_seconds", "Latency of LLM provider calls.",
+		[]float64{0.25, 0.5, 1, 2, 5, 10, 20, 30, 60}, "model")
+	// Tokens counts provider token usage, by kind (prompt or completion).
+	Tokens = NewCounterVec("discord_bot_llm_tokens_total", "Tokens used by LLM provider calls.", "kind")
+	// Errors counts errors, by type.
+	Errors = NewCounterVec("discord_bot_errors_total", "Errors by type.", "type")
+	// RateLimitHits counts rate limit responses, by source (discord or provider).
+	RateLimitHits = NewCounterVec("discord_bot_rate_limit_hits_total", "Rate limit responses received.", "source")
+)
+
+var (
+	registryMu sync.Mutex
+	registry   []collector
+)
+
+type collector interface {
+	write(w io.Writer)
+}
+
+func register(c collector) {
+	registryMu.Lock()
+	defer registryMu.Unlock()
+	registry = append(registry, c)
+}
+
+// WritePrometheus writes every registered metric in the Prometheus text format.
+func WritePrometheus(w io.Writer) {
+	registryMu.Lock()
+	collectors := append([]collector(nil), registry...)
+	registryMu.Unlock()
+
+	for _, c := range collectors {
+		c.write(w)
+	}
+}
+
+// --- COUNTERS ---
+
+// CounterVec is a set of counters partitioned by label values.
+type CounterVec struct {
+	name   string
+	help   string
+	labels []string
+
+	mu     sync.Mutex
+	values map[string]float64
+}
+
+// NewCounterVec creates and registers a counter with the given label names.
+func NewCounterVec(name, help string, labels ...string) *CounterVec {
+	c := &CounterVec{name: name, help: help, labels: labels, values: make(map[string]float64)}
+	register(c)
+	return c
+}
+
+// Inc adds one to the counter for the label values.
+func (c *CounterVec) Inc(labelValues ...string) {
+	c.Add(1, labelValues...)
+}
+
+// Add adds v to the counter for the label values. Negative values are ignored.
+func (c *CounterVec) Add(v float64, labelValues ...string) {
+	if v < 0 {
+		return
+	}
+	key := labelKey(labelValues)
+	c.mu.Lock()
+	c.values[key] += v
+	c.mu.Unlock()
+}
+
+func (c *CounterVec) write(w io.Writer) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	fmt.Fprintf(w, "# HELP %s %s\n# TYPE %s counter\n", c.name, escapeHelp(c.help), c.name)
+	for _, key := range sortedKeys(c.values) {
+		fmt.Fprintf(w, "%s%s %s\n", c.name, formatLabels(c.labels, splitKey(key), ""), formatValue(c.values[key]))
+	}
+}
+
+// --- HISTOGRAMS ---
+
+// HistogramVec is a set of histograms partitioned by label values.
+type HistogramVec struct {
+	name    string
+	help    string
+	labels  []string
+	buckets []float64
+
+	mu     sync.Mutex
+	values map[string]*histogram
+}
+
+type histogram struct {
+	counts []uint64 // one per bucket, not cumulative
+	count  uint64
+	sum    float64
+}
+
+// NewHistogramVec creates and registers a histogram with the given upper bounds and label names.
+func NewHistogramVec(name, help string, buckets []float64, labels ...string) *HistogramVec {
+	sorted := append([]float64(nil), buckets...)
+	sort.Float64s(sorted)
+	h := &HistogramVec{name: name, help: help, labels: labels, buckets: sorted, values: make(map[string]*histogram)}
+	register(h)
+	return h
+}
+
+// Observe records v in the histogram for the label values.
+func (h *HistogramVec) Observe(v float64, labelValues ...string) {
+	key := labelKey(labelValues)
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	hist := h.values[key]
+	if hist == nil {
+		hist = &histogram{counts: make([]uint64, len(h.buckets))}
+		h.values[key] = hist
+	}
+	for idx, upper := range h.buckets {
+		if v <= upper {
+			hist.counts[idx]++
+			break
+		}
+	}
+	hist.count++
+	hist.sum += v
+}
+
+func (h *HistogramVec) write(w io.Writer) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	fmt.Fprintf(w, "# HELP %s %s\n# TYPE %s histogram\n", h.name, escapeHelp(h.help), h.name)
+	for _, key := range sortedKeys(h.values) {
+		hist := h.values[key]
+		values := splitKey(key)
+		var cumulative uint64
+		for idx, upper := range h.buckets {
+			cumulative += hist.counts[idx]
+			fmt.Fprintf(w, "%s_bucket%s %d\n", h.name, formatLabels(h.labels, values, formatValue(upper)), cumulative)
+		}
+		fmt.Fprintf(w, "%s_bucket%s %d\n", h.name, formatLabels(h.labels, values, "+Inf"), hist.count)
+		fmt.Fprintf(w, "%s_sum%s %s\n", h.name, formatLabels(h.labels, values, ""), formatValue(hist.sum))
+		fmt.Fprintf(w, "%s_count%s %d\n", h.name, formatLabels(h.labels, values, ""), hist.count)
+	}
+}
+
+// --- FORMATTING ---
+
+// labelKey joins label values with a separator that can't appear in valid UTF-8.
+func labelKey(values []string) string {
+	return strings.Join(values, "\xff")
+}
+
+func splitKey(key string) []string {
+	return strings.Split(key, "\xff")
+}
+
+func sortedKeys[V any](m map[string]V) []string {
+	keys := make([]string, 0, len(m))
+	for k := range m {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	return keys
+}
+
+// formatLabels renders {name="value",...}, adding an le label for histogram buckets.
+func formatLabels(names, values []string, le string) string {
+	var parts []string
+	for idx, name := range names {
+		value := ""
+		if idx < len(values) {
+			value = values[idx]
+		}
+		parts = append(parts, name+`="`+labelEscaper.Replace(value)+`"`)
+	}
+	if le != "" {
+		parts = append(parts, `le="`+le+`"`)
+	}
+	if len(parts) == 0 {
+		return ""
+	}
+	return "{" + strings.Join(parts, ",") + "}"
+}
+
+// The text format escapes only these characters; Go's %q escapes more and
+// would produce sequences Prometheus rejects.
+var (
+	labelEscaper = strings.NewReplacer(`\`, `\\`, `"`, `\"`, "\n", `\n`)
+	helpEscaper  = strings.NewReplacer(`\`, `\\`, "\n", `\n`)
+)
+
+func escapeHelp(help string) string {
+	return helpEscaper.Replace(help)
+}
+
+func formatValue(v float64) string {
+	if math.IsInf(v, 1) {
+		return "+Inf"
+	}
+	return strconv.FormatFloat(v, 'g', -1, 64)
+}
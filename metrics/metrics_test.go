@@ -0,0 +1,62 @@
+package metrics
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+)
+
+func TestCounterFormat(t *testing.T) {
+	// Built directly so the test doesn't add to the global registry
+	c := &CounterVec{name: "test_total", help: "Things with a \\ and a\nnewline.", labels: []string{"kind"}, values: make(map[string]float64)}
+	c.Inc("plain")
+	c.Add(2.5, `quote " backslash \ newline`+"\n"+`é`)
+	c.Add(-1, "plain")
+
+	var buf bytes.Buffer
+	c.write(&buf)
+	want := strings.Join([]string{
+		`# HELP test_total Things with a \\ and a\nnewline.`,
+		`# TYPE test_total counter`,
+		`test_total{kind="plain"} 1`,
+		`test_total{kind="quote \" backslash \\ newline\né"} 2.5`,
+		``,
+	}, "\n")
+	if got := buf.String(); got != want {
+		t.Errorf("counter output:\n%s\nwant:\n%s", got, want)
+	}
+}
+
+func TestHistogramFormat(t *testing.T) {
+	h := &HistogramVec{name: "test_seconds", help: "Latency.", labels: []string{"model"}, buckets: []float64{0.5, 1}, values: make(map[string]*histogram)}
+	for _, v := range []float64{0.25, 0.75, 3} {
+		h.Observe(v, "a")
+	}
+
+	var buf bytes.Buffer
+	h.write(&buf)
+	want := strings.Join([]string{
+		`# HELP test_seconds Latency.`,
+		`# TYPE test_seconds histogram`,
+		`test_seconds_bucket{model="a",le="0.5"} 1`,
+		`test_seconds_bucket{model="a",le="1"} 2`,
+		`test_seconds_bucket{model="a",le="+Inf"} 3`,
+		`test_seconds_sum{model="a"} 4`,
+		`test_seconds_count{model="a"} 3`,
+		``,
+	}, "\n")
+	if got := buf.String(); got != want {
+		t.Errorf("histogram output:\n%s\nwant:\n%s", got, want)
+	}
+}
+
+func TestUnlabelledCounterFormat(t *testing.T) {
+	c := &CounterVec{name: "up_total", help: "Up.", values: make(map[string]float64)}
+	c.Inc()
+
+	var buf bytes.Buffer
+	c.write(&buf)
+	if want := "# HELP up_total Up.\n# TYPE up_total counter\nup_total 1\n"; buf.String() != want {
+		t.Errorf("unlabelled counter output:\n%s\nwant:\n%s", buf.String(), want)
+	}
+}
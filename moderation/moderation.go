@@ -0,0 +1,280 @@
+// Package moderation checks user prompts and AI replies against configurable
+// blocklists, regular expressions and an optional moderation model.
+package moderation
+
+import (
+	"bufio"
+	"context"
+	"fmt"
+	"log/slog"
+	"os"
+	"regexp"
+	"strings"
+
+	"discord-ai-bot/ai"
+	"discord-ai-bot/logging"
+)
+
+// Level is a guild's moderation strictness.
+type Level int
+
+const (
+	// LevelOff disables moderation entirely.
+	LevelOff Level = iota
+	// LevelLow redacts blocklisted text from replies but never refuses.
+	LevelLow
+	// LevelMedium refuses blocklisted prompts and redacts blocklisted replies.
+	LevelMedium
+	// LevelHigh additionally runs the moderation model on prompts and replies
+	// and refuses anything it flags.
+	LevelHigh
+)
+
+var levelNames = []string{"off", "low", "medium", "high"}
+
+// String returns the level's name as used in /moderation.
+func (l Level) String() string {
+	if l < LevelOff || l > LevelHigh {
+		return "unknown"
+	}
+	return levelNames[l]
+}
+
+// ParseLevel converts a level name to a Level.
+func ParseLevel(s string) (Level, bool) {
+	for idx, name := range levelNames {
+		if strings.EqualFold(strings.TrimSpace(s), name) {
+			return Level(idx), true
+		}
+	}
+	return LevelOff, false
+}
+
+// DefaultLevel is the level for guilds that never ran /moderation, taken from
+// MODERATION_DEFAULT_LEVEL (medium when unset).
+func DefaultLevel() Level {
+	if l, ok := ParseLevel(os.Getenv("MODERATION_DEFAULT_LEVEL")); ok {
+		return l
+	}
+	return LevelMedium
+}
+
+// Action is what happened to a moderated text.
+type Action int
+
+const (
+	// ActionAllow means the text passed unchanged.
+	ActionAllow Action = iota
+	// ActionRedact means matches were replaced and the redacted text can be used.
+	ActionRedact
+	// ActionRefuse means the text must not be used at all.
+	ActionRefuse
+)
+
+// Verdict is the outcome of checking a text.
+type Verdict struct {
+	Action  Action
+	Reasons []string
+	// Text is the text to use: the original, or the redacted version for ActionRedact.
+	Text string
+}
+
+// redactedMarker replaces blocklisted text in redacted output.
+const redactedMarker = "[redacted]"
+
+// Filter holds the compiled blocklist rules.
+type Filter struct {
+	rules []rule
+}
+
+type rule struct {
+	name string
+	re   *regexp.Regexp
+	// word rules capture the blocklisted word as group 1, between the
+	// boundary characters around it.
+	word bool
+}
+
+// redact replaces every match of the rule in text, reporting whether there
+// was one. For word rules only the word itself is replaced, and the search
+// resumes right after it so the boundary character after one match can
+// start the next ("bad bad").
+func (r rule) redact(text string) (string, bool) {
+	if !r.word {
+		if !r.re.MatchString(text) {
+			return text, false
+		}
+		return r.re.ReplaceAllString(text, redactedMarker), true
+	}
+	var sb strings.Builder
+	pos := 0
+	for pos < len(text) {
+		loc := r.re.FindStringSubmatchIndex(text[pos:])
+		if loc == nil {
+			break
+		}
+		sb.WriteString(text[pos : pos+loc[2]])
+		sb.WriteString(redactedMarker)
+		pos += loc[3]
+	}
+	if sb.Len() == 0 {
+		return text, false
+	}
+	sb.WriteString(text[pos:])
+	return sb.String(), true
+}
+
+// NewFilter compiles a blocklist. Words are matched case-insensitively when
+// they stand on their own, between non-word characters or the ends of the
+// text, so entries such as "f*ck" or an emoji work too. Patterns are regular
+// expressions used as-is.
+func NewFilter(words, patterns []string) (*Filter, error) {
+	f := &Filter{}
+	for _, w := range words {
+		w = strings.TrimSpace(w)
+		if w == "" {
+			continue
+		}
+		re := regexp.MustCompile(`(?i)(?:^|\W)(` + regexp.QuoteMeta(w) + `)(?:\W|$)`)
+		f.rules = append(f.rules, rule{name: "blocklist: " + w, re: re, word: true})
+	}
+	for _, p := range patterns {
+		p = strings.TrimSpace(p)
+		if p == "" {
+			continue
+		}
+		re, err := regexp.Compile(p)
+		if err != nil {
+			return nil, fmt.Errorf("compiling pattern %q: %w", p, err)
+		}
+		f.rules = append(f.rules, rule{name: "pattern: " + p, re: re})
+	}
+	return f, nil
+}
+
+// LoadFilterFromEnv builds the filter from MODERATION_BLOCKLIST (comma-separated
+// words) and MODERATION_BLOCKLIST_FILE (one word per line, "re:" prefix for a
+// regular expression, "#" for comments). Invalid entries are logged and skipped.
+func LoadFilterFromEnv() *Filter {
+	words := strings.Split(os.Getenv("MODERATION_BLOCKLIST"), ",")
+	var patterns []string
+
+	if path := os.Getenv("MODERATION_BLOCKLIST_FILE"); path != "" {
+		file, err := os.Open(path)
+		if err != nil {
+			slog.Warn("opening moderation blocklist file failed", "path", path, "error", err)
+		} else {
+			scanner := bufio.NewScanner(file)
+			for scanner.Scan() {
+				line := strings.TrimSpace(scanner.Text())
+				switch {
+				case line == "" || strings.HasPrefix(line, "#"):
+				case strings.HasPrefix(line, "re:"):
+					patterns = append(patterns, strings.TrimPrefix(line, "re:"))
+				default:
+					words = append(words, line)
+				}
+			}
+			file.Close()
+		}
+	}
+
+	f, err := NewFilter(words, nil)
+	if err != nil {
+		slog.Warn("compiling moderation blocklist failed", "error", err)
+		f = &Filter{}
+	}
+	for _, p := range patterns {
+		pf, err := NewFilter(nil, []string{p})
+		if err != nil {
+			slog.Warn("skipping invalid moderation pattern", "error", err)
+			continue
+		}
+		f.rules = append(f.rules, pf.rules...)
+	}
+	return f
+}
+
+// match returns the names of all rules matching text and the text with every match redacted.
+func (f *Filter) match(text string) ([]string, string) {
+	var reasons []string
+	redacted := text
+	for _, r := range f.rules {
+		var matched bool
+		if redacted, matched = r.redact(redacted); matched {
+			reasons = append(reasons, r.name)
+		}
+	}
+	return reasons, redacted
+}
+
+// CheckInput moderates a user prompt. Prompts are never redacted: a blocklist
+// hit refuses at medium and above, and the model can refuse at high.
+func (f *Filter) CheckInput(ctx context.Context, text string, level Level) Verdict {
+	v := Verdict{Action: ActionAllow, Text: text}
+	if level < LevelMedium {
+		return v
+	}
+	if reasons, _ := f.match(text); len(reasons) > 0 {
+		return Verdict{Action: ActionRefuse, Reasons: reasons, Text: text}
+	}
+	if level >= LevelHigh {
+		if flagged, reason := classify(ctx, text); flagged {
+			return Verdict{Action: ActionRefuse, Reasons: []string{reason}, Text: text}
+		}
+	}
+	return v
+}
+
+// CheckOutput moderates an AI reply. Blocklist hits are redacted from low
+// upwards, and at high a reply flagged by the model is refused.
+func (f *Filter) CheckOutput(ctx context.Context, text string, level Level) Verdict {
+	v := Verdict{Action: ActionAllow, Text: text}
+	if level < LevelLow {
+		return v
+	}
+	if reasons, redacted := f.match(text); len(reasons) > 0 {
+		v = Verdict{Action: ActionRedact, Reasons: reasons, Text: redacted}
+	}
+	if level >= LevelHigh {
+		if flagged, reason := classify(ctx, v.Text); flagged {
+			return Verdict{Action: ActionRefuse, Reasons: append(v.Reasons, reason), Text: text}
+		}
+	}
+	return v
+}
+
+// classifierPrompt instructs the moderation model to answer with a single verdict line.
+const classifierPrompt = `You are a content moderation classifier for a Discord server. Decide whether the message contains hate speech or slurs targeting protected groups, sexual content involving minors, credible threats of violence, encouragement of self-harm, doxxing or sharing of personal information, or instructions for serious crimes. Ordinary swearing, insults between friends and edgy humour are allowed.
+Answer with exactly one line: "SAFE" or "UNSAFE: <short category>".`
+
+// classify is the moderation model check; tests replace it.
+var classify = classifyWithModel
+
+// classifyWithModel asks the moderation model about text. It is disabled
+// unless MODERATION_MODEL is set, and fails open if the model can't be reached.
+func classifyWithModel(ctx context.Context, text string) (bool, string) {
+	model := os.Getenv("MODERATION_MODEL")
+	if model == "" {
+		return false, ""
+	}
+
+	verdict, err := ai.GetCerebrasResponseWithModel(ctx, model, []ai.Message{
+		{Role: "system", Content: classifierPrompt},
+		{Role: "user", Content: text},
+	})
+	if err != nil {
+		logging.FromContext(ctx).Warn("moderation model call failed, allowing message", "error", err)
+		return false, ""
+	}
+
+	verdict = strings.TrimSpace(verdict)
+	if strings.HasPrefix(strings.ToUpper(verdict), "UNSAFE") {
+		category := strings.TrimSpace(strings.TrimPrefix(verdict[len("UNSAFE"):], ":"))
+		if category == "" {
+			category = "unspecified"
+		}
+		return true, "model: " + category
+	}
+	return false, ""
+}
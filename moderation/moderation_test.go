@@ -0,0 +1,153 @@
+package moderation
+
+import (
+	"context"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func newTestFilter(t *testing.T) *Filter {
+	t.Helper()
+	f, err := NewFilter([]string{"darn", "f*ck", "$lur", "🍆", " "}, []string{`\d{3}-\d{4}`})
+	if err != nil {
+		t.Fatalf("NewFilter: %v", err)
+	}
+	return f
+}
+
+// stubClassifier makes the moderation model flag texts containing flagged.
+func stubClassifier(t *testing.T, flagged string) {
+	t.Helper()
+	t.Cleanup(func() { classify = classifyWithModel })
+	classify = func(_ context.Context, text string) (bool, string) {
+		if strings.Contains(text, flagged) {
+			return true, "model: threats"
+		}
+		return false, ""
+	}
+}
+
+func TestFilterMatch(t *testing.T) {
+	f := newTestFilter(t)
+	tests := []struct {
+		text    string
+		want    string
+		reasons []string
+	}{
+		{"clean text", "clean text", nil},
+		{"Darn it", "[redacted] it", []string{"blocklist: darn"}},
+		{"darn darn", "[redacted] [redacted]", []string{"blocklist: darn"}},
+		{"darned", "darned", nil},
+		{"undarn", "undarn", nil},
+		{"what the f*ck!", "what the [redacted]!", []string{"blocklist: f*ck"}},
+		{"you $lur", "you [redacted]", []string{"blocklist: $lur"}},
+		{"a$lur", "a$lur", nil},
+		{"nice 🍆🍆", "nice [redacted][redacted]", []string{"blocklist: 🍆"}},
+		{"call 555-1234 now", "call [redacted] now", []string{"pattern: \\d{3}-\\d{4}"}},
+		{"darn, 555-1234", "[redacted], [redacted]", []string{"blocklist: darn", "pattern: \\d{3}-\\d{4}"}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.text, func(t *testing.T) {
+			reasons, got := f.match(tt.text)
+			if got != tt.want || !reflect.DeepEqual(reasons, tt.reasons) {
+				t.Errorf("match(%q) = %q, %q; want %q, %q", tt.text, got, reasons, tt.want, tt.reasons)
+			}
+		})
+	}
+}
+
+func TestNewFilterRejectsInvalidPattern(t *testing.T) {
+	if _, err := NewFilter(nil, []string{"("}); err == nil {
+		t.Error("NewFilter accepted an invalid pattern")
+	}
+}
+
+func TestCheckInput(t *testing.T) {
+	f := newTestFilter(t)
+	stubClassifier(t, "hurt")
+	tests := []struct {
+		name  string
+		text  string
+		level Level
+		want  Action
+	}{
+		{"off ignores the blocklist", "darn", LevelOff, ActionAllow},
+		{"low ignores the blocklist", "darn", LevelLow, ActionAllow},
+		{"medium refuses the blocklist", "darn", LevelMedium, ActionRefuse},
+		{"medium skips the model", "I will hurt you", LevelMedium, ActionAllow},
+		{"high refuses the blocklist", "darn", LevelHigh, ActionRefuse},
+		{"high refuses what the model flags", "I will hurt you", LevelHigh, ActionRefuse},
+		{"high allows clean text", "hello", LevelHigh, ActionAllow},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			v := f.CheckInput(context.Background(), tt.text, tt.level)
+			if v.Action != tt.want || v.Text != tt.text {
+				t.Errorf("CheckInput(%q, %s) = %+v, want action %d and the text unchanged", tt.text, tt.level, v, tt.want)
+			}
+		})
+	}
+}
+
+func TestCheckOutput(t *testing.T) {
+	f := newTestFilter(t)
+	stubClassifier(t, "hurt")
+	tests := []struct {
+		name     string
+		text     string
+		level    Level
+		want     Action
+		wantText string
+	}{
+		{"off leaves the blocklist", "darn it", LevelOff, ActionAllow, "darn it"},
+		{"low redacts", "darn it", LevelLow, ActionRedact, "[redacted] it"},
+		{"medium redacts", "darn it", LevelMedium, ActionRedact, "[redacted] it"},
+		{"medium skips the model", "I will hurt you", LevelMedium, ActionAllow, "I will hurt you"},
+		{"high redacts", "darn it", LevelHigh, ActionRedact, "[redacted] it"},
+		{"high refuses what the model flags", "darn, I will hurt you", LevelHigh, ActionRefuse, "darn, I will hurt you"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			v := f.CheckOutput(context.Background(), tt.text, tt.level)
+			if v.Action != tt.want || v.Text != tt.wantText {
+				t.Errorf("CheckOutput(%q, %s) = %+v, want action %d with text %q", tt.text, tt.level, v, tt.want, tt.wantText)
+			}
+		})
+	}
+}
+
+// TestModelFailsOpen checks that an unreachable moderation model lets text
+// through rather than refusing everything.
+func TestModelFailsOpen(t *testing.T) {
+	t.Setenv("MODERATION_MODEL", "llama3.1-8b")
+	t.Setenv("CEREBRAS_API_KEY", "") // every model call fails
+	f := newTestFilter(t)
+	ctx := context.Background()
+
+	if v := f.CheckInput(ctx, "hello", LevelHigh); v.Action != ActionAllow {
+		t.Errorf("CheckInput with a failing model = %+v, want allowed", v)
+	}
+	if v := f.CheckOutput(ctx, "hello", LevelHigh); v.Action != ActionAllow {
+		t.Errorf("CheckOutput with a failing model = %+v, want allowed", v)
+	}
+	if v := f.CheckInput(ctx, "darn", LevelHigh); v.Action != ActionRefuse {
+		t.Errorf("CheckInput with a failing model = %+v, want the blocklist to still refuse", v)
+	}
+
+	t.Setenv("MODERATION_MODEL", "")
+	if flagged, _ := classifyWithModel(ctx, "anything"); flagged {
+		t.Error("classify flagged text without MODERATION_MODEL")
+	}
+}
+
+func TestParseLevel(t *testing.T) {
+	for _, l := range []Level{LevelOff, LevelLow, LevelMedium, LevelHigh} {
+		if got, ok := ParseLevel(" " + l.String() + " "); !ok || got != l {
+			t.Errorf("ParseLevel(%q) = %v, %v", l.String(), got, ok)
+		}
+	}
+	if _, ok := ParseLevel("extreme"); ok {
+		t.Error("ParseLevel accepted an unknown level")
+	}
+}
@@ -0,0 +1,152 @@
+package presence
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"regexp"
+	"strings"
+	"time"
+
+	"discord-ai-bot/ai"
+	"discord-ai-bot/db"
+	"discord-ai-bot/moderation"
+
+	"github.com/bwmarrin/discordgo"
+)
+
+// AI status limits. Lines are generated at most every MinAIStatusInterval to
+// keep the LLM cost down.
+const (
+	DefaultAIStatusInterval = time.Hour
+	MinAIStatusInterval     = 5 * time.Minute
+	MaxAIStatusLength       = 80
+)
+
+// Number and length of recent prompts shown to the LLM as conversation topics.
+const (
+	aiStatusTopics     = 10
+	aiStatusTopicRunes = 150
+)
+
+// linkPattern matches URLs and bare domains such as "discord.gg/x" or "example.com".
+var linkPattern = regexp.MustCompile(`(?i)https?:|www\.|\b[a-z0-9-]+\.(?:com|net|org|gg|io|xyz|tv|me|ly|co|app|dev)\b`)
+
+// aiStatusTopicScopes are the history scopes topics are taken from.
+var aiStatusTopicScopes = []string{db.GlobalScope}
+
+// aiStatusTimeout bounds one generation request.
+const aiStatusTimeout = 30 * time.Second
+
+// aiStatusPrompt is appended to the personality when asking for a status line.
+const aiStatusPrompt = "\n\nYou are picking your own Discord status. Reply with a single short line " +
+	"(at most 60 characters) that fits your personality and, if it suits you, what people have " +
+	"been talking about lately. No quotes, no hashtags, no links and no @mentions. Reply with the line only."
+
+// AIStatusInterval returns the time between generated lines for settings.
+func AIStatusInterval(settings db.AIStatus) time.Duration {
+	if settings.IntervalMinutes > 0 {
+		return time.Duration(settings.IntervalMinutes) * time.Minute
+	}
+	return DefaultAIStatusInterval
+}
+
+// Generate asks the LLM for a new status line based on the personality and
+// the latest prompts in the shared history, validates it and saves it as the
+// AI status line. It does not apply it; Refresh or the next tick does.
+func (m *Manager) Generate(ctx context.Context) (string, error) {
+	m.mu.Lock()
+	m.lastAttempt = time.Now()
+	m.mu.Unlock()
+
+	personality, err := m.store.LoadPersonality()
+	if err != nil {
+		return "", fmt.Errorf("loading personality: %w", err)
+	}
+	// The status is public in every guild, so topics only come from the
+	// shared history; thread conversations may be private
+	recent, err := m.store.RecentUserTurns(aiStatusTopicScopes, aiStatusTopics)
+	if err != nil {
+		return "", fmt.Errorf("loading history: %w", err)
+	}
+	var topics []string
+	for _, turn := range recent {
+		topics = append(topics, "- "+truncateRunes(strings.Join(strings.Fields(turn.Content), " "), aiStatusTopicRunes))
+	}
+	request := "Nobody has said anything lately."
+	if len(topics) > 0 {
+		request = "Recent messages, newest first:\n" + strings.Join(topics, "\n")
+	}
+
+	ctx, cancel := context.WithTimeout(ctx, aiStatusTimeout)
+	defer cancel()
+	completion, err := ai.GetCerebrasCompletion(ctx, "", []ai.Message{
+		{Role: "system", Content: personality + aiStatusPrompt},
+		{Role: "user", Content: request},
+	})
+	if err != nil {
+		return "", fmt.Errorf("asking the LLM: %w", err)
+	}
+	line, err := m.validateLine(ctx, completion.Content)
+	if err != nil {
+		return "", err
+	}
+
+	settings, err := m.store.LoadAIStatus()
+	if err != nil {
+		return "", fmt.Errorf("loading AI status: %w", err)
+	}
+	settings.Line = line
+	settings.GeneratedAt = time.Now().UTC()
+	if err := m.store.SaveAIStatus(settings); err != nil {
+		return "", fmt.Errorf("saving AI status: %w", err)
+	}
+	return line, nil
+}
+
+// validateLine cleans up the LLM's answer and rejects lines that are empty,
+// too long, ping or link anywhere, or fail moderation at the strictest level.
+func (m *Manager) validateLine(ctx context.Context, text string) (string, error) {
+	line := ""
+	for _, l := range strings.Split(text, "\n") {
+		if l = strings.TrimSpace(l); l != "" {
+			line = l
+			break
+		}
+	}
+	line = strings.TrimSpace(strings.Trim(line, "\"'`*“”"))
+	switch {
+	case line == "":
+		return "", errors.New("the LLM returned an empty line")
+	case len([]rune(line)) > MaxAIStatusLength:
+		return "", fmt.Errorf("line is longer than %d characters", MaxAIStatusLength)
+	case strings.Contains(line, "@") || strings.Contains(line, "<#"):
+		return "", errors.New("line contains a mention")
+	case linkPattern.MatchString(line):
+		return "", errors.New("line contains a link")
+	}
+	if v := m.filter.CheckInput(ctx, line, moderation.LevelHigh); v.Action == moderation.ActionRefuse {
+		return "", fmt.Errorf("line failed moderation: %s", strings.Join(v.Reasons, ", "))
+	}
+	return line, nil
+}
+
+// aiPresence shows line as a custom status, keeping the saved online status.
+func aiPresence(base *discordgo.UpdateStatusData, line string) discordgo.UpdateStatusData {
+	status := "online"
+	if base != nil && base.Status != "" {
+		status = base.Status
+	}
+	return discordgo.UpdateStatusData{
+		Status:     status,
+		Activities: []*discordgo.Activity{{Type: discordgo.ActivityTypeCustom, Name: "Custom Status", State: line}},
+	}
+}
+
+func truncateRunes(s string, max int) string {
+	runes := []rune(s)
+	if len(runes) <= max {
+		return s
+	}
+	return string(runes[:max-1]) + "…"
+}
@@ -0,0 +1,56 @@
+package presence
+
+import (
+	"context"
+	"strings"
+	"testing"
+
+	"discord-ai-bot/moderation"
+)
+
+func TestValidateLine(t *testing.T) {
+	t.Setenv("MODERATION_MODEL", "")
+	filter, err := moderation.NewFilter([]string{"darn"}, []string{`(?i)kill\s+\w+`})
+	if err != nil {
+		t.Fatalf("NewFilter: %v", err)
+	}
+	m := &Manager{filter: filter}
+
+	tests := []struct {
+		name    string
+		text    string
+		want    string
+		wantErr string
+	}{
+		{"plain line", "Brewing some tea", "Brewing some tea", ""},
+		{"first non-empty line", "\n\n  Reading the docs  \nand more", "Reading the docs", ""},
+		{"quotes and markdown trimmed", `"**Thinking about pasta**"`, "Thinking about pasta", ""},
+		{"exactly the limit", strings.Repeat("é", MaxAIStatusLength), strings.Repeat("é", MaxAIStatusLength), ""},
+		{"empty", "  \n \"\" ", "", "empty"},
+		{"too long", strings.Repeat("a", MaxAIStatusLength+1), "", "longer than"},
+		{"user mention", "Hi <@1234>", "", "mention"},
+		{"everyone", "Hello @everyone", "", "mention"},
+		{"channel mention", "Hanging out in <#1234>", "", "mention"},
+		{"https link", "Watch https://youtu.be/x", "", "link"},
+		{"bare invite", "Join discord.gg/abc", "", "link"},
+		{"bare domain", "Shopping at Example.com today", "", "link"},
+		{"www", "Browsing www.reddit", "", "link"},
+		{"dotted words are fine", "Version 2.0 is out. Nice", "Version 2.0 is out. Nice", ""},
+		{"blocklisted word", "Darn, Mondays", "", "moderation"},
+		{"blocklisted pattern", "Ready to kill everyone", "", "moderation"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := m.validateLine(context.Background(), tt.text)
+			if tt.wantErr != "" {
+				if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+					t.Errorf("validateLine(%q) = %q, %v; want an error about %s", tt.text, got, err, tt.wantErr)
+				}
+				return
+			}
+			if err != nil || got != tt.want {
+				t.Errorf("validateLine(%q) = %q, %v; want %q", tt.text, got, err, tt.want)
+			}
+		})
+	}
+}
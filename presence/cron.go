@@ -0,0 +1,134 @@
+package presence
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Schedule is a parsed five-field cron expression: minute, hour, day of
+// month, month and day of week. Each field accepts "*", numbers, ranges
+// ("1-5"), steps ("*/15", "0-30/10") and comma-separated lists of those.
+type Schedule struct {
+	minute, hour, dom, month, dow uint64
+	// Cron matches a day when either day field does if both are restricted.
+	domAny, dowAny bool
+}
+
+// cronFields are the bounds of each field, in order.
+var cronFields = []struct {
+	name     string
+	min, max int
+}{
+	{"minute", 0, 59},
+	{"hour", 0, 23},
+	{"day of month", 1, 31},
+	{"month", 1, 12},
+	{"day of week", 0, 7},
+}
+
+// ParseSchedule parses a cron expression such as "*/30 9-17 * * 1-5".
+func ParseSchedule(expr string) (Schedule, error) {
+	fields := strings.Fields(expr)
+	if len(fields) != len(cronFields) {
+		return Schedule{}, fmt.Errorf("cron schedule needs %d fields (minute hour day month weekday), got %d", len(cronFields), len(fields))
+	}
+	var sets [5]uint64
+	for idx, field := range fields {
+		set, err := parseCronField(field, cronFields[idx].min, cronFields[idx].max)
+		if err != nil {
+			return Schedule{}, fmt.Errorf("cron %s %q: %w", cronFields[idx].name, field, err)
+		}
+		sets[idx] = set
+	}
+	// Both 0 and 7 mean Sunday
+	if sets[4]&(1<<7) != 0 {
+		sets[4] |= 1
+	}
+	return Schedule{
+		minute: sets[0],
+		hour:   sets[1],
+		dom:    sets[2],
+		month:  sets[3],
+		dow:    sets[4],
+		// Like cron, a day field starting with "*" ("*", "*/2") counts as unrestricted
+		domAny: strings.HasPrefix(fields[2], "*"),
+		dowAny: strings.HasPrefix(fields[4], "*"),
+	}, nil
+}
+
+// parseCronField returns the values a field allows as a bit set.
+func parseCronField(field string, min, max int) (uint64, error) {
+	var set uint64
+	for _, part := range strings.Split(field, ",") {
+		rng, stepText, hasStep := strings.Cut(part, "/")
+		step := 1
+		if hasStep {
+			n, err := strconv.Atoi(stepText)
+			if err != nil || n <= 0 {
+				return 0, fmt.Errorf("invalid step %q", stepText)
+			}
+			step = n
+		}
+
+		lo, hi := min, max
+		if rng != "*" {
+			loText, hiText, isRange := strings.Cut(rng, "-")
+			var err error
+			if lo, err = strconv.Atoi(loText); err != nil {
+				return 0, fmt.Errorf("invalid value %q", loText)
+			}
+			hi = lo
+			if isRange {
+				if hi, err = strconv.Atoi(hiText); err != nil {
+					return 0, fmt.Errorf("invalid value %q", hiText)
+				}
+			} else if hasStep {
+				// "5/15" means every 15 starting at 5
+				hi = max
+			}
+		}
+		if lo < min || hi > max {
+			return 0, fmt.Errorf("%s is outside %d-%d", rng, min, max)
+		}
+		if lo > hi {
+			return 0, fmt.Errorf("range %s runs backwards", rng)
+		}
+		for v := lo; v <= hi; v += step {
+			set |= 1 << uint(v)
+		}
+	}
+	return set, nil
+}
+
+// Next returns the first minute after t that matches the schedule, or the
+// zero time if none does within five years (e.g. "0 0 31 2 *").
+func (s Schedule) Next(t time.Time) time.Time {
+	t = t.Truncate(time.Minute).Add(time.Minute)
+	limit := t.AddDate(5, 0, 0)
+	for t.Before(limit) {
+		switch {
+		case s.month&(1<<uint(t.Month())) == 0:
+			t = time.Date(t.Year(), t.Month()+1, 1, 0, 0, 0, 0, t.Location())
+		case !s.dayMatches(t):
+			t = time.Date(t.Year(), t.Month(), t.Day()+1, 0, 0, 0, 0, t.Location())
+		case s.hour&(1<<uint(t.Hour())) == 0:
+			t = time.Date(t.Year(), t.Month(), t.Day(), t.Hour()+1, 0, 0, 0, t.Location())
+		case s.minute&(1<<uint(t.Minute())) == 0:
+			t = t.Add(time.Minute)
+		default:
+			return t
+		}
+	}
+	return time.Time{}
+}
+
+func (s Schedule) dayMatches(t time.Time) bool {
+	dom := s.dom&(1<<uint(t.Day())) != 0
+	dow := s.dow&(1<<uint(t.Weekday())) != 0
+	if s.domAny || s.dowAny {
+		return dom && dow
+	}
+	return dom || dow
+}
@@ -0,0 +1,99 @@
+package presence
+
+import (
+	"testing"
+	"time"
+)
+
+func TestParseScheduleInvalid(t *testing.T) {
+	tests := []string{
+		"",
+		"* * * *",
+		"* * * * * *",
+		"60 * * * *",
+		"* 24 * * *",
+		"* * 0 * *",
+		"* * 32 * *",
+		"* * * 13 *",
+		"* * * * 8",
+		"*/0 * * * *",
+		"*/x * * * *",
+		"5-1 * * * *",
+		"a * * * *",
+		"1,,2 * * * *",
+		"-5 * * * *",
+		"1-x * * * *",
+	}
+	for _, expr := range tests {
+		t.Run(expr, func(t *testing.T) {
+			if _, err := ParseSchedule(expr); err == nil {
+				t.Errorf("ParseSchedule(%q) succeeded, want an error", expr)
+			}
+		})
+	}
+}
+
+func TestScheduleNext(t *testing.T) {
+	// 2024-01-01 is a Monday
+	from := time.Date(2024, 1, 1, 10, 7, 30, 0, time.UTC)
+	at := func(month time.Month, day, hour, minute int) time.Time {
+		return time.Date(2024, month, day, hour, minute, 0, 0, time.UTC)
+	}
+	tests := []struct {
+		name string
+		expr string
+		want time.Time
+	}{
+		{"every minute", "* * * * *", at(1, 1, 10, 8)},
+		{"step", "*/15 * * * *", at(1, 1, 10, 15)},
+		{"step from a start value", "5/20 * * * *", at(1, 1, 10, 25)},
+		{"range with step", "0-30/10 11 * * *", at(1, 1, 11, 0)},
+		{"range", "0 9-17 * * *", at(1, 1, 11, 0)},
+		{"list", "0 8,12,18 * * *", at(1, 1, 12, 0)},
+		{"list of ranges", "0 1-2,20-21 * * *", at(1, 1, 20, 0)},
+		{"next day", "0 9 * * *", at(1, 2, 9, 0)},
+		{"month", "0 0 1 3 *", at(3, 1, 0, 0)},
+		{"weekday range", "0 9 * * 6-7", at(1, 6, 9, 0)},
+		{"sunday as 7", "0 9 * * 7", at(1, 7, 9, 0)},
+		{"sunday as 0", "0 9 * * 0", at(1, 7, 9, 0)},
+		{"leap day", "0 0 29 2 *", at(2, 29, 0, 0)},
+		// Both day fields restricted: either one matching is enough
+		{"day of month or day of week", "0 0 15 * 5", at(1, 5, 0, 0)},
+		{"day of month before day of week", "0 0 3 * 5", at(1, 3, 0, 0)},
+		// One day field unrestricted: both must match
+		{"day of month and any weekday", "0 0 15 * *", at(1, 15, 0, 0)},
+		{"stepped day of month counts as unrestricted", "0 0 */2 * 5", at(1, 5, 0, 0)},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			schedule, err := ParseSchedule(tt.expr)
+			if err != nil {
+				t.Fatalf("ParseSchedule(%q): %v", tt.expr, err)
+			}
+			if got := schedule.Next(from); !got.Equal(tt.want) {
+				t.Errorf("Next(%s) for %q = %s, want %s", from, tt.expr, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestScheduleNextImpossibleDates(t *testing.T) {
+	for _, expr := range []string{"0 0 31 2 *", "0 0 30 2 *", "0 0 31 4,6,9,11 *"} {
+		t.Run(expr, func(t *testing.T) {
+			schedule, err := ParseSchedule(expr)
+			if err != nil {
+				t.Fatalf("ParseSchedule(%q): %v", expr, err)
+			}
+			done := make(chan time.Time, 1)
+			go func() { done <- schedule.Next(time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)) }()
+			select {
+			case got := <-done:
+				if !got.IsZero() {
+					t.Errorf("Next for %q = %s, want the zero time", expr, got)
+				}
+			case <-time.After(5 * time.Second):
+				t.Fatalf("Next for %q did not return", expr)
+			}
+		})
+	}
+}
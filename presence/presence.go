@@ -0,0 +1,281 @@
+// Package presence keeps the bot's Discord presence applied: a status line
+// the LLM wrote, the entry a scheduled rotation is currently showing, or the
+// status saved through /config, in that order of priority.
+package presence
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"discord-ai-bot/db"
+	"discord-ai-bot/moderation"
+
+	"github.com/bwmarrin/discordgo"
+)
+
+// DefaultRotationInterval is used when a rotation has neither an interval nor a cron schedule.
+const DefaultRotationInterval = 10 * time.Minute
+
+// retryDelay is how long Run waits after failing to read the settings.
+const retryDelay = time.Minute
+
+// Sources of the applied presence, as returned by Apply.
+const (
+	SourceStatus   = "status"
+	SourceRotation = "rotation"
+	SourceAI       = "ai"
+)
+
+// Manager applies the bot's presence, advances the status rotation and has
+// the LLM write new AI status lines.
+type Manager struct {
+	session *discordgo.Session
+	store   db.Store
+	filter  *moderation.Filter
+	started time.Time
+	refresh chan struct{}
+
+	mu sync.Mutex
+	// current is the index of the rotation entry being shown.
+	current int
+	// lastAttempt is when Generate last ran, successful or not.
+	lastAttempt time.Time
+}
+
+// New returns a Manager for the session's presence. Start it with Run.
+func New(s *discordgo.Session, store db.Store) *Manager {
+	return &Manager{
+		session: s,
+		store:   store,
+		filter:  moderation.LoadFilterFromEnv(),
+		started: time.Now(),
+		refresh: make(chan struct{}, 1),
+	}
+}
+
+// Run writes new AI status lines or moves through the rotation on schedule
+// until ctx is cancelled. The presence itself is first applied by Ready.
+func (m *Manager) Run(ctx context.Context) {
+	for {
+		wait, err := m.untilNext()
+		if err != nil {
+			slog.Error("scheduling status rotation failed", "error", err)
+			wait = retryDelay
+		}
+		// With the rotation off, only a Refresh changes anything
+		var timer *time.Timer
+		var due <-chan time.Time
+		if wait > 0 {
+			timer = time.NewTimer(wait)
+			due = timer.C
+		}
+
+		select {
+		case <-ctx.Done():
+		case <-m.refresh:
+		case <-due:
+			if err == nil {
+				m.advance(ctx)
+			}
+		}
+		if timer != nil {
+			timer.Stop()
+		}
+		if ctx.Err() != nil {
+			return
+		}
+		m.applyLogged()
+	}
+}
+
+// Refresh makes Run reapply the presence and restart the rotation timer.
+// Call it after changing the saved status or rotation.
+func (m *Manager) Refresh() {
+	select {
+	case m.refresh <- struct{}{}:
+	default:
+	}
+}
+
+// advance moves on to the next presence: a fresh line while AI status is on,
+// the next rotation entry otherwise.
+func (m *Manager) advance(ctx context.Context) {
+	settings, err := m.store.LoadAIStatus()
+	if err != nil {
+		slog.Error("loading AI status failed", "error", err)
+		return
+	}
+	if settings.Enabled {
+		line, err := m.Generate(ctx)
+		if err != nil {
+			slog.Warn("generating AI status failed, keeping the current one", "error", err)
+			return
+		}
+		slog.Info("AI status generated", "text", line)
+		return
+	}
+	m.mu.Lock()
+	m.current++
+	m.mu.Unlock()
+}
+
+// untilNext returns how long the current presence stays up, or 0 when
+// neither AI status nor the rotation is on and nothing changes until the next
+// Refresh.
+func (m *Manager) untilNext() (time.Duration, error) {
+	settings, err := m.store.LoadAIStatus()
+	if err != nil {
+		return 0, err
+	}
+	if settings.Enabled {
+		m.mu.Lock()
+		last := m.lastAttempt
+		m.mu.Unlock()
+		if settings.GeneratedAt.After(last) {
+			last = settings.GeneratedAt
+		}
+		return max(time.Until(last.Add(AIStatusInterval(settings))), time.Second), nil
+	}
+
+	rotation, err := m.store.LoadStatusRotation()
+	if err != nil || !Rotating(rotation) {
+		return 0, err
+	}
+	now := time.Now()
+	if rotation.Cron != "" {
+		schedule, err := ParseSchedule(rotation.Cron)
+		if err != nil {
+			return 0, err
+		}
+		next := schedule.Next(now)
+		if next.IsZero() {
+			return 0, fmt.Errorf("cron schedule %q never fires", rotation.Cron)
+		}
+		return next.Sub(now), nil
+	}
+	if rotation.IntervalMinutes > 0 {
+		return time.Duration(rotation.IntervalMinutes) * time.Minute, nil
+	}
+	return DefaultRotationInterval, nil
+}
+
+// Rotating reports whether the rotation decides the presence.
+func Rotating(rotation db.StatusRotation) bool {
+	return rotation.Enabled && len(rotation.Entries) > 0
+}
+
+// Apply sends the presence that should be showing now: the AI status line
+// while AI status is on, else the current rotation entry while the rotation
+// is on, else the saved status. It returns which of them it applied.
+func (m *Manager) Apply() (string, error) {
+	settings, err := m.store.LoadAIStatus()
+	if err != nil {
+		return "", fmt.Errorf("loading AI status: %w", err)
+	}
+	if settings.Enabled && settings.Line != "" {
+		status, err := m.store.LoadStatus()
+		if err != nil {
+			return "", fmt.Errorf("loading status: %w", err)
+		}
+		return SourceAI, m.session.UpdateStatusComplex(aiPresence(status, settings.Line))
+	}
+
+	rotation, err := m.store.LoadStatusRotation()
+	if err != nil {
+		return "", fmt.Errorf("loading status rotation: %w", err)
+	}
+	if Rotating(rotation) {
+		m.mu.Lock()
+		m.current %= len(rotation.Entries)
+		entry := rotation.Entries[m.current]
+		m.mu.Unlock()
+		return SourceRotation, m.session.UpdateStatusComplex(m.Expand(entry))
+	}
+
+	status, err := m.store.LoadStatus()
+	if err != nil {
+		return "", fmt.Errorf("loading status: %w", err)
+	}
+	if status == nil {
+		status = &discordgo.UpdateStatusData{Status: "online"}
+	}
+	return SourceStatus, m.session.UpdateStatusComplex(m.Expand(*status))
+}
+
+// Ready applies the presence once the gateway session is established,
+// including after a reconnect, which starts a new session with no presence.
+func (m *Manager) Ready(s *discordgo.Session, r *discordgo.Ready) {
+	m.reapply("ready")
+}
+
+// Resumed reapplies the presence after a resumed session, which can come
+// back with the presence reset.
+func (m *Manager) Resumed(s *discordgo.Session, r *discordgo.Resumed) {
+	m.reapply("resumed")
+}
+
+func (m *Manager) reapply(event string) {
+	source, err := m.Apply()
+	if err != nil {
+		slog.Error("reapplying presence failed", "event", event, "source", source, "error", err)
+		return
+	}
+	slog.Info("presence reapplied", "event", event, "source", source)
+}
+
+func (m *Manager) applyLogged() {
+	source, err := m.Apply()
+	if err != nil {
+		slog.Error("applying presence failed", "source", source, "error", err)
+		return
+	}
+	slog.Debug("presence applied", "source", source)
+}
+
+// Expand returns a copy of status with the placeholders in its activities'
+// text filled in:
+//
+//	{guild_count}  servers the bot is in
+//	{uptime}       time since the bot started, e.g. "3d 4h"
+func (m *Manager) Expand(status discordgo.UpdateStatusData) discordgo.UpdateStatusData {
+	m.session.State.RLock()
+	guilds := len(m.session.State.Guilds)
+	m.session.State.RUnlock()
+	replacer := strings.NewReplacer(
+		"{guild_count}", strconv.Itoa(guilds),
+		"{uptime}", FormatUptime(time.Since(m.started)),
+	)
+
+	activities := make([]*discordgo.Activity, 0, len(status.Activities))
+	for _, act := range status.Activities {
+		expanded := *act
+		expanded.Name = replacer.Replace(act.Name)
+		expanded.Details = replacer.Replace(act.Details)
+		expanded.State = replacer.Replace(act.State)
+		expanded.Assets.LargeText = replacer.Replace(act.Assets.LargeText)
+		expanded.Assets.SmallText = replacer.Replace(act.Assets.SmallText)
+		activities = append(activities, &expanded)
+	}
+	status.Activities = activities
+	return status
+}
+
+// FormatUptime renders d with its two largest units, e.g. "3d 4h" or "12m".
+func FormatUptime(d time.Duration) string {
+	days := int(d / (24 * time.Hour))
+	hours := int(d/time.Hour) % 24
+	minutes := int(d/time.Minute) % 60
+	switch {
+	case days > 0:
+		return fmt.Sprintf("%dd %dh", days, hours)
+	case hours > 0:
+		return fmt.Sprintf("%dh %dm", hours, minutes)
+	default:
+		return fmt.Sprintf("%dm", minutes)
+	}
+}
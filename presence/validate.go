@@ -0,0 +1,101 @@
+package presence
+
+import (
+	"fmt"
+	"net/url"
+	"regexp"
+	"strings"
+
+	"github.com/bwmarrin/discordgo"
+)
+
+// MaxActivityText is the longest activity name, details or custom status Discord accepts.
+const MaxActivityText = 128
+
+// onlineStatuses are the statuses a bot can set.
+var onlineStatuses = map[string]bool{"online": true, "idle": true, "dnd": true, "invisible": true}
+
+// activityTypes are the activity types a bot can show besides a custom status.
+var activityTypes = map[discordgo.ActivityType]bool{
+	discordgo.ActivityTypeGame:      true,
+	discordgo.ActivityTypeStreaming: true,
+	discordgo.ActivityTypeListening: true,
+	discordgo.ActivityTypeWatching:  true,
+	discordgo.ActivityTypeCompeting: true,
+}
+
+// streamingHosts are the sites Discord shows a streaming activity for.
+var streamingHosts = map[string]bool{
+	"twitch.tv": true, "www.twitch.tv": true, "m.twitch.tv": true,
+	"youtube.com": true, "www.youtube.com": true, "m.youtube.com": true, "youtu.be": true,
+}
+
+// assetKeyPattern matches art asset names from the Developer Portal and
+// "mp:" media proxy keys.
+var assetKeyPattern = regexp.MustCompile(`^(?:[a-z0-9_]{1,32}|mp:\S{1,250})$`)
+
+// Validate lists everything Discord would reject, or silently ignore, in
+// status. An empty list means it's safe to save. /config and the dashboard
+// both check presences with it.
+func Validate(status discordgo.UpdateStatusData) []string {
+	var problems []string
+	if !onlineStatuses[status.Status] {
+		problems = append(problems, fmt.Sprintf("Status %q isn't one of online, idle, dnd or invisible.", status.Status))
+	}
+	for _, act := range status.Activities {
+		if act == nil {
+			problems = append(problems, "An activity is empty.")
+			continue
+		}
+		if act.Type == discordgo.ActivityTypeCustom {
+			if act.State == "" && act.Emoji.Name == "" {
+				problems = append(problems, "The custom status needs text or an emoji.")
+			}
+			if len([]rune(act.State)) > MaxActivityText {
+				problems = append(problems, fmt.Sprintf("The custom status is longer than %d characters.", MaxActivityText))
+			}
+			continue
+		}
+		if !activityTypes[act.Type] {
+			problems = append(problems, fmt.Sprintf("Activity type %d isn't supported.", act.Type))
+		}
+		switch {
+		case strings.TrimSpace(act.Name) == "":
+			problems = append(problems, "The activity needs a name.")
+		case len([]rune(act.Name)) > MaxActivityText:
+			problems = append(problems, fmt.Sprintf("The activity name is longer than %d characters.", MaxActivityText))
+		}
+		if len([]rune(act.Details)) > MaxActivityText {
+			problems = append(problems, fmt.Sprintf("The details are longer than %d characters.", MaxActivityText))
+		}
+
+		if act.URL != "" && !validStreamingURL(act.URL) {
+			problems = append(problems, fmt.Sprintf("%q isn't a Twitch or YouTube link.", act.URL))
+		} else if act.Type == discordgo.ActivityTypeStreaming && act.URL == "" {
+			problems = append(problems, "Streaming needs a Twitch or YouTube URL.")
+		}
+
+		assets := []struct{ label, key, text string }{
+			{"large image", act.Assets.LargeImageID, act.Assets.LargeText},
+			{"small image", act.Assets.SmallImageID, act.Assets.SmallText},
+		}
+		for _, a := range assets {
+			if a.key != "" && !assetKeyPattern.MatchString(a.key) {
+				problems = append(problems, fmt.Sprintf("The %s key %q isn't valid. Use the asset's name from the Developer Portal (lowercase letters, digits and _) or an mp: key.", a.label, a.key))
+			}
+			if a.key == "" && a.text != "" {
+				problems = append(problems, fmt.Sprintf("The %s tooltip needs a %s key.", a.label, a.label))
+			}
+		}
+	}
+	return problems
+}
+
+// validStreamingURL reports whether u links to a Twitch or YouTube page.
+func validStreamingURL(u string) bool {
+	parsed, err := url.Parse(u)
+	if err != nil || (parsed.Scheme != "https" && parsed.Scheme != "http") {
+		return false
+	}
+	return streamingHosts[strings.ToLower(parsed.Hostname())] && strings.Trim(parsed.Path, "/") != ""
+}
@@ -0,0 +1,84 @@
+package presence
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/bwmarrin/discordgo"
+)
+
+func TestValidate(t *testing.T) {
+	playing := func(change func(*discordgo.Activity)) discordgo.UpdateStatusData {
+		act := &discordgo.Activity{Type: discordgo.ActivityTypeGame, Name: "Minecraft"}
+		if change != nil {
+			change(act)
+		}
+		return discordgo.UpdateStatusData{Status: "online", Activities: []*discordgo.Activity{act}}
+	}
+	long := strings.Repeat("é", MaxActivityText+1)
+
+	tests := []struct {
+		name   string
+		status discordgo.UpdateStatusData
+		want   []string // substrings, one per expected problem
+	}{
+		{"valid", playing(nil), nil},
+		{"no activities", discordgo.UpdateStatusData{Status: "idle"}, nil},
+		{"max length in runes", playing(func(a *discordgo.Activity) { a.Name = strings.Repeat("é", MaxActivityText) }), nil},
+		{"bad status", discordgo.UpdateStatusData{Status: "busy"}, []string{`"busy" isn't one of`}},
+		{"empty status", discordgo.UpdateStatusData{}, []string{`"" isn't one of`}},
+		{"nil activity", discordgo.UpdateStatusData{Status: "online", Activities: []*discordgo.Activity{nil}}, []string{"empty"}},
+		{"bad activity type", playing(func(a *discordgo.Activity) { a.Type = 42 }), []string{"type 42 isn't supported"}},
+		{"blank name", playing(func(a *discordgo.Activity) { a.Name = "  " }), []string{"needs a name"}},
+		{"long name", playing(func(a *discordgo.Activity) { a.Name = long }), []string{"name is longer"}},
+		{"long details", playing(func(a *discordgo.Activity) { a.Details = long }), []string{"details are longer"}},
+		{"streaming without URL", playing(func(a *discordgo.Activity) { a.Type = discordgo.ActivityTypeStreaming }), []string{"Streaming needs"}},
+		{"streaming on Twitch", playing(func(a *discordgo.Activity) {
+			a.Type, a.URL = discordgo.ActivityTypeStreaming, "https://www.twitch.tv/someone"
+		}), nil},
+		{"streaming on YouTube", playing(func(a *discordgo.Activity) {
+			a.Type, a.URL = discordgo.ActivityTypeStreaming, "https://youtu.be/dQw4w9WgXcQ"
+		}), nil},
+		{"streaming elsewhere", playing(func(a *discordgo.Activity) {
+			a.Type, a.URL = discordgo.ActivityTypeStreaming, "https://kick.com/someone"
+		}), []string{"isn't a Twitch or YouTube link"}},
+		{"streaming to a bare host", playing(func(a *discordgo.Activity) {
+			a.Type, a.URL = discordgo.ActivityTypeStreaming, "https://twitch.tv/"
+		}), []string{"isn't a Twitch or YouTube link"}},
+		{"streaming without a scheme", playing(func(a *discordgo.Activity) {
+			a.Type, a.URL = discordgo.ActivityTypeStreaming, "twitch.tv/someone"
+		}), []string{"isn't a Twitch or YouTube link"}},
+		{"valid asset keys", playing(func(a *discordgo.Activity) {
+			a.Assets = discordgo.Assets{LargeImageID: "logo_2", LargeText: "Hi", SmallImageID: "mp:external/abc/https/example.com/a.png"}
+		}), nil},
+		{"uppercase asset key", playing(func(a *discordgo.Activity) { a.Assets.LargeImageID = "Logo" }), []string{"large image key"}},
+		{"asset key with spaces", playing(func(a *discordgo.Activity) { a.Assets.SmallImageID = "my logo" }), []string{"small image key"}},
+		{"long asset key", playing(func(a *discordgo.Activity) { a.Assets.LargeImageID = strings.Repeat("a", 33) }), []string{"large image key"}},
+		{"tooltip without key", playing(func(a *discordgo.Activity) { a.Assets.SmallText = "hover" }), []string{"small image tooltip needs"}},
+		{"custom status", discordgo.UpdateStatusData{Status: "dnd", Activities: []*discordgo.Activity{
+			{Type: discordgo.ActivityTypeCustom, State: "Busy", Emoji: discordgo.Emoji{Name: "🎉"}},
+		}}, nil},
+		{"empty custom status", discordgo.UpdateStatusData{Status: "dnd", Activities: []*discordgo.Activity{
+			{Type: discordgo.ActivityTypeCustom},
+		}}, []string{"needs text or an emoji"}},
+		{"long custom status", discordgo.UpdateStatusData{Status: "dnd", Activities: []*discordgo.Activity{
+			{Type: discordgo.ActivityTypeCustom, State: long},
+		}}, []string{"custom status is longer"}},
+		{"several problems", discordgo.UpdateStatusData{Status: "away", Activities: []*discordgo.Activity{
+			{Type: discordgo.ActivityTypeStreaming, Assets: discordgo.Assets{LargeImageID: "Bad Key"}},
+		}}, []string{"isn't one of", "needs a name", "Streaming needs", "large image key"}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			problems := Validate(tt.status)
+			if len(problems) != len(tt.want) {
+				t.Fatalf("Validate = %q, want %d problems matching %q", problems, len(tt.want), tt.want)
+			}
+			for idx, want := range tt.want {
+				if !strings.Contains(problems[idx], want) {
+					t.Errorf("problem %d = %q, want it to mention %q", idx, problems[idx], want)
+				}
+			}
+		})
+	}
+}
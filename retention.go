@@ -0,0 +1,52 @@
+package main
+
+import (
+	"context"
+	"log/slog"
+	"os"
+	"strconv"
+	"time"
+
+	"discord-ai-bot/db"
+)
+
+// defaultJanitorInterval is how often retention is enforced. Override with RETENTION_INTERVAL.
+const defaultJanitorInterval = time.Hour
+
+// runJanitor enforces RETENTION_MAX_AGE (a Go duration such as "720h") and
+// RETENTION_MAX_TURNS (exchanges kept per scope) until ctx is cancelled. It
+// does nothing when neither is set.
+func runJanitor(ctx context.Context, store db.Store) {
+	var policy db.RetentionPolicy
+	if d, err := time.ParseDuration(os.Getenv("RETENTION_MAX_AGE")); err == nil && d > 0 {
+		policy.MaxAge = d
+	}
+	if n, err := strconv.Atoi(os.Getenv("RETENTION_MAX_TURNS")); err == nil && n > 0 {
+		policy.MaxTurns = n
+	}
+	if policy.MaxAge == 0 && policy.MaxTurns == 0 {
+		return
+	}
+	interval := defaultJanitorInterval
+	if d, err := time.ParseDuration(os.Getenv("RETENTION_INTERVAL")); err == nil && d > 0 {
+		interval = d
+	}
+	slog.Info("history retention enabled", "max_age", policy.MaxAge.String(), "max_turns", policy.MaxTurns, "interval", interval.String())
+
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		removed, err := store.ApplyRetention(policy)
+		if err != nil {
+			slog.Error("enforcing history retention failed", "error", err)
+		} else if removed > 0 {
+			slog.Info("history retention enforced", "removed", removed)
+		}
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
@@ -0,0 +1,332 @@
+package server
+
+import (
+	"crypto/subtle"
+	_ "embed"
+	"encoding/json"
+	"fmt"
+	"log/slog"
+	"mime"
+	"net/http"
+	"os"
+	"sort"
+	"strconv"
+	"strings"
+
+	"discord-ai-bot/db"
+	"discord-ai-bot/moderation"
+	"discord-ai-bot/presence"
+
+	"github.com/bwmarrin/discordgo"
+)
+
+//go:embed dashboard.html
+var dashboardHTML []byte
+
+// maxRequestBody bounds JSON bodies accepted by the API.
+const maxRequestBody = 1 << 20
+
+// registerDashboard mounts the admin UI at /admin and its JSON API at /api/.
+// Both are disabled unless DASHBOARD_TOKEN or DASHBOARD_USER/DASHBOARD_PASSWORD is set.
+//
+// Rate limits are the per-guild token quotas, and the only trigger that can be
+// configured is the list of channels where a ping starts a conversation
+// thread; both are part of the guild settings.
+func registerDashboard(mux *http.ServeMux, s *discordgo.Session, store db.Store, pres *presence.Manager) {
+	auth := dashboardAuth()
+	if auth == nil {
+		slog.Info("admin dashboard disabled: set DASHBOARD_TOKEN or DASHBOARD_USER and DASHBOARD_PASSWORD to enable it")
+		return
+	}
+
+	api := &dashboardAPI{session: s, store: store, presence: pres}
+	mux.Handle("/admin", auth(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "text/html; charset=utf-8")
+		w.Header().Set("X-Frame-Options", "DENY")
+		w.Write(dashboardHTML)
+	})))
+	mux.Handle("/api/personality", auth(http.HandlerFunc(api.personality)))
+	mux.Handle("/api/status", auth(http.HandlerFunc(api.status)))
+	mux.Handle("/api/conversations", auth(http.HandlerFunc(api.conversations)))
+	mux.Handle("/api/guilds", auth(http.HandlerFunc(api.guilds)))
+	mux.Handle("/api/guilds/", auth(http.HandlerFunc(api.guildSettings)))
+}
+
+// --- AUTHENTICATION ---
+
+// dashboardAuth returns middleware accepting either "Authorization: Bearer
+// <DASHBOARD_TOKEN>" or HTTP basic auth with DASHBOARD_USER/DASHBOARD_PASSWORD.
+// It returns nil when neither is configured.
+func dashboardAuth() func(http.Handler) http.Handler {
+	token := os.Getenv("DASHBOARD_TOKEN")
+	user, password := os.Getenv("DASHBOARD_USER"), os.Getenv("DASHBOARD_PASSWORD")
+	basicEnabled := user != "" && password != ""
+	if token == "" && !basicEnabled {
+		return nil
+	}
+
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			if bearer, ok := strings.CutPrefix(r.Header.Get("Authorization"), "Bearer "); ok && token != "" {
+				if secureEqual(bearer, token) {
+					next.ServeHTTP(w, r)
+					return
+				}
+			}
+			if u, p, ok := r.BasicAuth(); ok && basicEnabled {
+				if secureEqual(u, user) && secureEqual(p, password) {
+					next.ServeHTTP(w, r)
+					return
+				}
+			}
+
+			// The UI page itself falls back to the browser's basic auth prompt
+			// when that is configured; with token auth the page asks for the token.
+			if r.URL.Path == "/admin" && !basicEnabled {
+				next.ServeHTTP(w, r)
+				return
+			}
+			if basicEnabled {
+				w.Header().Set("WWW-Authenticate", `Basic realm="bot dashboard"`)
+			}
+			writeError(w, http.StatusUnauthorized, "unauthorized")
+		})
+	}
+}
+
+func secureEqual(a, b string) bool {
+	return subtle.ConstantTimeCompare([]byte(a), []byte(b)) == 1
+}
+
+// --- API ---
+
+type dashboardAPI struct {
+	session  *discordgo.Session
+	store    db.Store
+	presence *presence.Manager
+}
+
+// GET returns the personality; PUT {"personality": "..."} replaces it.
+func (a *dashboardAPI) personality(w http.ResponseWriter, r *http.Request) {
+	type body struct {
+		Personality string `json:"personality"`
+	}
+	switch r.Method {
+	case http.MethodGet:
+		personality, err := a.store.LoadPersonality()
+		if err != nil {
+			storeError(w, "loading personality", err)
+			return
+		}
+		writeJSON(w, http.StatusOK, body{Personality: personality})
+	case http.MethodPut:
+		var req body
+		if !readJSON(w, r, &req) {
+			return
+		}
+		req.Personality = strings.TrimSpace(req.Personality)
+		if req.Personality == "" || len([]rune(req.Personality)) > 2000 {
+			writeError(w, http.StatusBadRequest, "personality must be 1-2000 characters")
+			return
+		}
+		if err := a.store.SavePersonality(req.Personality); err != nil {
+			storeError(w, "saving personality", err)
+			return
+		}
+		slog.Info("personality updated from dashboard")
+		writeJSON(w, http.StatusOK, req)
+	default:
+		methodNotAllowed(w, http.MethodGet, http.MethodPut)
+	}
+}
+
+// GET returns the saved presence; PUT validates and saves a new one like
+// /config does and applies the presence, answering {"status": ..., "shown": source}.
+// shown is "rotation" or "ai" when those take priority over the saved status.
+func (a *dashboardAPI) status(w http.ResponseWriter, r *http.Request) {
+	switch r.Method {
+	case http.MethodGet:
+		status, err := a.store.LoadStatus()
+		if err != nil {
+			storeError(w, "loading status", err)
+			return
+		}
+		if status == nil {
+			status = &discordgo.UpdateStatusData{Status: "online", Activities: []*discordgo.Activity{}}
+		}
+		writeJSON(w, http.StatusOK, status)
+	case http.MethodPut:
+		var status discordgo.UpdateStatusData
+		if !readJSON(w, r, &status) {
+			return
+		}
+		if problems := presence.Validate(status); len(problems) > 0 {
+			writeError(w, http.StatusBadRequest, strings.Join(problems, " "))
+			return
+		}
+		if err := a.store.SaveStatus(status); err != nil {
+			storeError(w, "saving status", err)
+			return
+		}
+		source, err := a.presence.Apply()
+		if err != nil {
+			slog.Error("applying status from dashboard failed", "error", err)
+			writeError(w, http.StatusBadGateway, "saved, but applying the status failed: "+err.Error())
+			return
+		}
+		slog.Info("status updated from dashboard", "shown", source)
+		writeJSON(w, http.StatusOK, map[string]interface{}{"status": status, "shown": source})
+	default:
+		methodNotAllowed(w, http.MethodGet, http.MethodPut)
+	}
+}
+
+// GET ?limit=N&scope=S returns the newest N messages of a scope's rolling
+// conversation history (the global scope by default).
+func (a *dashboardAPI) conversations(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		methodNotAllowed(w, http.MethodGet)
+		return
+	}
+	limit := 50
+	if n, err := strconv.Atoi(r.URL.Query().Get("limit")); err == nil && n > 0 {
+		limit = n
+	}
+	scope := r.URL.Query().Get("scope")
+	if scope == "" {
+		scope = db.GlobalScope
+	}
+	history, err := a.store.LoadHistory(scope)
+	if err != nil {
+		storeError(w, "loading history", err)
+		return
+	}
+	if len(history) > limit {
+		history = history[len(history)-limit:]
+	}
+	writeJSON(w, http.StatusOK, map[string]interface{}{"messages": history})
+}
+
+// GET lists the guilds the bot is in with their settings.
+func (a *dashboardAPI) guilds(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		methodNotAllowed(w, http.MethodGet)
+		return
+	}
+	type guild struct {
+		ID       string           `json:"id"`
+		Name     string           `json:"name"`
+		Settings db.GuildSettings `json:"settings"`
+	}
+	a.session.State.RLock()
+	guilds := make([]guild, 0, len(a.session.State.Guilds))
+	for _, g := range a.session.State.Guilds {
+		guilds = append(guilds, guild{ID: g.ID, Name: g.Name})
+	}
+	a.session.State.RUnlock()
+
+	for idx := range guilds {
+		settings, err := a.store.LoadGuildSettings(guilds[idx].ID)
+		if err != nil {
+			storeError(w, "loading guild settings", err)
+			return
+		}
+		guilds[idx].Settings = settings
+	}
+	sort.Slice(guilds, func(x, y int) bool { return guilds[x].Name < guilds[y].Name })
+	writeJSON(w, http.StatusOK, map[string]interface{}{"guilds": guilds})
+}
+
+// GET/PUT /api/guilds/{id}/settings reads or replaces a guild's settings
+// (moderation level, mod log channel and token quotas).
+func (a *dashboardAPI) guildSettings(w http.ResponseWriter, r *http.Request) {
+	parts := strings.Split(strings.Trim(strings.TrimPrefix(r.URL.Path, "/api/guilds/"), "/"), "/")
+	if len(parts) != 2 || parts[1] != "settings" || parts[0] == "" {
+		writeError(w, http.StatusNotFound, "not found")
+		return
+	}
+	guildID := parts[0]
+
+	switch r.Method {
+	case http.MethodGet:
+		settings, err := a.store.LoadGuildSettings(guildID)
+		if err != nil {
+			storeError(w, "loading guild settings", err)
+			return
+		}
+		writeJSON(w, http.StatusOK, settings)
+	case http.MethodPut:
+		var settings db.GuildSettings
+		if !readJSON(w, r, &settings) {
+			return
+		}
+		if settings.ModerationLevel != "" {
+			if _, ok := moderation.ParseLevel(settings.ModerationLevel); !ok {
+				writeError(w, http.StatusBadRequest, "moderation_level must be off, low, medium or high")
+				return
+			}
+		}
+		for _, id := range append([]string{settings.ModLogChannelID}, settings.ThreadChannels...) {
+			if id != "" && !isSnowflake(id) {
+				writeError(w, http.StatusBadRequest, fmt.Sprintf("%q isn't a channel ID", id))
+				return
+			}
+		}
+		if err := a.store.SaveGuildSettings(guildID, settings); err != nil {
+			storeError(w, "saving guild settings", err)
+			return
+		}
+		slog.Info("guild settings updated from dashboard", "guild_id", guildID)
+		writeJSON(w, http.StatusOK, settings)
+	default:
+		methodNotAllowed(w, http.MethodGet, http.MethodPut)
+	}
+}
+
+// isSnowflake reports whether id looks like a Discord ID.
+func isSnowflake(id string) bool {
+	if len(id) == 0 || len(id) > 20 {
+		return false
+	}
+	_, err := strconv.ParseUint(id, 10, 64)
+	return err == nil
+}
+
+// --- JSON HELPERS ---
+
+// readJSON decodes a JSON request body into v. Requiring the JSON content type
+// also means browsers must preflight cross-site writes.
+func readJSON(w http.ResponseWriter, r *http.Request, v interface{}) bool {
+	if mediaType, _, _ := mime.ParseMediaType(r.Header.Get("Content-Type")); mediaType != "application/json" {
+		writeError(w, http.StatusUnsupportedMediaType, "Content-Type must be application/json")
+		return false
+	}
+	dec := json.NewDecoder(http.MaxBytesReader(w, r.Body, maxRequestBody))
+	dec.DisallowUnknownFields()
+	if err := dec.Decode(v); err != nil {
+		writeError(w, http.StatusBadRequest, fmt.Sprintf("invalid JSON: %v", err))
+		return false
+	}
+	return true
+}
+
+func writeJSON(w http.ResponseWriter, code int, v interface{}) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(code)
+	json.NewEncoder(w).Encode(v)
+}
+
+func writeError(w http.ResponseWriter, code int, msg string) {
+	writeJSON(w, code, map[string]string{"error": msg})
+}
+
+// storeError logs a storage failure and answers 500 without exposing details.
+func storeError(w http.ResponseWriter, action string, err error) {
+	slog.Error(action+" failed", "error", err)
+	writeError(w, http.StatusInternalServerError, action+" failed")
+}
+
+func methodNotAllowed(w http.ResponseWriter, allowed ...string) {
+	w.Header().Set("Allow", strings.Join(allowed, ", "))
+	writeError(w, http.StatusMethodNotAllowed, "method not allowed")
+}
@@ -0,0 +1,149 @@
+package server
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"discord-ai-bot/db"
+)
+
+// newDashboard mounts the dashboard on a fresh mux with the given environment.
+func newDashboard(t *testing.T, env map[string]string) (*http.ServeMux, db.Store) {
+	t.Helper()
+	for _, key := range []string{"DASHBOARD_TOKEN", "DASHBOARD_USER", "DASHBOARD_PASSWORD"} {
+		t.Setenv(key, env[key])
+	}
+	store := db.NewMemoryStore()
+	mux := http.NewServeMux()
+	registerDashboard(mux, nil, store, nil)
+	return mux, store
+}
+
+type authRequest struct {
+	name       string
+	path       string
+	bearer     string
+	user, pass string
+	want       int
+}
+
+func (tt authRequest) serve(mux *http.ServeMux) *httptest.ResponseRecorder {
+	req := httptest.NewRequest(http.MethodGet, tt.path, nil)
+	if tt.bearer != "" {
+		req.Header.Set("Authorization", "Bearer "+tt.bearer)
+	}
+	if tt.user != "" || tt.pass != "" {
+		req.SetBasicAuth(tt.user, tt.pass)
+	}
+	rec := httptest.NewRecorder()
+	mux.ServeHTTP(rec, req)
+	return rec
+}
+
+func TestDashboardDisabled(t *testing.T) {
+	newDashboard(t, nil)
+	if dashboardAuth() != nil {
+		t.Error("dashboardAuth enabled without DASHBOARD_TOKEN or DASHBOARD_USER/DASHBOARD_PASSWORD")
+	}
+	mux, _ := newDashboard(t, map[string]string{"DASHBOARD_USER": "admin"}) // no password
+	if rec := (authRequest{path: "/api/personality"}).serve(mux); rec.Code != http.StatusNotFound {
+		t.Errorf("/api/personality with the dashboard disabled = %d, want 404", rec.Code)
+	}
+}
+
+func TestDashboardTokenAuth(t *testing.T) {
+	mux, _ := newDashboard(t, map[string]string{"DASHBOARD_TOKEN": "s3cret"})
+	tests := []authRequest{
+		{name: "no credentials", path: "/api/personality", want: http.StatusUnauthorized},
+		{name: "wrong token", path: "/api/personality", bearer: "guess", want: http.StatusUnauthorized},
+		{name: "token prefix", path: "/api/personality", bearer: "s3cre", want: http.StatusUnauthorized},
+		{name: "basic auth isn't configured", path: "/api/personality", user: "admin", pass: "s3cret", want: http.StatusUnauthorized},
+		{name: "right token", path: "/api/personality", bearer: "s3cret", want: http.StatusOK},
+		{name: "other API routes", path: "/api/guilds/1", want: http.StatusUnauthorized},
+		// The page itself loads so it can ask for the token
+		{name: "UI page", path: "/admin", want: http.StatusOK},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			rec := tt.serve(mux)
+			if rec.Code != tt.want {
+				t.Errorf("GET %s = %d, want %d: %s", tt.path, rec.Code, tt.want, rec.Body)
+			}
+			if rec.Header().Get("WWW-Authenticate") != "" {
+				t.Error("token auth asked for basic auth")
+			}
+		})
+	}
+}
+
+func TestDashboardBasicAuth(t *testing.T) {
+	mux, _ := newDashboard(t, map[string]string{"DASHBOARD_USER": "admin", "DASHBOARD_PASSWORD": "hunter2", "DASHBOARD_TOKEN": "s3cret"})
+	tests := []authRequest{
+		{name: "no credentials", path: "/api/personality", want: http.StatusUnauthorized},
+		{name: "wrong password", path: "/api/personality", user: "admin", pass: "hunter3", want: http.StatusUnauthorized},
+		{name: "wrong user", path: "/api/personality", user: "root", pass: "hunter2", want: http.StatusUnauthorized},
+		{name: "right password", path: "/api/personality", user: "admin", pass: "hunter2", want: http.StatusOK},
+		{name: "token still works", path: "/api/personality", bearer: "s3cret", want: http.StatusOK},
+		{name: "UI page without credentials", path: "/admin", want: http.StatusUnauthorized},
+		{name: "UI page", path: "/admin", user: "admin", pass: "hunter2", want: http.StatusOK},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			rec := tt.serve(mux)
+			if rec.Code != tt.want {
+				t.Errorf("GET %s = %d, want %d: %s", tt.path, rec.Code, tt.want, rec.Body)
+			}
+			if rec.Code == http.StatusUnauthorized && rec.Header().Get("WWW-Authenticate") == "" {
+				t.Error("401 without a basic auth challenge")
+			}
+		})
+	}
+}
+
+func TestReadJSONContentType(t *testing.T) {
+	mux, store := newDashboard(t, map[string]string{"DASHBOARD_TOKEN": "s3cret"})
+	tests := []struct {
+		name        string
+		contentType string
+		body        string
+		want        int
+	}{
+		{"no content type", "", `{"personality":"a pirate"}`, http.StatusUnsupportedMediaType},
+		{"form", "application/x-www-form-urlencoded", `{"personality":"a pirate"}`, http.StatusUnsupportedMediaType},
+		{"text", "text/plain", `{"personality":"a pirate"}`, http.StatusUnsupportedMediaType},
+		{"invalid JSON", "application/json", `{"personality":`, http.StatusBadRequest},
+		{"unknown field", "application/json", `{"personality":"a pirate","admin":true}`, http.StatusBadRequest},
+		{"JSON with a charset", "application/json; charset=utf-8", `{"personality":"a pirate"}`, http.StatusOK},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			mustSavePersonality(t, store, "unchanged")
+			req := httptest.NewRequest(http.MethodPut, "/api/personality", strings.NewReader(tt.body))
+			req.Header.Set("Authorization", "Bearer s3cret")
+			if tt.contentType != "" {
+				req.Header.Set("Content-Type", tt.contentType)
+			}
+			rec := httptest.NewRecorder()
+			mux.ServeHTTP(rec, req)
+			if rec.Code != tt.want {
+				t.Errorf("PUT with %q = %d, want %d: %s", tt.contentType, rec.Code, tt.want, rec.Body)
+			}
+			want := "unchanged"
+			if tt.want == http.StatusOK {
+				want = "a pirate"
+			}
+			if got, _ := store.LoadPersonality(); got != want {
+				t.Errorf("personality after PUT = %q, want %q", got, want)
+			}
+		})
+	}
+}
+
+func mustSavePersonality(t *testing.T, store db.Store, personality string) {
+	t.Helper()
+	if err := store.SavePersonality(personality); err != nil {
+		t.Fatalf("SavePersonality: %v", err)
+	}
+}
@@ -0,0 +1,111 @@
+// Package server serves the bot's HTTP endpoints: the keep-alive root page,
+// health and readiness probes, Prometheus metrics and the admin dashboard.
+package server
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"os"
+	"time"
+
+	"discord-ai-bot/ai"
+	"discord-ai-bot/db"
+	"discord-ai-bot/metrics"
+	"discord-ai-bot/presence"
+
+	"github.com/bwmarrin/discordgo"
+)
+
+// defaultProviderWindow is how long after its last success the LLM provider
+// still counts as reachable once calls start failing. Override with
+// PROVIDER_READY_WINDOW (a Go duration such as "10m").
+const defaultProviderWindow = 5 * time.Minute
+
+var startTime = time.Now()
+
+// New returns the HTTP handler for the bot's endpoints. Presence changes made
+// on the dashboard are applied through pres, like /config's.
+func New(s *discordgo.Session, store db.Store, pres *presence.Manager) http.Handler {
+	mux := http.NewServeMux()
+	mux.HandleFunc("/", func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/" {
+			http.NotFound(w, r)
+			return
+		}
+		fmt.Fprintf(w, "Discord Bot is running.")
+	})
+	mux.HandleFunc("/healthz", func(w http.ResponseWriter, r *http.Request) {
+		fmt.Fprintln(w, "ok")
+	})
+	mux.HandleFunc("/readyz", readyz(s, store))
+	mux.HandleFunc("/metrics", metricsHandler(s))
+	registerDashboard(mux, s, store, pres)
+	return mux
+}
+
+// readyz reports 200 only when the gateway is connected, the database can be
+// read and the LLM provider has been reachable recently.
+func readyz(s *discordgo.Session, store db.Store) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		checks := map[string]string{}
+		ready := true
+		fail := func(name string, err error) {
+			checks[name] = err.Error()
+			ready = false
+		}
+
+		if gatewayConnected(s) {
+			checks["gateway"] = "ok"
+		} else {
+			fail("gateway", fmt.Errorf("not connected"))
+		}
+
+		if err := store.Ping(); err != nil {
+			fail("database", err)
+		} else {
+			checks["database"] = "ok"
+		}
+
+		window := defaultProviderWindow
+		if d, err := time.ParseDuration(os.Getenv("PROVIDER_READY_WINDOW")); err == nil {
+			window = d
+		}
+		if err := ai.ProviderHealthy(window); err != nil {
+			fail("provider", err)
+		} else {
+			checks["provider"] = "ok"
+		}
+
+		status := "ok"
+		w.Header().Set("Content-Type", "application/json")
+		if !ready {
+			status = "unavailable"
+			w.WriteHeader(http.StatusServiceUnavailable)
+		}
+		json.NewEncoder(w).Encode(map[string]interface{}{"status": status, "checks": checks})
+	}
+}
+
+// metricsHandler writes the registered metrics plus gauges sampled at scrape time.
+func metricsHandler(s *discordgo.Session) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "text/plain; version=0.0.4; charset=utf-8")
+		metrics.WritePrometheus(w)
+
+		connected := 0
+		if gatewayConnected(s) {
+			connected = 1
+		}
+		fmt.Fprintf(w, "# HELP discord_bot_gateway_connected Whether the Discord gateway connection is ready.\n")
+		fmt.Fprintf(w, "# TYPE discord_bot_gateway_connected gauge\ndiscord_bot_gateway_connected %d\n", connected)
+		fmt.Fprintf(w, "# HELP discord_bot_uptime_seconds Seconds since the process started.\n")
+		fmt.Fprintf(w, "# TYPE discord_bot_uptime_seconds gauge\ndiscord_bot_uptime_seconds %d\n", int(time.Since(startTime).Seconds()))
+	}
+}
+
+func gatewayConnected(s *discordgo.Session) bool {
+	s.RLock()
+	defer s.RUnlock()
+	return s.DataReady
+}